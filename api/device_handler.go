@@ -2,15 +2,31 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/netip"
 	"network-scanner/logger"
 	"network-scanner/model"
+	"network-scanner/repository"
 	"network-scanner/service"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+const (
+	defaultDeviceLimit = 100
+	maxDeviceLimit     = 1000
+)
+
+// defaultMetricsWindow is how far back device metrics go without a from
+// parameter.
+const defaultMetricsWindow = 24 * time.Hour
+
 type DeviceHandler struct {
 	scanner *service.ScannerService
 	logger  logger.Logger
@@ -20,16 +36,109 @@ func NewDeviceHandler(scanner *service.ScannerService, logger logger.Logger) *De
 	return &DeviceHandler{scanner: scanner, logger: logger}
 }
 
+type DeviceTypeRequest struct {
+	Type string `json:"type" example:"printer"`
+}
+
 // GetDevices godoc
-// @Summary Get all discovered devices
-// @Description Returns the list of scanned devices
+// @Summary List discovered devices
+// @Description Returns one page of devices with the total number of matches. Pass next_cursor back as cursor to get the following page.
+// @Param limit query int false "Page size (default 100, max 1000)"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param sort query string false "Sort field and direction, e.g. last_seen:desc (default ip_address:asc)"
+// @Param status query string false "online, degraded or offline"
+// @Param tag query string false "Only devices with this tag"
+// @Param type query string false "Only devices of this type: router, switch, printer, phone, iot, server, workstation, hypervisor or camera"
+// @Param manufacturer query string false "Manufacturer contains this text"
+// @Param seen_since query string false "Only devices seen at or after this RFC3339 time"
+// @Param cidr query string false "Only devices whose address is in this prefix, e.g. 10.0.1.0/24"
+// @Param q query string false "Search expression, see /devices/search"
 // @Produce json
-// @Success 200 {array} model.Device
+// @Success 200 {object} model.DevicePage
+// @Failure 400 {string} string "Invalid query"
 // @Router /devices [get]
 func (h *DeviceHandler) GetDevices(w http.ResponseWriter, r *http.Request) {
-	devices := h.scanner.GetDevices()
-	h.logger.Info("Fetched ", len(devices), " devices")
-	json.NewEncoder(w).Encode(devices)
+	q, err := parseDeviceQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := h.scanner.ListDevices(q)
+	if errors.Is(err, repository.ErrInvalidSort) || errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list devices:", err)
+		http.Error(w, "Failed to list devices", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Fetched ", len(page.Devices), " of ", page.Total, " devices")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func parseDeviceQuery(r *http.Request) (repository.DeviceQuery, error) {
+	v := r.URL.Query()
+	q := repository.DeviceQuery{
+		Status:       v.Get("status"),
+		Tag:          strings.ToLower(strings.TrimSpace(v.Get("tag"))),
+		Type:         strings.ToLower(strings.TrimSpace(v.Get("type"))),
+		Manufacturer: strings.TrimSpace(v.Get("manufacturer")),
+		Cursor:       v.Get("cursor"),
+		Limit:        defaultDeviceLimit,
+	}
+	switch q.Status {
+	case "", "online", "degraded", "offline":
+	default:
+		return q, fmt.Errorf("invalid status: %q", q.Status)
+	}
+	if q.Type != "" && !slices.Contains(model.DeviceTypes, q.Type) {
+		return q, fmt.Errorf("invalid type: %q, expected one of %s", q.Type, strings.Join(model.DeviceTypes, ", "))
+	}
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("invalid limit: %q", s)
+		}
+		q.Limit = min(n, maxDeviceLimit)
+	}
+	if s := v.Get("sort"); s != "" {
+		field, dir, _ := strings.Cut(s, ":")
+		q.Sort = field
+		switch strings.ToLower(dir) {
+		case "", "asc":
+		case "desc":
+			q.Desc = true
+		default:
+			return q, fmt.Errorf("invalid sort direction: %q", dir)
+		}
+		if !slices.Contains(repository.DeviceSortFields(), field) {
+			return q, fmt.Errorf("invalid sort field: %q, expected one of %s", field, strings.Join(repository.DeviceSortFields(), ", "))
+		}
+	}
+	if s := v.Get("seen_since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, fmt.Errorf("invalid seen_since: %q is not an RFC3339 time", s)
+		}
+		q.SeenSince = t
+	}
+	if s := v.Get("cidr"); s != "" {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return q, fmt.Errorf("invalid cidr: %q", s)
+		}
+		q.CIDR = p
+	}
+	if s := v.Get("q"); s != "" {
+		expr, err := service.ParseQuery(s, time.Now())
+		if err != nil {
+			return q, err
+		}
+		q.Match = expr
+	}
+	return q, nil
 }
 
 // ClearDevices godoc
@@ -60,6 +169,124 @@ func (h *DeviceHandler) GetDeviceByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(dev)
 }
 
+// GetDevicePorts godoc
+// @Summary Get the port inventory of a device
+// @Param id path string true "Device ID"
+// @Produce json
+// @Success 200 {array} model.Port
+// @Failure 404 {string} string "Not found"
+// @Router /devices/{id}/ports [get]
+func (h *DeviceHandler) GetDevicePorts(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	dev, err := h.scanner.FindByID(id)
+	if err != nil || dev == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	if dev.Ports == nil {
+		dev.Ports = []model.Port{}
+	}
+	json.NewEncoder(w).Encode(dev.Ports)
+}
+
+// GetDeviceAddresses godoc
+// @Summary Get the IP address history of a device
+// @Param id path string true "Device ID"
+// @Produce json
+// @Success 200 {array} model.DeviceAddress
+// @Failure 404 {string} string "Not found"
+// @Router /devices/{id}/addresses [get]
+func (h *DeviceHandler) GetDeviceAddresses(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	dev, err := h.scanner.FindByID(id)
+	if err != nil || dev == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	if dev.AddressHistory == nil {
+		dev.AddressHistory = []model.DeviceAddress{}
+	}
+	json.NewEncoder(w).Encode(dev.AddressHistory)
+}
+
+// GetDeviceHistory godoc
+// @Summary Get the event history of a device
+// @Param id path string true "Device ID"
+// @Param from query string false "Only events at or after this RFC3339 time"
+// @Param to query string false "Only events at or before this RFC3339 time"
+// @Param type query string false "Comma separated event types"
+// @Param limit query int false "Maximum number of events (default 100, max 1000)"
+// @Produce json
+// @Success 200 {array} model.DeviceEvent
+// @Failure 400 {string} string "Invalid filter"
+// @Failure 404 {string} string "Not found"
+// @Router /devices/{id}/history [get]
+func (h *DeviceHandler) GetDeviceHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	f, err := parseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if dev, err := h.scanner.FindByID(id); err != nil || dev == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	events, err := h.scanner.DeviceHistory(id, f)
+	if err != nil {
+		h.logger.Error("Failed to load device history:", err)
+		http.Error(w, "Failed to load device history", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(events)
+}
+
+// GetDeviceMetrics godoc
+// @Summary Get latency and packet loss samples of a device
+// @Description Samples are taken on every status poll answered over ICMP, oldest first.
+// @Param id path string true "Device ID"
+// @Param from query string false "Only samples at or after this RFC3339 time (default 24h ago)"
+// @Param to query string false "Only samples at or before this RFC3339 time"
+// @Produce json
+// @Success 200 {array} model.LatencySample
+// @Failure 400 {string} string "Invalid time range"
+// @Failure 404 {string} string "Not found"
+// @Router /devices/{id}/metrics [get]
+func (h *DeviceHandler) GetDeviceMetrics(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	q := r.URL.Query()
+	from := time.Now().Add(-defaultMetricsWindow)
+	var to time.Time
+	var err error
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid from: %q is not an RFC3339 time", v), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid to: %q is not an RFC3339 time", v), http.StatusBadRequest)
+			return
+		}
+	}
+	if !to.IsZero() && to.Before(from) {
+		http.Error(w, "invalid range: to is before from", http.StatusBadRequest)
+		return
+	}
+	if dev, err := h.scanner.FindByID(id); err != nil || dev == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	samples, err := h.scanner.DeviceMetrics(id, from, to)
+	if err != nil {
+		h.logger.Error("Failed to load device metrics:", err)
+		http.Error(w, "Failed to load device metrics", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(samples)
+}
+
 // AddTag godoc
 // @Summary Add a tag to a device
 // @Param id path string true "Device ID"
@@ -127,11 +354,64 @@ func (h *DeviceHandler) RemoveTag(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Tag removed"))
 }
 
+// SetDeviceType godoc
+// @Summary Override the type of a device
+// @Description The type is kept across rescans until the override is removed.
+// @Param id path string true "Device ID"
+// @Accept json
+// @Produce json
+// @Param input body DeviceTypeRequest true "Device type"
+// @Success 200 {object} model.Device
+// @Failure 400 {string} string "Invalid type"
+// @Failure 404 {string} string "Not found"
+// @Router /devices/{id}/type [put]
+func (h *DeviceHandler) SetDeviceType(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var req DeviceTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	dev, err := h.scanner.SetDeviceType(id, req.Type)
+	h.writeDeviceType(w, id, dev, err)
+}
+
+// ClearDeviceType godoc
+// @Summary Remove a device type override
+// @Description The device is classified again from its attributes.
+// @Param id path string true "Device ID"
+// @Produce json
+// @Success 200 {object} model.Device
+// @Failure 404 {string} string "Not found"
+// @Router /devices/{id}/type [delete]
+func (h *DeviceHandler) ClearDeviceType(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	dev, err := h.scanner.ClearDeviceType(id)
+	h.writeDeviceType(w, id, dev, err)
+}
+
+func (h *DeviceHandler) writeDeviceType(w http.ResponseWriter, id string, dev *model.Device, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidDeviceType):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		h.logger.Error("Failed to update type of device ", id, ": ", err)
+		http.Error(w, "Failed to update device type", http.StatusInternalServerError)
+	case dev == nil:
+		http.Error(w, "Device not found", http.StatusNotFound)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dev)
+	}
+}
+
 // SearchDevices godoc
-// @Summary Search devices by IP, hostname, or tags
-// @Param query query string true "Search query"
+// @Summary Search devices with a query expression
+// @Description Terms are field:value pairs (id, hostname, mac, manufacturer, status, type, tag, ip) or bare words, combined with AND, OR, NOT and parentheses. first_seen and last_seen take <, <=, > or >= with an age (7d) or an RFC3339 time. Text values accept * as a wildcard.
+// @Param query query string true "Search query, e.g. tag:prod AND status:offline AND last_seen<7d"
 // @Produce json
 // @Success 200 {array} model.Device
+// @Failure 400 {string} string "Syntax error with its position"
 // @Router /devices/search [get]
 func (h *DeviceHandler) SearchDevices(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query().Get("query")
@@ -140,7 +420,13 @@ func (h *DeviceHandler) SearchDevices(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	result, err := h.scanner.SearchDevices(q)
+	var syntaxErr *service.QuerySyntaxError
+	if errors.As(err, &syntaxErr) {
+		http.Error(w, syntaxErr.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
+		h.logger.Error("Failed to search devices:", err)
 		http.Error(w, "Search error", http.StatusInternalServerError)
 		return
 	}
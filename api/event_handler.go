@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"network-scanner/logger"
+	"network-scanner/repository"
+	"network-scanner/service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+)
+
+type EventHandler struct {
+	scanner *service.ScannerService
+	logger  logger.Logger
+}
+
+func NewEventHandler(scanner *service.ScannerService, logger logger.Logger) *EventHandler {
+	return &EventHandler{scanner: scanner, logger: logger}
+}
+
+// ListEvents godoc
+// @Summary List device events across all devices
+// @Description Returns status transitions and attribute changes, newest first.
+// @Param from query string false "Only events at or after this RFC3339 time"
+// @Param to query string false "Only events at or before this RFC3339 time"
+// @Param type query string false "Comma separated event types, e.g. status_changed,discovered"
+// @Param limit query int false "Maximum number of events (default 100, max 1000)"
+// @Produce json
+// @Success 200 {array} model.DeviceEvent
+// @Failure 400 {string} string "Invalid filter"
+// @Router /events [get]
+func (h *EventHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	f, err := parseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	events, err := h.scanner.Events(f)
+	if err != nil {
+		h.logger.Error("Failed to list events:", err)
+		http.Error(w, "Failed to list events", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+func parseEventFilter(r *http.Request) (repository.EventFilter, error) {
+	q := r.URL.Query()
+	f := repository.EventFilter{Limit: defaultEventLimit}
+	for _, t := range strings.Split(q.Get("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f.Types = append(f.Types, t)
+		}
+	}
+	var err error
+	if v := q.Get("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid from: %q is not an RFC3339 time", v)
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid to: %q is not an RFC3339 time", v)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return f, fmt.Errorf("invalid limit: %q", v)
+		}
+		f.Limit = min(n, maxEventLimit)
+	}
+	return f, nil
+}
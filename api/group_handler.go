@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"network-scanner/service"
+
+	"github.com/gorilla/mux"
+)
+
+type GroupHandler struct {
+	scanner *service.ScannerService
+	logger  logger.Logger
+}
+
+func NewGroupHandler(scanner *service.ScannerService, logger logger.Logger) *GroupHandler {
+	return &GroupHandler{scanner: scanner, logger: logger}
+}
+
+type GroupRequest struct {
+	Name      string   `json:"name" example:"offline printers"`
+	Type      string   `json:"type" enums:"dynamic,static" example:"dynamic"`
+	Query     string   `json:"query,omitempty" example:"tag:printer AND status:offline"`
+	DeviceIDs []string `json:"device_ids,omitempty"`
+}
+
+// ListGroups godoc
+// @Summary List device groups
+// @Produce json
+// @Success 200 {array} model.DeviceGroup
+// @Router /groups [get]
+func (h *GroupHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.scanner.Groups()
+	if err != nil {
+		h.logger.Error("Failed to fetch groups:", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(groups)
+}
+
+// CreateGroup godoc
+// @Summary Create a device group
+// @Description A dynamic group saves a search query and always reflects current device state; a static group lists its member device IDs.
+// @Accept json
+// @Produce json
+// @Param input body GroupRequest true "Group"
+// @Success 201 {object} model.DeviceGroup
+// @Failure 400 {string} string "Invalid group or query"
+// @Router /groups [post]
+func (h *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	h.saveGroup(w, r, "")
+}
+
+// GetGroup godoc
+// @Summary Get a device group by ID
+// @Param id path string true "Group ID"
+// @Produce json
+// @Success 200 {object} model.DeviceGroup
+// @Failure 404 {string} string "Not found"
+// @Router /groups/{id} [get]
+func (h *GroupHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	g, err := h.scanner.Group(mux.Vars(r)["id"])
+	if err != nil {
+		h.groupError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(g)
+}
+
+// UpdateGroup godoc
+// @Summary Replace a device group
+// @Param id path string true "Group ID"
+// @Accept json
+// @Produce json
+// @Param input body GroupRequest true "Group"
+// @Success 200 {object} model.DeviceGroup
+// @Failure 400 {string} string "Invalid group or query"
+// @Failure 404 {string} string "Not found"
+// @Router /groups/{id} [put]
+func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	h.saveGroup(w, r, mux.Vars(r)["id"])
+}
+
+// DeleteGroup godoc
+// @Summary Delete a device group
+// @Param id path string true "Group ID"
+// @Success 200 {string} string "Deleted"
+// @Failure 404 {string} string "Not found"
+// @Router /groups/{id} [delete]
+func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if err := h.scanner.DeleteGroup(mux.Vars(r)["id"]); err != nil {
+		h.groupError(w, err)
+		return
+	}
+	w.Write([]byte("Deleted"))
+}
+
+// GetGroupDevices godoc
+// @Summary List the current members of a device group
+// @Param id path string true "Group ID"
+// @Produce json
+// @Success 200 {array} model.Device
+// @Failure 404 {string} string "Not found"
+// @Router /groups/{id}/devices [get]
+func (h *GroupHandler) GetGroupDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := h.scanner.GroupDevices(mux.Vars(r)["id"])
+	if err != nil {
+		h.groupError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(devices)
+}
+
+func (h *GroupHandler) saveGroup(w http.ResponseWriter, r *http.Request, id string) {
+	var body GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	g, err := h.scanner.SaveGroup(model.DeviceGroup{
+		ID:        id,
+		Name:      body.Name,
+		Type:      body.Type,
+		Query:     body.Query,
+		DeviceIDs: body.DeviceIDs,
+	})
+	if err != nil {
+		h.groupError(w, err)
+		return
+	}
+	if id == "" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(g)
+}
+
+func (h *GroupHandler) groupError(w http.ResponseWriter, err error) {
+	var syntaxErr *service.QuerySyntaxError
+	switch {
+	case errors.Is(err, service.ErrGroupNotFound):
+		http.Error(w, "Group not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidGroup), errors.As(err, &syntaxErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.logger.Error("Group request failed:", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
@@ -5,15 +5,20 @@ import (
 	"database/sql"
 	"encoding/json"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"network-scanner/model"
 	"network-scanner/repository"
 	"network-scanner/service"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gorilla/mux"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -73,10 +78,11 @@ func TestStartScanAndGetDevices_SQLite(t *testing.T) {
 			data, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
 
-			var devices []model.Device
-			if err := json.Unmarshal(data, &devices); err != nil {
+			var page model.DevicePage
+			if err := json.Unmarshal(data, &page); err != nil {
 				t.Fatalf("failed to parse JSON: %v", err)
 			}
+			devices := page.Devices
 
 			if len(devices) == 1 && devices[0].IPAddress == "127.0.0.1" {
 				if devices[0].ID == "" {
@@ -104,3 +110,362 @@ func TestStartScanAndGetDevices_SQLite(t *testing.T) {
 		time.Sleep(50 * time.Millisecond)
 	}
 }
+
+func TestStartScan_InvalidCIDR(t *testing.T) {
+	scanner := service.NewScannerService(repository.NewInMemoryRepository(), &dummyLogger{})
+	scanHandler := NewScanHandler(scanner, &dummyLogger{})
+
+	body := []byte(`{"ip_range": "10.0.0.0/33"}`)
+	req := httptest.NewRequest(http.MethodPost, "/scan", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	scanHandler.StartScan(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 Bad Request, got %d", w.Code)
+	}
+}
+
+func TestGetScan_NotFound(t *testing.T) {
+	scanner := service.NewScannerService(repository.NewInMemoryRepository(), &dummyLogger{})
+	scanHandler := NewScanHandler(scanner, &dummyLogger{})
+
+	req := httptest.NewRequest(http.MethodGet, "/scans/missing", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "missing"})
+	w := httptest.NewRecorder()
+	scanHandler.GetScan(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 Not Found, got %d", w.Code)
+	}
+}
+
+func TestListEvents_InvalidFilter(t *testing.T) {
+	scanner := service.NewScannerService(repository.NewInMemoryRepository(), &dummyLogger{})
+	eventHandler := NewEventHandler(scanner, &dummyLogger{})
+
+	for _, q := range []string{"from=yesterday", "to=2024-13-01", "limit=0"} {
+		req := httptest.NewRequest(http.MethodGet, "/events?"+q, nil)
+		w := httptest.NewRecorder()
+		eventHandler.ListEvents(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 Bad Request, got %d", q, w.Code)
+		}
+	}
+}
+
+func TestGetDeviceMetrics(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1"})
+	scanner := service.NewScannerService(repo, &dummyLogger{})
+	deviceHandler := NewDeviceHandler(scanner, &dummyLogger{})
+
+	cases := map[string]int{
+		"/devices/d1/metrics":                http.StatusOK,
+		"/devices/d1/metrics?from=yesterday": http.StatusBadRequest,
+		"/devices/d1/metrics?from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z": http.StatusBadRequest,
+		"/devices/missing/metrics": http.StatusNotFound,
+	}
+	for target, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		id := strings.Split(target, "/")[2]
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		deviceHandler.GetDeviceMetrics(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", target, want, w.Code)
+		}
+	}
+}
+
+func TestAvailabilityReport_FormatsAndValidation(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	events := repository.NewInMemoryEventRepository()
+	now := time.Now().UTC()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1", Status: "online", Tags: []string{"prod"}, FirstSeen: now.Add(-time.Hour)})
+	events.Append(model.DeviceEvent{DeviceID: "d1", Type: model.EventDiscovered, Timestamp: now.Add(-time.Hour)})
+	reportHandler := NewReportHandler(service.NewReportService(repo, events), &dummyLogger{})
+
+	req := httptest.NewRequest(http.MethodGet, "/reports/availability?format=csv", nil)
+	w := httptest.NewRecorder()
+	reportHandler.Availability(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("expected CSV response, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "device,d1,10.0.0.1,") || !strings.HasPrefix(lines[2], "tag,prod,") {
+		t.Errorf("unexpected CSV body:\n%s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/reports/availability?tag=prod", nil)
+	w = httptest.NewRecorder()
+	reportHandler.Availability(w, req)
+	var report model.AvailabilityReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil || len(report.Devices) != 1 {
+		t.Errorf("expected one device in JSON report, got %+v (%v)", report, err)
+	}
+
+	for _, q := range []string{"from=yesterday", "format=xml", "from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z"} {
+		req := httptest.NewRequest(http.MethodGet, "/reports/availability?"+q, nil)
+		w := httptest.NewRecorder()
+		reportHandler.Availability(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 Bad Request, got %d", q, w.Code)
+		}
+	}
+}
+
+func TestGetDevices_InvalidQuery(t *testing.T) {
+	scanner := service.NewScannerService(repository.NewInMemoryRepository(), &dummyLogger{})
+	deviceHandler := NewDeviceHandler(scanner, &dummyLogger{})
+
+	for _, q := range []string{"limit=-1", "sort=password", "sort=last_seen:sideways", "status=asleep", "type=toaster", "seen_since=today", "cidr=10.0.0.0/33", "cursor=%21%21"} {
+		req := httptest.NewRequest(http.MethodGet, "/devices?"+q, nil)
+		w := httptest.NewRecorder()
+		deviceHandler.GetDevices(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 Bad Request, got %d", q, w.Code)
+		}
+	}
+}
+
+func TestDeviceTypeOverride(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1", Status: "online", Hostname: "office-printer"})
+	scanner := service.NewScannerService(repo, &dummyLogger{})
+	deviceHandler := NewDeviceHandler(scanner, &dummyLogger{})
+
+	put := func(id, body string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/devices/"+id+"/type", strings.NewReader(body)), map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		deviceHandler.SetDeviceType(w, req)
+		return w
+	}
+	if w := put("d1", `{"type":"toaster"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown type, got %d", w.Code)
+	}
+	if w := put("nope", `{"type":"camera"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown device, got %d", w.Code)
+	}
+	w := put("d1", `{"type":"camera"}`)
+	var d model.Device
+	if err := json.NewDecoder(w.Body).Decode(&d); err != nil || d.DeviceType != model.TypeCamera || !d.TypeManual {
+		t.Fatalf("expected a manual camera, got %d %+v (%v)", w.Code, d, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/devices?type=camera", nil)
+	w = httptest.NewRecorder()
+	deviceHandler.GetDevices(w, req)
+	var page model.DevicePage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil || page.Total != 1 {
+		t.Errorf("expected the camera filter to find d1, got %+v (%v)", page, err)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/devices/d1/type", nil), map[string]string{"id": "d1"})
+	w = httptest.NewRecorder()
+	deviceHandler.ClearDeviceType(w, req)
+	d = model.Device{}
+	if err := json.NewDecoder(w.Body).Decode(&d); err != nil || d.DeviceType != model.TypePrinter || d.TypeManual {
+		t.Errorf("expected d1 to be reclassified as a printer, got %d %+v (%v)", w.Code, d, err)
+	}
+}
+
+func TestSearchDevices_SyntaxError(t *testing.T) {
+	scanner := service.NewScannerService(repository.NewInMemoryRepository(), &dummyLogger{})
+	deviceHandler := NewDeviceHandler(scanner, &dummyLogger{})
+
+	req := httptest.NewRequest(http.MethodGet, "/devices/search?query="+url.QueryEscape("tag:prod AND (status:offline"), nil)
+	w := httptest.NewRecorder()
+	deviceHandler.SearchDevices(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 Bad Request, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "position 29") {
+		t.Errorf("expected the error to name its position, got %q", w.Body.String())
+	}
+}
+
+func TestGroupHandler_CRUD(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1", Status: "offline", Tags: []string{"printer"}})
+	scanner := service.NewScannerService(repo, &dummyLogger{})
+	groupHandler := NewGroupHandler(scanner, &dummyLogger{})
+
+	req := httptest.NewRequest(http.MethodPost, "/groups", strings.NewReader(`{"name":"offline printers","type":"dynamic","query":"tag:printer AND status:offline"}`))
+	w := httptest.NewRecorder()
+	groupHandler.CreateGroup(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body.String())
+	}
+	var g model.DeviceGroup
+	json.NewDecoder(w.Body).Decode(&g)
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/groups/"+g.ID+"/devices", nil), map[string]string{"id": g.ID})
+	w = httptest.NewRecorder()
+	groupHandler.GetGroupDevices(w, req)
+	var devices []model.Device
+	if err := json.NewDecoder(w.Body).Decode(&devices); err != nil || len(devices) != 1 || devices[0].ID != "d1" {
+		t.Errorf("expected d1 in group, got %+v (%v)", devices, err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/groups", strings.NewReader(`{"name":"broken","type":"dynamic","query":"status:offline AND"}`))
+	w = httptest.NewRecorder()
+	groupHandler.CreateGroup(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "position") {
+		t.Errorf("expected 400 with the syntax error position, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/groups/nope", nil), map[string]string{"id": "nope"})
+	w = httptest.NewRecorder()
+	groupHandler.DeleteGroup(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found, got %d", w.Code)
+	}
+}
+
+func TestTagHandler_BulkAndRename(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1", Status: "online", Tags: []string{"prod"}})
+	repo.Save(model.Device{ID: "d2", IPAddress: "10.0.0.2", Status: "offline"})
+	scanner := service.NewScannerService(repo, &dummyLogger{})
+	tagHandler := NewTagHandler(scanner, &dummyLogger{})
+
+	req := httptest.NewRequest(http.MethodPost, "/devices/tags", strings.NewReader(`{"query":"ip:10.0.0.0/24","add":["lab"]}`))
+	w := httptest.NewRecorder()
+	tagHandler.BulkTags(w, req)
+	var res model.BulkTagResult
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil || res.Matched != 2 || res.Updated != 2 {
+		t.Fatalf("expected both devices tagged, got %d %+v (%v)", w.Code, res, err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/devices/tags", strings.NewReader(`{"ids":["nope"],"add":["lab"]}`))
+	w = httptest.NewRecorder()
+	tagHandler.BulkTags(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown device, got %d", w.Code)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/tags/lab/rename", strings.NewReader(`{"name":"prod"}`)), map[string]string{"name": "lab"})
+	w = httptest.NewRecorder()
+	tagHandler.RenameTag(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 when renaming onto an existing tag, got %d", w.Code)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/tags/lab/merge", strings.NewReader(`{"into":"prod"}`)), map[string]string{"name": "lab"})
+	w = httptest.NewRecorder()
+	tagHandler.MergeTag(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK for merge, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	tagHandler.ListTags(w, httptest.NewRequest(http.MethodGet, "/tags", nil))
+	var tags []model.Tag
+	if err := json.NewDecoder(w.Body).Decode(&tags); err != nil || len(tags) != 1 || tags[0].Name != "prod" || tags[0].DeviceCount != 2 {
+		t.Errorf("expected only prod on 2 devices, got %+v (%v)", tags, err)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/tags/nope", nil), map[string]string{"name": "nope"})
+	w = httptest.NewRecorder()
+	tagHandler.DeleteTag(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found, got %d", w.Code)
+	}
+}
+
+func TestTagRuleHandler_CreateAndDryRun(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1", Manufacturer: "Raspberry Pi Trading Ltd"})
+	repo.Save(model.Device{ID: "d2", IPAddress: "10.0.0.2", Manufacturer: "Cisco Systems"})
+	scanner := service.NewScannerService(repo, &dummyLogger{})
+	ruleHandler := NewTagRuleHandler(scanner, &dummyLogger{})
+
+	const pis = `{"name":"pis","match":{"manufacturer":"raspberry"},"tags":["iot"]}`
+	w := httptest.NewRecorder()
+	ruleHandler.DryRunTagRules(w, httptest.NewRequest(http.MethodPost, "/tag-rules/dry-run", strings.NewReader(pis)))
+	var changes []model.TagRuleChange
+	if err := json.NewDecoder(w.Body).Decode(&changes); err != nil || len(changes) != 1 || changes[0].DeviceID != "d1" {
+		t.Errorf("expected only d1 to change, got %d %+v (%v)", w.Code, changes, err)
+	}
+	if d, _ := repo.FindByID("d1"); len(d.Tags) != 0 {
+		t.Errorf("expected dry run to leave tags alone, got %v", d.Tags)
+	}
+
+	w = httptest.NewRecorder()
+	ruleHandler.CreateTagRule(w, httptest.NewRequest(http.MethodPost, "/tag-rules", strings.NewReader(pis)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 Created, got %d: %s", w.Code, w.Body.String())
+	}
+	var rule model.TagRule
+	if err := json.NewDecoder(w.Body).Decode(&rule); err != nil || !rule.Enabled {
+		t.Errorf("expected an enabled rule by default, got %+v (%v)", rule, err)
+	}
+	if d, _ := repo.FindByID("d1"); len(d.Tags) != 1 || d.Tags[0] != "iot" {
+		t.Errorf("expected the new rule to tag d1, got %v", d.Tags)
+	}
+	if d, _ := repo.FindByID("d2"); len(d.Tags) != 0 {
+		t.Errorf("expected d2 to be left alone, got %v", d.Tags)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/tag-rules/dry-run", strings.NewReader(`{"name":"bad","match":{"hostname":"("},"tags":["x"]}`))
+	w = httptest.NewRecorder()
+	ruleHandler.DryRunTagRules(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid candidate rule, got %d", w.Code)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/tag-rules/nope", strings.NewReader(`{"name":"x","match":{"cidr":"10.0.0.0/8"},"tags":["x"]}`)), map[string]string{"id": "nope"})
+	w = httptest.NewRecorder()
+	ruleHandler.UpdateTagRule(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found, got %d", w.Code)
+	}
+}
+
+func vendorUpload(t *testing.T, content string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "oui.txt")
+	fw.Write([]byte(content))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/admin/vendors/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestVendorHandler_Import(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mac-vendors.csv")
+	os.WriteFile(path, []byte("Mac Prefix,Vendor Name,Private,Block Type,Last Update\n00:00:0C,Cisco,false,MA-L,2015/11/17\n"), 0o644)
+	resolver, err := service.NewOfflineManufacturerResolver(path, filepath.Join(dir, "vendors"))
+	if err != nil {
+		t.Fatalf("failed to load vendors: %v", err)
+	}
+	repo := repository.NewInMemoryRepository()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1", MACAddress: "00:22:72:00:00:01"})
+	scanner := service.NewScannerServiceWithResolver(repo, &dummyLogger{}, resolver)
+	vendorHandler := NewVendorHandler(scanner, &dummyLogger{})
+
+	w := httptest.NewRecorder()
+	vendorHandler.ImportVendors(w, vendorUpload(t, "not a registry file"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed file, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	vendorHandler.ImportVendors(w, vendorUpload(t, "00-22-72   (hex)\t\tAmerican Micro-Fuel Device Corp.\n"))
+	var res model.VendorUpdate
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil || res.Prefixes != 2 || res.DevicesUpdated != 1 {
+		t.Fatalf("expected 2 prefixes and 1 device updated, got %d %+v (%v)", w.Code, res, err)
+	}
+	if d, _ := repo.FindByID("d1"); d.Manufacturer != "American Micro-Fuel Device Corp." {
+		t.Errorf("expected d1 to be re-resolved, got %q", d.Manufacturer)
+	}
+
+	w = httptest.NewRecorder()
+	vendorHandler.ReloadVendors(w, httptest.NewRequest(http.MethodPost, "/admin/vendors/reload", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 OK for reload, got %d", w.Code)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"network-scanner/logger"
 	"network-scanner/model"
@@ -41,7 +42,7 @@ func (h *RangeHandler) ListRanges(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param input body model.IPRange true "IP Range"
 // @Success 201 {string} string "Created"
-// @Failure 400 {string} string "Invalid input"
+// @Failure 400 {string} string "Invalid input or target spec"
 // @Router /ranges [post]
 func (h *RangeHandler) AddRange(w http.ResponseWriter, r *http.Request) {
 	var input model.IPRange
@@ -51,8 +52,12 @@ func (h *RangeHandler) AddRange(w http.ResponseWriter, r *http.Request) {
 	}
 	input.ID = uuid.New().String()
 	if err := h.service.Save(input); err != nil {
+		if errors.Is(err, service.ErrInvalidRange) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		h.logger.Error("Failed to save IP range:", err)
-		http.Error(w, "Invalid CIDR or server error", http.StatusBadRequest)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
 		return
 	}
 
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"network-scanner/service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultReportWindow is the report period when no from parameter is given.
+const defaultReportWindow = 30 * 24 * time.Hour
+
+type ReportHandler struct {
+	service *service.ReportService
+	logger  logger.Logger
+}
+
+func NewReportHandler(service *service.ReportService, logger logger.Logger) *ReportHandler {
+	return &ReportHandler{service: service, logger: logger}
+}
+
+// Availability godoc
+// @Summary Device and tag availability report
+// @Description Uptime percentage, outage count, longest outage and MTTR per device and per tag, computed from recorded status changes. A degraded device counts as the state it is leaving, so an outage lasts until the device is confirmed online again.
+// @Param from query string false "Start of the window, RFC3339 (default 30 days before to)"
+// @Param to query string false "End of the window, RFC3339 (default now)"
+// @Param tag query string false "Only include devices with this tag"
+// @Param format query string false "json (default) or csv"
+// @Produce json
+// @Produce text/csv
+// @Success 200 {object} model.AvailabilityReport
+// @Failure 400 {string} string "Invalid parameters"
+// @Router /reports/availability [get]
+func (h *ReportHandler) Availability(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	to := time.Now()
+	var from time.Time
+	var err error
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid to: %q is not an RFC3339 time", v), http.StatusBadRequest)
+			return
+		}
+	}
+	from = to.Add(-defaultReportWindow)
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid from: %q is not an RFC3339 time", v), http.StatusBadRequest)
+			return
+		}
+	}
+	format := strings.ToLower(q.Get("format"))
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		format = "csv"
+	}
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, fmt.Sprintf("invalid format: %q", format), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.Availability(from, to, strings.TrimSpace(q.Get("tag")))
+	if errors.Is(err, service.ErrInvalidWindow) {
+		http.Error(w, "invalid window: from must be before to", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to build availability report:", err)
+		http.Error(w, "Failed to build availability report", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="availability.csv"`)
+		writeAvailabilityCSV(w, report)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// writeAvailabilityCSV writes one row per device followed by one row per tag,
+// told apart by the scope column.
+func writeAvailabilityCSV(w http.ResponseWriter, report model.AvailabilityReport) {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"scope", "name", "ip_address", "hostname", "devices", "availability_percent",
+		"monitored_seconds", "uptime_seconds", "downtime_seconds", "outages", "longest_outage_seconds", "mttr_seconds"})
+	row := func(scope, name, ip, hostname string, devices int, st model.AvailabilityStats) []string {
+		f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+		return []string{scope, name, ip, hostname, strconv.Itoa(devices), f(st.AvailabilityPercent),
+			f(st.MonitoredSeconds), f(st.UptimeSeconds), f(st.DowntimeSeconds), strconv.Itoa(st.Outages),
+			f(st.LongestOutageSeconds), f(st.MTTRSeconds)}
+	}
+	for _, d := range report.Devices {
+		cw.Write(row("device", d.DeviceID, d.IPAddress, d.Hostname, 1, d.AvailabilityStats))
+	}
+	for _, t := range report.Tags {
+		cw.Write(row("tag", t.Tag, "", "", t.Devices, t.AvailabilityStats))
+	}
+	cw.Flush()
+}
@@ -2,9 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"network-scanner/logger"
+	"network-scanner/model"
 	"network-scanner/service"
+
+	"github.com/gorilla/mux"
 )
 
 type ScanHandler struct {
@@ -17,29 +21,113 @@ func NewScanHandler(scanner *service.ScannerService, logger logger.Logger) *Scan
 }
 
 type ScanRequest struct {
-	IPRange string `json:"ip_range"`
+	IPRange string   `json:"ip_range" example:"10.0.1.0/24,10.0.2.7"`
+	Exclude []string `json:"exclude,omitempty"`
+	Ports   string   `json:"ports,omitempty" example:"top100"`
+	// Group scans the members of a device group, by ID or name, in
+	// addition to IPRange.
+	Group string `json:"group,omitempty" example:"offline printers"`
 }
 
 // StartScan godoc
 // @Summary Initiate a network scan
-// @Description Starts scanning the given CIDR range in the background
+// @Description Queues a scan job for the given targets (CIDRs, dash ranges or single hosts, comma separated) and/or the members of a device group, minus any exclusions, and returns it. Ports takes a profile (top20, top100), a list such as 22,80,8000-8010, or none.
 // @Accept json
 // @Produce json
 // @Param input body ScanRequest true "IP range to scan"
-// @Success 202 {object} map[string]string
-// @Failure 400 {string} string "Bad request"
+// @Success 202 {object} model.ScanJob
+// @Failure 400 {string} string "Invalid or oversized range, unknown or empty group, or invalid ports"
 // @Router /scan [post]
 func (h *ScanHandler) StartScan(w http.ResponseWriter, r *http.Request) {
 	var body ScanRequest
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.IPRange == "" {
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || (body.IPRange == "" && body.Group == "") {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		h.logger.Error("Invalid scan request body")
 		return
 	}
 
-	h.logger.Info("Received scan request for range: ", body.IPRange)
-	h.scanner.StartScan(body.IPRange)
+	h.logger.Info("Received scan request for range: ", body.IPRange, " group: ", body.Group)
+	job, err := h.scanner.StartScanWithOptions(service.ScanOptions{
+		Range:   body.IPRange,
+		Exclude: body.Exclude,
+		Ports:   body.Ports,
+		Group:   body.Group,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRange) || errors.Is(err, service.ErrScanTooLarge) ||
+			errors.Is(err, service.ErrInvalidPorts) || errors.Is(err, service.ErrGroupNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.logger.Error("Failed to start scan:", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusAccepted)
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "Scan started"})
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// ListScans godoc
+// @Summary List scan jobs
+// @Description Returns all scan jobs, newest first
+// @Produce json
+// @Success 200 {array} model.ScanJob
+// @Router /scans [get]
+func (h *ScanHandler) ListScans(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.scanner.ListScans()
+	if err != nil {
+		h.logger.Error("Failed to fetch scans:", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if jobs == nil {
+		jobs = []model.ScanJob{}
+	}
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// GetScan godoc
+// @Summary Get a scan job by ID
+// @Param id path string true "Scan ID"
+// @Produce json
+// @Success 200 {object} model.ScanJob
+// @Failure 404 {string} string "Not found"
+// @Router /scans/{id} [get]
+func (h *ScanHandler) GetScan(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	job, err := h.scanner.GetScan(id)
+	if err != nil {
+		http.Error(w, "Scan not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelScan godoc
+// @Summary Cancel a scan job
+// @Description Cancels a queued or running scan and returns its final state
+// @Param id path string true "Scan ID"
+// @Produce json
+// @Success 200 {object} model.ScanJob
+// @Failure 404 {string} string "Not found"
+// @Failure 409 {string} string "Scan already finished"
+// @Router /scans/{id} [delete]
+func (h *ScanHandler) CancelScan(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	job, err := h.scanner.CancelScan(id)
+	switch {
+	case errors.Is(err, service.ErrScanNotFound):
+		http.Error(w, "Scan not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrScanFinished):
+		http.Error(w, "Scan already finished", http.StatusConflict)
+		return
+	case err != nil:
+		h.logger.Error("Failed to cancel scan:", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Scan cancelled via API: ", id)
+	json.NewEncoder(w).Encode(job)
 }
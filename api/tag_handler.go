@@ -0,0 +1,224 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"network-scanner/service"
+
+	"github.com/gorilla/mux"
+)
+
+type TagHandler struct {
+	scanner *service.ScannerService
+	logger  logger.Logger
+}
+
+func NewTagHandler(scanner *service.ScannerService, logger logger.Logger) *TagHandler {
+	return &TagHandler{scanner: scanner, logger: logger}
+}
+
+type TagRequest struct {
+	Name        string `json:"name,omitempty" example:"prod"`
+	Color       string `json:"color,omitempty" example:"#e53935"`
+	Description string `json:"description,omitempty" example:"Production hosts"`
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name" example:"production"`
+}
+
+type MergeTagRequest struct {
+	Into string `json:"into" example:"production"`
+}
+
+type BulkTagRequest struct {
+	IDs    []string `json:"ids,omitempty"`
+	Query  string   `json:"query,omitempty" example:"manufacturer:Cisco* AND status:online"`
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// ListTags godoc
+// @Summary List tags with the number of devices carrying each
+// @Produce json
+// @Success 200 {array} model.Tag
+// @Router /tags [get]
+func (h *TagHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.scanner.Tags()
+	if err != nil {
+		h.logger.Error("Failed to fetch tags:", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(tags)
+}
+
+// CreateTag godoc
+// @Summary Register a tag
+// @Accept json
+// @Produce json
+// @Param input body TagRequest true "Tag"
+// @Success 201 {object} model.Tag
+// @Failure 400 {string} string "Invalid tag"
+// @Failure 409 {string} string "Tag already exists"
+// @Router /tags [post]
+func (h *TagHandler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	var body TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	t, err := h.scanner.SaveTag(model.Tag{Name: body.Name, Color: body.Color, Description: body.Description}, false)
+	if err != nil {
+		h.tagError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+// GetTag godoc
+// @Summary Get a tag by name
+// @Param name path string true "Tag name"
+// @Produce json
+// @Success 200 {object} model.Tag
+// @Failure 404 {string} string "Not found"
+// @Router /tags/{name} [get]
+func (h *TagHandler) GetTag(w http.ResponseWriter, r *http.Request) {
+	t, err := h.scanner.Tag(mux.Vars(r)["name"])
+	if err != nil {
+		h.tagError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// UpdateTag godoc
+// @Summary Change the color and description of a tag
+// @Param name path string true "Tag name"
+// @Accept json
+// @Produce json
+// @Param input body TagRequest true "Tag; name is ignored, use the rename endpoint"
+// @Success 200 {object} model.Tag
+// @Failure 400 {string} string "Invalid tag"
+// @Failure 404 {string} string "Not found"
+// @Router /tags/{name} [put]
+func (h *TagHandler) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	var body TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	t, err := h.scanner.SaveTag(model.Tag{Name: mux.Vars(r)["name"], Color: body.Color, Description: body.Description}, true)
+	if err != nil {
+		h.tagError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// DeleteTag godoc
+// @Summary Delete a tag and remove it from every device
+// @Param name path string true "Tag name"
+// @Success 200 {string} string "Deleted"
+// @Failure 404 {string} string "Not found"
+// @Failure 409 {string} string "A tag rule adds the tag"
+// @Router /tags/{name} [delete]
+func (h *TagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	if err := h.scanner.DeleteTag(mux.Vars(r)["name"]); err != nil {
+		h.tagError(w, err)
+		return
+	}
+	w.Write([]byte("Deleted"))
+}
+
+// RenameTag godoc
+// @Summary Rename a tag on every device
+// @Param name path string true "Tag name"
+// @Accept json
+// @Produce json
+// @Param input body RenameTagRequest true "New name"
+// @Success 200 {object} model.Tag
+// @Failure 400 {string} string "Invalid tag"
+// @Failure 404 {string} string "Not found"
+// @Failure 409 {string} string "Target tag already exists, merge instead, or a tag rule adds the tag"
+// @Router /tags/{name}/rename [post]
+func (h *TagHandler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	var body RenameTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	h.renameTag(w, mux.Vars(r)["name"], body.Name, false)
+}
+
+// MergeTag godoc
+// @Summary Merge a tag into another one
+// @Description Devices carrying the tag get the target tag instead and the tag is deleted. The target keeps its color and description.
+// @Param name path string true "Tag name"
+// @Accept json
+// @Produce json
+// @Param input body MergeTagRequest true "Target tag"
+// @Success 200 {object} model.Tag
+// @Failure 400 {string} string "Invalid tag"
+// @Failure 404 {string} string "Not found"
+// @Failure 409 {string} string "A tag rule adds the tag"
+// @Router /tags/{name}/merge [post]
+func (h *TagHandler) MergeTag(w http.ResponseWriter, r *http.Request) {
+	var body MergeTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	h.renameTag(w, mux.Vars(r)["name"], body.Into, true)
+}
+
+// BulkTags godoc
+// @Summary Add and remove tags on many devices at once
+// @Description Selects devices by ids or by a search query and applies removals, then additions, in one transaction.
+// @Accept json
+// @Produce json
+// @Param input body BulkTagRequest true "Devices and tags"
+// @Success 200 {object} model.BulkTagResult
+// @Failure 400 {string} string "Invalid tags, devices or query"
+// @Router /devices/tags [post]
+func (h *TagHandler) BulkTags(w http.ResponseWriter, r *http.Request) {
+	var body BulkTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	res, err := h.scanner.BulkTags(body.IDs, body.Query, body.Add, body.Remove)
+	if err != nil {
+		h.tagError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (h *TagHandler) renameTag(w http.ResponseWriter, from, to string, merge bool) {
+	t, err := h.scanner.RenameTag(from, to, merge)
+	if err != nil {
+		h.tagError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+func (h *TagHandler) tagError(w http.ResponseWriter, err error) {
+	var syntaxErr *service.QuerySyntaxError
+	switch {
+	case errors.Is(err, service.ErrTagNotFound):
+		http.Error(w, "Tag not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrTagExists), errors.Is(err, service.ErrTagInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrInvalidTag), errors.As(err, &syntaxErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.logger.Error("Tag request failed:", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"network-scanner/service"
+
+	"github.com/gorilla/mux"
+)
+
+type TagRuleHandler struct {
+	scanner *service.ScannerService
+	logger  logger.Logger
+}
+
+func NewTagRuleHandler(scanner *service.ScannerService, logger logger.Logger) *TagRuleHandler {
+	return &TagRuleHandler{scanner: scanner, logger: logger}
+}
+
+type TagRuleRequest struct {
+	Name     string          `json:"name" example:"raspberry pis"`
+	Position int             `json:"position,omitempty"`
+	Enabled  *bool           `json:"enabled,omitempty"`
+	Match    model.RuleMatch `json:"match"`
+	Tags     []string        `json:"tags" example:"iot"`
+	Stop     bool            `json:"stop,omitempty"`
+}
+
+func (req TagRuleRequest) rule(id string) model.TagRule {
+	enabled := req.Enabled == nil || *req.Enabled
+	return model.TagRule{
+		ID:       id,
+		Name:     req.Name,
+		Position: req.Position,
+		Enabled:  enabled,
+		Match:    req.Match,
+		Tags:     req.Tags,
+		Stop:     req.Stop,
+	}
+}
+
+// ListTagRules godoc
+// @Summary List auto-tagging rules in evaluation order
+// @Produce json
+// @Success 200 {array} model.TagRule
+// @Router /tag-rules [get]
+func (h *TagRuleHandler) ListTagRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.scanner.TagRules()
+	if err != nil {
+		h.logger.Error("Failed to fetch tag rules:", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(rules)
+}
+
+// CreateTagRule godoc
+// @Summary Create an auto-tagging rule
+// @Description A rule adds its tags to every device matching all of its conditions after each scan and poll, and to all known devices when it is saved. Rules run by position; new rules go last unless a position is given.
+// @Accept json
+// @Produce json
+// @Param input body TagRuleRequest true "Rule"
+// @Success 201 {object} model.TagRule
+// @Failure 400 {string} string "Invalid rule"
+// @Failure 500 {string} string "Rule saved but not applied"
+// @Router /tag-rules [post]
+func (h *TagRuleHandler) CreateTagRule(w http.ResponseWriter, r *http.Request) {
+	h.saveTagRule(w, r, "")
+}
+
+// GetTagRule godoc
+// @Summary Get an auto-tagging rule by ID
+// @Param id path string true "Rule ID"
+// @Produce json
+// @Success 200 {object} model.TagRule
+// @Failure 404 {string} string "Not found"
+// @Router /tag-rules/{id} [get]
+func (h *TagRuleHandler) GetTagRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.scanner.TagRule(mux.Vars(r)["id"])
+	if err != nil {
+		h.ruleError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(rule)
+}
+
+// UpdateTagRule godoc
+// @Summary Replace an auto-tagging rule
+// @Param id path string true "Rule ID"
+// @Accept json
+// @Produce json
+// @Param input body TagRuleRequest true "Rule"
+// @Success 200 {object} model.TagRule
+// @Failure 400 {string} string "Invalid rule"
+// @Failure 404 {string} string "Not found"
+// @Failure 500 {string} string "Rule saved but not applied"
+// @Router /tag-rules/{id} [put]
+func (h *TagRuleHandler) UpdateTagRule(w http.ResponseWriter, r *http.Request) {
+	h.saveTagRule(w, r, mux.Vars(r)["id"])
+}
+
+// DeleteTagRule godoc
+// @Summary Delete an auto-tagging rule
+// @Param id path string true "Rule ID"
+// @Success 200 {string} string "Deleted"
+// @Failure 404 {string} string "Not found"
+// @Router /tag-rules/{id} [delete]
+func (h *TagRuleHandler) DeleteTagRule(w http.ResponseWriter, r *http.Request) {
+	if err := h.scanner.DeleteTagRule(mux.Vars(r)["id"]); err != nil {
+		h.ruleError(w, err)
+		return
+	}
+	w.Write([]byte("Deleted"))
+}
+
+// DryRunTagRules godoc
+// @Summary Show which devices the tag rules would change
+// @Description Without a body the enabled rules are evaluated; with a rule in the body only that rule is. Nothing is written.
+// @Accept json
+// @Produce json
+// @Param input body TagRuleRequest false "Candidate rule"
+// @Success 200 {array} model.TagRuleChange
+// @Failure 400 {string} string "Invalid rule"
+// @Router /tag-rules/dry-run [post]
+func (h *TagRuleHandler) DryRunTagRules(w http.ResponseWriter, r *http.Request) {
+	var candidate *model.TagRule
+	var body TagRuleRequest
+	switch err := json.NewDecoder(r.Body).Decode(&body); {
+	case errors.Is(err, io.EOF):
+	case err != nil:
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	default:
+		rule := body.rule("")
+		candidate = &rule
+	}
+	changes, err := h.scanner.DryRunTagRules(candidate)
+	if err != nil {
+		h.ruleError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(changes)
+}
+
+func (h *TagRuleHandler) saveTagRule(w http.ResponseWriter, r *http.Request, id string) {
+	var body TagRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	rule, err := h.scanner.SaveTagRule(body.rule(id))
+	if err != nil {
+		h.ruleError(w, err)
+		return
+	}
+	if id == "" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (h *TagRuleHandler) ruleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrRuleNotFound):
+		http.Error(w, "Tag rule not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidRule):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrRuleNotApplied):
+		h.logger.Error("Tag rule request failed:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		h.logger.Error("Tag rule request failed:", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"network-scanner/logger"
+	"network-scanner/service"
+)
+
+// maxVendorUpload bounds uploaded registry files; the full IEEE oui.txt is
+// about 6 MB.
+const maxVendorUpload = 32 << 20
+
+type VendorHandler struct {
+	scanner *service.ScannerService
+	logger  logger.Logger
+}
+
+func NewVendorHandler(scanner *service.ScannerService, logger logger.Logger) *VendorHandler {
+	return &VendorHandler{scanner: scanner, logger: logger}
+}
+
+// ReloadVendors godoc
+// @Summary Reload the MAC vendor database from disk
+// @Description Re-reads the vendor files and updates the manufacturer of existing devices. A malformed file is reported and the previous data stays in use.
+// @Produce json
+// @Success 200 {object} model.VendorUpdate
+// @Failure 400 {string} string "Invalid vendor file"
+// @Router /admin/vendors/reload [post]
+func (h *VendorHandler) ReloadVendors(w http.ResponseWriter, r *http.Request) {
+	res, err := h.scanner.ReloadVendors()
+	if err != nil {
+		h.vendorError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// ImportVendors godoc
+// @Summary Import an IEEE registry file into the MAC vendor database
+// @Description Accepts oui.txt or a registry CSV (oui.csv, mam.csv, oui36.csv, cid.csv) as the multipart field file. The format is detected from the content.
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Registry file"
+// @Success 200 {object} model.VendorUpdate
+// @Failure 400 {string} string "Invalid vendor file"
+// @Router /admin/vendors/import [post]
+func (h *VendorHandler) ImportVendors(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxVendorUpload)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	res, err := h.scanner.ImportVendors(file)
+	if err != nil {
+		h.vendorError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (h *VendorHandler) vendorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidVendorFile):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrVendorsReadOnly):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		h.logger.Error("Vendor database update failed:", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
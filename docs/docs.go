@@ -15,6 +15,64 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/vendors/import": {
+            "post": {
+                "description": "Accepts oui.txt or a registry CSV (oui.csv, mam.csv, oui36.csv, cid.csv) as the multipart field file. The format is detected from the content.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Import an IEEE registry file into the MAC vendor database",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Registry file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.VendorUpdate"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid vendor file",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/vendors/reload": {
+            "post": {
+                "description": "Re-reads the vendor files and updates the manufacturer of existing devices. A malformed file is reported and the previous data stays in use.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Reload the MAC vendor database from disk",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.VendorUpdate"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid vendor file",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/clear": {
             "delete": {
                 "description": "Deletes all devices from in-memory store",
@@ -31,19 +89,84 @@ const docTemplate = `{
         },
         "/devices": {
             "get": {
-                "description": "Returns the list of scanned devices",
+                "description": "Returns one page of devices with the total number of matches. Pass next_cursor back as cursor to get the following page.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get all discovered devices",
+                "summary": "List discovered devices",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field and direction, e.g. last_seen:desc (default ip_address:asc)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "online, degraded or offline",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only devices with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only devices of this type: router, switch, printer, phone, iot, server, workstation, hypervisor or camera",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Manufacturer contains this text",
+                        "name": "manufacturer",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only devices seen at or after this RFC3339 time",
+                        "name": "seen_since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only devices whose address is in this prefix, e.g. 10.0.1.0/24",
+                        "name": "cidr",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search expression, see /devices/search",
+                        "name": "q",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/model.Device"
-                            }
+                            "$ref": "#/definitions/model.DevicePage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
@@ -51,14 +174,15 @@ const docTemplate = `{
         },
         "/devices/search": {
             "get": {
+                "description": "Terms are field:value pairs (id, hostname, mac, manufacturer, status, type, tag, ip) or bare words, combined with AND, OR, NOT and parentheses. first_seen and last_seen take \u003c, \u003c=, \u003e or \u003e= with an age (7d) or an RFC3339 time. Text values accept * as a wildcard.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Search devices by IP, hostname, or tags",
+                "summary": "Search devices with a query expression",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Search query",
+                        "description": "Search query, e.g. tag:prod AND status:offline AND last_seen\u003c7d",
                         "name": "query",
                         "in": "query",
                         "required": true
@@ -73,6 +197,49 @@ const docTemplate = `{
                                 "$ref": "#/definitions/model.Device"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Syntax error with its position",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/tags": {
+            "post": {
+                "description": "Selects devices by ids or by a search query and applies removals, then additions, in one transaction.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Add and remove tags on many devices at once",
+                "parameters": [
+                    {
+                        "description": "Devices and tags",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.BulkTagRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.BulkTagResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid tags, devices or query",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
@@ -108,15 +275,12 @@ const docTemplate = `{
                 }
             }
         },
-        "/devices/{id}/tags": {
-            "post": {
-                "consumes": [
-                    "application/json"
-                ],
+        "/devices/{id}/addresses": {
+            "get": {
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Add a tag to a device",
+                "summary": "Get the IP address history of a device",
                 "parameters": [
                     {
                         "type": "string",
@@ -124,37 +288,33 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Tag to add",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Tag added",
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.DeviceAddress"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
-            "delete": {
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/devices/{id}/history": {
+            "get": {
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Remove a tag from a device",
+                "summary": "Get the event history of a device",
                 "parameters": [
                     {
                         "type": "string",
@@ -164,21 +324,48 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Tag to remove",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
+                        "type": "string",
+                        "description": "Only events at or after this RFC3339 time",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only events at or before this RFC3339 time",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated event types",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of events (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Tag removed",
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.DeviceEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
                         "schema": {
                             "type": "string"
                         }
@@ -186,52 +373,52 @@ const docTemplate = `{
                 }
             }
         },
-        "/ranges": {
+        "/devices/{id}/metrics": {
             "get": {
+                "description": "Samples are taken on every status poll answered over ICMP, oldest first.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get all saved IP ranges",
+                "summary": "Get latency and packet loss samples of a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only samples at or after this RFC3339 time (default 24h ago)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only samples at or before this RFC3339 time",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/model.IPRange"
+                                "$ref": "#/definitions/model.LatencySample"
                             }
                         }
-                    }
-                }
-            },
-            "post": {
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "summary": "Add a new IP range",
-                "parameters": [
-                    {
-                        "description": "IP Range",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.IPRange"
-                        }
-                    }
-                ],
-                "responses": {
-                    "201": {
-                        "description": "Created",
+                    },
+                    "400": {
+                        "description": "Invalid time range",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "400": {
-                        "description": "Invalid input",
+                    "404": {
+                        "description": "Not found",
                         "schema": {
                             "type": "string"
                         }
@@ -239,13 +426,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/ranges/{id}": {
-            "delete": {
-                "summary": "Delete an IP range by ID",
+        "/devices/{id}/ports": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get the port inventory of a device",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "IP Range ID",
+                        "description": "Device ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -253,9 +443,12 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Deleted",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Port"
+                            }
                         }
                     },
                     "404": {
@@ -267,102 +460,1875 @@ const docTemplate = `{
                 }
             }
         },
-        "/scan": {
+        "/devices/{id}/tags": {
             "post": {
-                "description": "Starts scanning the given CIDR range in the background",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Initiate a network scan",
+                "summary": "Add a tag to a device",
                 "parameters": [
                     {
-                        "description": "IP range to scan",
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tag to add",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.ScanRequest"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Accepted",
+                    "200": {
+                        "description": "Tag added",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Remove a tag from a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tag to remove",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tag removed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/type": {
+            "put": {
+                "description": "The type is kept across rescans until the override is removed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Override the type of a device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Device type",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.DeviceTypeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Device"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "The device is classified again from its attributes.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Remove a device type override",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Device"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/events": {
+            "get": {
+                "description": "Returns status transitions and attribute changes, newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List device events across all devices",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only events at or after this RFC3339 time",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only events at or before this RFC3339 time",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated event types, e.g. status_changed,discovered",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of events (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.DeviceEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/groups": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List device groups",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.DeviceGroup"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "A dynamic group saves a search query and always reflects current device state; a static group lists its member device IDs.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create a device group",
+                "parameters": [
+                    {
+                        "description": "Group",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.GroupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.DeviceGroup"
+                        }
                     },
                     "400": {
-                        "description": "Bad request",
+                        "description": "Invalid group or query",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/groups/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a device group by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.DeviceGroup"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
+            },
+            "put": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Replace a device group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Group",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.GroupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.DeviceGroup"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid group or query",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "summary": "Delete a device group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/groups/{id}/devices": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List the current members of a device group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Device"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/ranges": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get all saved IP ranges",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.IPRange"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Add a new IP range",
+                "parameters": [
+                    {
+                        "description": "IP Range",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.IPRange"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or target spec",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/ranges/{id}": {
+            "delete": {
+                "summary": "Delete an IP range by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "IP Range ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/availability": {
+            "get": {
+                "description": "Uptime percentage, outage count, longest outage and MTTR per device and per tag, computed from recorded status changes. A degraded device counts as the state it is leaving, so an outage lasts until the device is confirmed online again.",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "summary": "Device and tag availability report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start of the window, RFC3339 (default 30 days before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the window, RFC3339 (default now)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include devices with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (default) or csv",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.AvailabilityReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/scan": {
+            "post": {
+                "description": "Queues a scan job for the given targets (CIDRs, dash ranges or single hosts, comma separated) and/or the members of a device group, minus any exclusions, and returns it. Ports takes a profile (top20, top100), a list such as 22,80,8000-8010, or none.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Initiate a network scan",
+                "parameters": [
+                    {
+                        "description": "IP range to scan",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ScanRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/model.ScanJob"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or oversized range, unknown or empty group, or invalid ports",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/scans": {
+            "get": {
+                "description": "Returns all scan jobs, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List scan jobs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.ScanJob"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/scans/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a scan job by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Scan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ScanJob"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Cancels a queued or running scan and returns its final state",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cancel a scan job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Scan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ScanJob"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Scan already finished",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tag-rules": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List auto-tagging rules in evaluation order",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.TagRule"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "A rule adds its tags to every device matching all of its conditions after each scan and poll, and to all known devices when it is saved. Rules run by position; new rules go last unless a position is given.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create an auto-tagging rule",
+                "parameters": [
+                    {
+                        "description": "Rule",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.TagRuleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.TagRule"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid rule",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Rule saved but not applied",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tag-rules/dry-run": {
+            "post": {
+                "description": "Without a body the enabled rules are evaluated; with a rule in the body only that rule is. Nothing is written.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Show which devices the tag rules would change",
+                "parameters": [
+                    {
+                        "description": "Candidate rule",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.TagRuleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.TagRuleChange"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid rule",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tag-rules/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get an auto-tagging rule by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.TagRule"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Replace an auto-tagging rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Rule",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.TagRuleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.TagRule"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid rule",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Rule saved but not applied",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "summary": "Delete an auto-tagging rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tags": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List tags with the number of devices carrying each",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Tag"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Register a tag",
+                "parameters": [
+                    {
+                        "description": "Tag",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.TagRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.Tag"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid tag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Tag already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tags/{name}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a tag by name",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tag name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Tag"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Change the color and description of a tag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tag name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tag; name is ignored, use the rename endpoint",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.TagRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Tag"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid tag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "summary": "Delete a tag and remove it from every device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tag name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "A tag rule adds the tag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tags/{name}/merge": {
+            "post": {
+                "description": "Devices carrying the tag get the target tag instead and the tag is deleted. The target keeps its color and description.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Merge a tag into another one",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tag name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Target tag",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.MergeTagRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Tag"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid tag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "A tag rule adds the tag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tags/{name}/rename": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Rename a tag on every device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tag name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New name",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.RenameTagRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Tag"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid tag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Target tag already exists, merge instead, or a tag rule adds the tag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.BulkTagRequest": {
+            "type": "object",
+            "properties": {
+                "add": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "query": {
+                    "type": "string",
+                    "example": "manufacturer:Cisco* AND status:online"
+                },
+                "remove": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.DeviceTypeRequest": {
+            "type": "object",
+            "properties": {
+                "type": {
+                    "type": "string",
+                    "example": "printer"
+                }
+            }
+        },
+        "api.GroupRequest": {
+            "type": "object",
+            "properties": {
+                "device_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "offline printers"
+                },
+                "query": {
+                    "type": "string",
+                    "example": "tag:printer AND status:offline"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "dynamic",
+                        "static"
+                    ],
+                    "example": "dynamic"
+                }
+            }
+        },
+        "api.MergeTagRequest": {
+            "type": "object",
+            "properties": {
+                "into": {
+                    "type": "string",
+                    "example": "production"
+                }
+            }
+        },
+        "api.RenameTagRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "production"
+                }
+            }
+        },
+        "api.ScanRequest": {
+            "type": "object",
+            "properties": {
+                "exclude": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "group": {
+                    "description": "Group scans the members of a device group, by ID or name, in\naddition to IPRange.",
+                    "type": "string",
+                    "example": "offline printers"
+                },
+                "ip_range": {
+                    "type": "string",
+                    "example": "10.0.1.0/24,10.0.2.7"
+                },
+                "ports": {
+                    "type": "string",
+                    "example": "top100"
+                }
+            }
+        },
+        "api.TagRequest": {
+            "type": "object",
+            "properties": {
+                "color": {
+                    "type": "string",
+                    "example": "#e53935"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Production hosts"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "prod"
+                }
+            }
+        },
+        "api.TagRuleRequest": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "match": {
+                    "$ref": "#/definitions/model.RuleMatch"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "raspberry pis"
+                },
+                "position": {
+                    "type": "integer"
+                },
+                "stop": {
+                    "type": "boolean"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "iot"
+                    ]
+                }
+            }
+        },
+        "model.AvailabilityReport": {
+            "type": "object",
+            "properties": {
+                "devices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.DeviceAvailability"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.TagAvailability"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.BulkTagResult": {
+            "type": "object",
+            "properties": {
+                "matched": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.Device": {
+            "type": "object",
+            "properties": {
+                "address_history": {
+                    "description": "AddressHistory lists every IP the device has answered on.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.DeviceAddress"
+                    }
+                },
+                "device_type": {
+                    "description": "DeviceType is what the device was classified as, one of DeviceTypes,\nwith TypeConfidence in [0, 1]. TypeManual marks a user override the\nclassifier leaves alone.",
+                    "type": "string",
+                    "enum": [
+                        "router",
+                        "switch",
+                        "printer",
+                        "phone",
+                        "iot",
+                        "server",
+                        "workstation",
+                        "hypervisor",
+                        "camera"
+                    ]
+                },
+                "first_seen": {
+                    "type": "string"
+                },
+                "flap_count": {
+                    "description": "FlapCount is the number of online/offline transitions inside the\nconfigured flap window; Flapping is set once it reaches the threshold.",
+                    "type": "integer"
+                },
+                "flapping": {
+                    "type": "boolean"
+                },
+                "hostname": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip_address": {
+                    "type": "string"
+                },
+                "ipv6_addresses": {
+                    "description": "IPv6Addresses lists every IPv6 address seen for the device. IPAddress\nholds the IPv4 address when there is one, otherwise the first of these.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "last_seen": {
+                    "type": "string"
+                },
+                "liveness_method": {
+                    "description": "LivenessMethod names the probe that last proved the device online\n(\"icmp\", \"tcp\" or \"arp\").",
+                    "type": "string"
+                },
+                "mac_address": {
+                    "type": "string"
+                },
+                "manufacturer": {
+                    "type": "string"
+                },
+                "os": {
+                    "$ref": "#/definitions/model.OSGuess"
+                },
+                "ports": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Port"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type_confidence": {
+                    "type": "number"
+                },
+                "type_manual": {
+                    "type": "boolean"
+                }
             }
-        }
-    },
-    "definitions": {
-        "api.ScanRequest": {
+        },
+        "model.DeviceAddress": {
             "type": "object",
             "properties": {
-                "ip_range": {
+                "first_seen": {
+                    "type": "string"
+                },
+                "ip_address": {
+                    "type": "string"
+                },
+                "last_seen": {
                     "type": "string"
                 }
             }
         },
-        "model.Device": {
+        "model.DeviceAvailability": {
             "type": "object",
             "properties": {
-                "first_seen": {
+                "availability_percent": {
+                    "type": "number"
+                },
+                "device_id": {
                     "type": "string"
                 },
+                "downtime_seconds": {
+                    "type": "number"
+                },
                 "hostname": {
                     "type": "string"
                 },
+                "ip_address": {
+                    "type": "string"
+                },
+                "longest_outage_seconds": {
+                    "type": "number"
+                },
+                "monitored_seconds": {
+                    "type": "number"
+                },
+                "mttr_seconds": {
+                    "description": "MTTRSeconds is the mean duration of the outages that ended inside the\nwindow.",
+                    "type": "number"
+                },
+                "outages": {
+                    "type": "integer"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "uptime_seconds": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.DeviceEvent": {
+            "type": "object",
+            "properties": {
+                "device_id": {
+                    "type": "string"
+                },
                 "id": {
+                    "type": "integer"
+                },
+                "new_value": {
                     "type": "string"
                 },
-                "ip_address": {
+                "old_value": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.DeviceGroup": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "device_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "query": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "dynamic",
+                        "static"
+                    ]
+                }
+            }
+        },
+        "model.DevicePage": {
+            "type": "object",
+            "properties": {
+                "devices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Device"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.IPRange": {
+            "type": "object",
+            "properties": {
+                "exclude": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "range": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.LatencySample": {
+            "type": "object",
+            "properties": {
+                "device_id": {
+                    "type": "string"
+                },
+                "jitter_ms": {
+                    "description": "JitterMs is the mean difference between consecutive round-trip times.",
+                    "type": "number"
+                },
+                "loss_percent": {
+                    "type": "number"
+                },
+                "received": {
+                    "type": "integer"
+                },
+                "rtt_avg_ms": {
+                    "type": "number"
+                },
+                "rtt_max_ms": {
+                    "type": "number"
+                },
+                "rtt_min_ms": {
+                    "type": "number"
+                },
+                "sent": {
+                    "type": "integer"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.OSGuess": {
+            "type": "object",
+            "properties": {
+                "confidence": {
+                    "type": "number"
+                },
+                "evidence": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "family": {
+                    "type": "string",
+                    "enum": [
+                        "linux",
+                        "windows",
+                        "network",
+                        "embedded"
+                    ]
+                },
+                "ttl": {
+                    "description": "TTL is the TTL of the last echo reply, 0 if none was seen.",
+                    "type": "integer"
+                }
+            }
+        },
+        "model.Port": {
+            "type": "object",
+            "properties": {
+                "banner": {
+                    "type": "string"
+                },
+                "first_seen": {
                     "type": "string"
                 },
                 "last_seen": {
                     "type": "string"
                 },
-                "mac_address": {
+                "port": {
+                    "type": "integer"
+                },
+                "protocol": {
+                    "type": "string"
+                },
+                "service": {
+                    "description": "Service and Version identify what answered on an open port, e.g. \"ssh\"\nand \"OpenSSH_9.6p1\". Banner is the first line it sent, Title the\ntitle of an HTTP service's start page.",
+                    "type": "string",
+                    "example": "http"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "title": {
                     "type": "string"
                 },
+                "version": {
+                    "type": "string",
+                    "example": "nginx/1.24.0"
+                }
+            }
+        },
+        "model.RuleMatch": {
+            "type": "object",
+            "properties": {
+                "cidr": {
+                    "description": "CIDR matches the primary or any IPv6 address.",
+                    "type": "string",
+                    "example": "10.0.20.0/24"
+                },
+                "hostname": {
+                    "description": "Hostname is a regular expression.",
+                    "type": "string",
+                    "example": "^printer-"
+                },
+                "mac_prefix": {
+                    "type": "string",
+                    "example": "b8:27:eb"
+                },
                 "manufacturer": {
+                    "description": "Manufacturer matches case-insensitively anywhere in the vendor name.",
+                    "type": "string",
+                    "example": "Raspberry"
+                },
+                "ports": {
+                    "description": "Ports must all have been found open.",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "model.ScanJob": {
+            "type": "object",
+            "properties": {
+                "created_at": {
                     "type": "string"
                 },
-                "status": {
+                "error": {
                     "type": "string"
                 },
-                "tags": {
+                "exclude": {
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "group": {
+                    "type": "string"
+                },
+                "hosts_online": {
+                    "type": "integer"
+                },
+                "hosts_probed": {
+                    "type": "integer"
+                },
+                "hosts_total": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ports": {
+                    "type": "string"
+                },
+                "range": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
                 }
             }
         },
-        "model.IPRange": {
+        "model.Tag": {
+            "type": "object",
+            "properties": {
+                "color": {
+                    "type": "string",
+                    "example": "#e53935"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "device_count": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.TagAvailability": {
+            "type": "object",
+            "properties": {
+                "availability_percent": {
+                    "type": "number"
+                },
+                "devices": {
+                    "type": "integer"
+                },
+                "downtime_seconds": {
+                    "type": "number"
+                },
+                "longest_outage_seconds": {
+                    "type": "number"
+                },
+                "monitored_seconds": {
+                    "type": "number"
+                },
+                "mttr_seconds": {
+                    "description": "MTTRSeconds is the mean duration of the outages that ended inside the\nwindow.",
+                    "type": "number"
+                },
+                "outages": {
+                    "type": "integer"
+                },
+                "tag": {
+                    "type": "string"
+                },
+                "uptime_seconds": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.TagRule": {
             "type": "object",
             "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
                 "id": {
                     "type": "string"
                 },
+                "match": {
+                    "$ref": "#/definitions/model.RuleMatch"
+                },
                 "name": {
                     "type": "string"
                 },
-                "range": {
+                "position": {
+                    "type": "integer"
+                },
+                "stop": {
+                    "type": "boolean"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.TagRuleChange": {
+            "type": "object",
+            "properties": {
+                "add": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "device_id": {
+                    "type": "string"
+                },
+                "hostname": {
                     "type": "string"
+                },
+                "ip_address": {
+                    "type": "string"
+                },
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.VendorUpdate": {
+            "type": "object",
+            "properties": {
+                "devices_updated": {
+                    "type": "integer"
+                },
+                "prefixes": {
+                    "type": "integer"
                 }
             }
         }
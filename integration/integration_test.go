@@ -48,10 +48,12 @@ func TestScanAndGetDevicesIntegration(t *testing.T) {
 	for {
 		devicesResp, err := http.Get(server.URL + "/devices")
 		if err == nil && devicesResp.StatusCode == http.StatusOK {
-			var devices []map[string]interface{}
-			_ = json.NewDecoder(devicesResp.Body).Decode(&devices)
+			var page struct {
+				Devices []map[string]interface{} `json:"devices"`
+			}
+			_ = json.NewDecoder(devicesResp.Body).Decode(&page)
 			_ = devicesResp.Body.Close()
-			if len(devices) == 1 && devices[0]["ip_address"] == "127.0.0.1" {
+			if len(page.Devices) == 1 && page.Devices[0]["ip_address"] == "127.0.0.1" {
 				break
 			}
 		}
@@ -67,11 +69,15 @@ func TestScanAndGetDevicesIntegration(t *testing.T) {
 	}
 	defer resp.Body.Close()
 
-	var devices []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&devices); err != nil {
+	var page struct {
+		Devices []map[string]interface{} `json:"devices"`
+		Total   int                      `json:"total"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
-	if len(devices) != 1 {
+	devices := page.Devices
+	if len(devices) != 1 || page.Total != 1 {
 		t.Fatalf("expected 1 device, got %d", len(devices))
 	}
 	if devices[0]["ip_address"] != "127.0.0.1" {
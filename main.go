@@ -8,10 +8,13 @@ package main
 // @schemes http
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"network-scanner/api"
 	"network-scanner/config"
@@ -50,16 +53,53 @@ func main() {
 
 	userRepo := repository.NewSQLiteUserRepository(db, appLogger)
 	authHandler := api.NewAuthHandler(userRepo, appLogger)
-	resolver := service.NewOfflineManufacturerResolver("data/mac-vendors.csv")
-	scanner := service.NewScannerServiceWithResolver(deviceRepo, appLogger, resolver)
-	scanner.StartStatusPolling(5 * time.Second)
+	scannerCfg := service.DefaultScannerConfig()
+	if err := config.K.Unmarshal("scanner", &scannerCfg); err != nil {
+		appLogger.Error("Invalid scanner config, using defaults:", err)
+		scannerCfg = service.DefaultScannerConfig()
+	}
+	resolver, err := service.NewOfflineManufacturerResolver(scannerCfg.Vendors.Path, scannerCfg.Vendors.ImportDir)
+	if err != nil {
+		appLogger.Error("Failed to load MAC vendor database:", err)
+	}
+
+	scanRepo := repository.NewSQLiteScanRepository(db, appLogger)
+	portRepo := repository.NewSQLitePortRepository(db, appLogger)
+	addressRepo := repository.NewSQLiteAddressRepository(db, appLogger)
+	eventRepo := repository.NewSQLiteEventRepository(db, appLogger)
+	metricsRepo := repository.NewSQLiteMetricsRepository(db, appLogger)
+	groupRepo := repository.NewSQLiteGroupRepository(db, appLogger)
+	tagRepo := repository.NewSQLiteTagRepository(db, appLogger)
+	tagRuleRepo := repository.NewSQLiteTagRuleRepository(db, appLogger)
+	scanner := service.NewScannerServiceWithResolver(deviceRepo, appLogger, resolver).
+		WithConfig(scannerCfg).
+		WithScanRepository(scanRepo).
+		WithPortRepository(portRepo).
+		WithAddressRepository(addressRepo).
+		WithEventRepository(eventRepo).
+		WithMetricsRepository(metricsRepo).
+		WithGroupRepository(groupRepo).
+		WithTagRepository(tagRepo).
+		WithTagRuleRepository(tagRuleRepo)
+	scanner.LogMode()
+	scanner.StartStatusPolling()
+	go scanner.WatchVendors(context.Background())
+	go reloadVendorsOnHangup(scanner, appLogger)
 	scanHandler := api.NewScanHandler(scanner, appLogger)
 	deviceHandler := api.NewDeviceHandler(scanner, appLogger)
+	eventHandler := api.NewEventHandler(scanner, appLogger)
+	groupHandler := api.NewGroupHandler(scanner, appLogger)
+	vendorHandler := api.NewVendorHandler(scanner, appLogger)
+	tagHandler := api.NewTagHandler(scanner, appLogger)
+	tagRuleHandler := api.NewTagRuleHandler(scanner, appLogger)
 
 	rangeRepo := repository.NewSQLiteIPRangeRepository(db, appLogger)
 	rangeService := service.NewRangeService(rangeRepo)
 	rangeHandler := api.NewRangeHandler(rangeService, appLogger)
 
+	reportService := service.NewReportService(deviceRepo, eventRepo)
+	reportHandler := api.NewReportHandler(reportService, appLogger)
+
 	r := mux.NewRouter()
 
 	r.HandleFunc("/register", authHandler.Register).Methods("POST")
@@ -71,12 +111,45 @@ func main() {
 	})
 
 	protected.HandleFunc("/scan", scanHandler.StartScan).Methods("POST")
+	protected.HandleFunc("/scans", scanHandler.ListScans).Methods("GET")
+	protected.HandleFunc("/scans/{id}", scanHandler.GetScan).Methods("GET")
+	protected.HandleFunc("/scans/{id}", scanHandler.CancelScan).Methods("DELETE")
 	protected.HandleFunc("/devices", deviceHandler.GetDevices).Methods("GET")
 	protected.HandleFunc("/clear", deviceHandler.ClearDevices).Methods("DELETE")
 	protected.HandleFunc("/devices/search", deviceHandler.SearchDevices).Methods("GET")
+	protected.HandleFunc("/devices/tags", tagHandler.BulkTags).Methods("POST")
 	protected.HandleFunc("/devices/{id}", deviceHandler.GetDeviceByID).Methods("GET")
+	protected.HandleFunc("/devices/{id}/ports", deviceHandler.GetDevicePorts).Methods("GET")
+	protected.HandleFunc("/devices/{id}/addresses", deviceHandler.GetDeviceAddresses).Methods("GET")
+	protected.HandleFunc("/devices/{id}/history", deviceHandler.GetDeviceHistory).Methods("GET")
+	protected.HandleFunc("/devices/{id}/metrics", deviceHandler.GetDeviceMetrics).Methods("GET")
+	protected.HandleFunc("/events", eventHandler.ListEvents).Methods("GET")
 	protected.HandleFunc("/devices/{id}/tags", deviceHandler.AddTag).Methods("POST")
 	protected.HandleFunc("/devices/{id}/tags", deviceHandler.RemoveTag).Methods("DELETE")
+	protected.HandleFunc("/devices/{id}/type", deviceHandler.SetDeviceType).Methods("PUT")
+	protected.HandleFunc("/devices/{id}/type", deviceHandler.ClearDeviceType).Methods("DELETE")
+	protected.HandleFunc("/groups", groupHandler.ListGroups).Methods("GET")
+	protected.HandleFunc("/groups", groupHandler.CreateGroup).Methods("POST")
+	protected.HandleFunc("/groups/{id}", groupHandler.GetGroup).Methods("GET")
+	protected.HandleFunc("/groups/{id}", groupHandler.UpdateGroup).Methods("PUT")
+	protected.HandleFunc("/groups/{id}", groupHandler.DeleteGroup).Methods("DELETE")
+	protected.HandleFunc("/groups/{id}/devices", groupHandler.GetGroupDevices).Methods("GET")
+	protected.HandleFunc("/tags", tagHandler.ListTags).Methods("GET")
+	protected.HandleFunc("/tags", tagHandler.CreateTag).Methods("POST")
+	protected.HandleFunc("/tags/{name}", tagHandler.GetTag).Methods("GET")
+	protected.HandleFunc("/tags/{name}", tagHandler.UpdateTag).Methods("PUT")
+	protected.HandleFunc("/tags/{name}", tagHandler.DeleteTag).Methods("DELETE")
+	protected.HandleFunc("/tags/{name}/rename", tagHandler.RenameTag).Methods("POST")
+	protected.HandleFunc("/tags/{name}/merge", tagHandler.MergeTag).Methods("POST")
+	protected.HandleFunc("/tag-rules", tagRuleHandler.ListTagRules).Methods("GET")
+	protected.HandleFunc("/tag-rules", tagRuleHandler.CreateTagRule).Methods("POST")
+	protected.HandleFunc("/tag-rules/dry-run", tagRuleHandler.DryRunTagRules).Methods("POST")
+	protected.HandleFunc("/tag-rules/{id}", tagRuleHandler.GetTagRule).Methods("GET")
+	protected.HandleFunc("/tag-rules/{id}", tagRuleHandler.UpdateTagRule).Methods("PUT")
+	protected.HandleFunc("/tag-rules/{id}", tagRuleHandler.DeleteTagRule).Methods("DELETE")
+	protected.HandleFunc("/reports/availability", reportHandler.Availability).Methods("GET")
+	protected.HandleFunc("/admin/vendors/reload", vendorHandler.ReloadVendors).Methods("POST")
+	protected.HandleFunc("/admin/vendors/import", vendorHandler.ImportVendors).Methods("POST")
 	protected.HandleFunc("/ranges", rangeHandler.ListRanges).Methods("GET")
 	protected.HandleFunc("/ranges", rangeHandler.AddRange).Methods("POST")
 	protected.HandleFunc("/ranges/{id}", rangeHandler.DeleteRange).Methods("DELETE")
@@ -85,7 +158,7 @@ func main() {
 
 	corsOptions := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000"},
-		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Content-Type", "Authorization"},
 		AllowCredentials: true,
 	})
@@ -93,3 +166,17 @@ func main() {
 	appLogger.Info("Server listening on :8080")
 	log.Fatal(http.ListenAndServe(":8080", corsOptions.Handler(r)))
 }
+
+// reloadVendorsOnHangup reloads the MAC vendor database on every SIGHUP.
+func reloadVendorsOnHangup(scanner *service.ScannerService, appLogger logger.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		res, err := scanner.ReloadVendors()
+		if err != nil {
+			appLogger.Error("Failed to reload vendor database:", err)
+			continue
+		}
+		appLogger.Info("Vendor database reloaded: ", res.Prefixes, " prefixes, ", res.DevicesUpdated, " devices updated")
+	}
+}
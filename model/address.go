@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// DeviceAddress is one IP address a device has been seen at.
+type DeviceAddress struct {
+	IPAddress string    `json:"ip_address"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
@@ -2,14 +2,72 @@ package model
 
 import "time"
 
+const (
+	TypeRouter      = "router"
+	TypeSwitch      = "switch"
+	TypePrinter     = "printer"
+	TypePhone       = "phone"
+	TypeIoT         = "iot"
+	TypeServer      = "server"
+	TypeWorkstation = "workstation"
+	TypeHypervisor  = "hypervisor"
+	TypeCamera      = "camera"
+)
+
+// DeviceTypes lists the types a device can be classified as.
+var DeviceTypes = []string{
+	TypeRouter, TypeSwitch, TypePrinter, TypePhone, TypeIoT,
+	TypeServer, TypeWorkstation, TypeHypervisor, TypeCamera,
+}
+
+const (
+	OSLinux    = "linux"
+	OSWindows  = "windows"
+	OSNetwork  = "network"
+	OSEmbedded = "embedded"
+)
+
+// OSFamilies lists the operating system families a device can be guessed as.
+var OSFamilies = []string{OSLinux, OSWindows, OSNetwork, OSEmbedded}
+
+// OSGuess is the operating system family inferred from how a device's
+// network stack answered probes, with the observations it is based on.
+type OSGuess struct {
+	Family     string   `json:"family" enums:"linux,windows,network,embedded"`
+	Confidence float64  `json:"confidence"`
+	Evidence   []string `json:"evidence"`
+	// TTL is the TTL of the last echo reply, 0 if none was seen.
+	TTL int `json:"ttl,omitempty"`
+}
+
 type Device struct {
-	ID           string    `json:"id"`
-	IPAddress    string    `json:"ip_address"`
-	MACAddress   string    `json:"mac_address"`
-	Hostname     string    `json:"hostname"`
-	Status       string    `json:"status"`
-	Manufacturer string    `json:"manufacturer"`
-	Tags         []string  `json:"tags"`
-	LastSeen     time.Time `json:"last_seen"`
-	FirstSeen    time.Time `json:"first_seen"`
+	ID        string `json:"id"`
+	IPAddress string `json:"ip_address"`
+	// IPv6Addresses lists every IPv6 address seen for the device. IPAddress
+	// holds the IPv4 address when there is one, otherwise the first of these.
+	IPv6Addresses []string  `json:"ipv6_addresses,omitempty"`
+	MACAddress    string    `json:"mac_address"`
+	Hostname      string    `json:"hostname"`
+	Status        string    `json:"status"`
+	Manufacturer  string    `json:"manufacturer"`
+	Tags          []string  `json:"tags"`
+	LastSeen      time.Time `json:"last_seen"`
+	FirstSeen     time.Time `json:"first_seen"`
+	// LivenessMethod names the probe that last proved the device online
+	// ("icmp", "tcp" or "arp").
+	LivenessMethod string `json:"liveness_method,omitempty"`
+	// FlapCount is the number of online/offline transitions inside the
+	// configured flap window; Flapping is set once it reaches the threshold.
+	FlapCount int  `json:"flap_count"`
+	Flapping  bool `json:"flapping"`
+	// DeviceType is what the device was classified as, one of DeviceTypes,
+	// with TypeConfidence in [0, 1]. TypeManual marks a user override the
+	// classifier leaves alone.
+	DeviceType     string   `json:"device_type,omitempty" enums:"router,switch,printer,phone,iot,server,workstation,hypervisor,camera"`
+	TypeConfidence float64  `json:"type_confidence,omitempty"`
+	TypeManual     bool     `json:"type_manual,omitempty"`
+	OS             *OSGuess `json:"os,omitempty"`
+	Ports          []Port   `json:"ports,omitempty"`
+	// AddressHistory lists every IP the device has answered on.
+	AddressHistory []DeviceAddress `json:"address_history,omitempty"`
 }
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	EventDiscovered          = "discovered"
+	EventStatusChanged       = "status_changed"
+	EventHostnameChanged     = "hostname_changed"
+	EventMACChanged          = "mac_changed"
+	EventManufacturerChanged = "manufacturer_changed"
+	EventTagsChanged         = "tags_changed"
+	EventFlappingChanged     = "flapping_changed"
+	EventTypeChanged         = "type_changed"
+	EventOSChanged           = "os_changed"
+)
+
+// DeviceEvent is one entry of a device's append-only history.
+type DeviceEvent struct {
+	ID        int64     `json:"id"`
+	DeviceID  string    `json:"device_id"`
+	Type      string    `json:"type"`
+	OldValue  string    `json:"old_value,omitempty"`
+	NewValue  string    `json:"new_value,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+const (
+	GroupDynamic = "dynamic"
+	GroupStatic  = "static"
+)
+
+// DeviceGroup is a named set of devices. A dynamic group is a saved search
+// query evaluated against current state; a static group lists its members.
+type DeviceGroup struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type" enums:"dynamic,static"`
+	Query     string    `json:"query,omitempty"`
+	DeviceIDs []string  `json:"device_ids,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
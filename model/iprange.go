@@ -1,7 +1,8 @@
 package model
 
 type IPRange struct {
-	ID    string `json:"id,omitempty"`
-	Name  string `json:"name"`
-	Range string `json:"range"`
+	ID      string   `json:"id,omitempty"`
+	Name    string   `json:"name"`
+	Range   string   `json:"range"`
+	Exclude []string `json:"exclude,omitempty"`
 }
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// LatencySample summarises the echo requests sent to a device during one
+// status poll. RTT fields are zero when no reply came back.
+type LatencySample struct {
+	DeviceID    string    `json:"device_id"`
+	Timestamp   time.Time `json:"timestamp"`
+	Sent        int       `json:"sent"`
+	Received    int       `json:"received"`
+	LossPercent float64   `json:"loss_percent"`
+	RTTMinMs    float64   `json:"rtt_min_ms"`
+	RTTAvgMs    float64   `json:"rtt_avg_ms"`
+	RTTMaxMs    float64   `json:"rtt_max_ms"`
+	// JitterMs is the mean difference between consecutive round-trip times.
+	JitterMs float64 `json:"jitter_ms"`
+}
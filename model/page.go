@@ -0,0 +1,9 @@
+package model
+
+// DevicePage is one page of a device listing. NextCursor is empty on the
+// last page.
+type DevicePage struct {
+	Devices    []Device `json:"devices"`
+	Total      int      `json:"total"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+const (
+	PortOpen   = "open"
+	PortClosed = "closed"
+)
+
+type Port struct {
+	Port      int       `json:"port"`
+	Protocol  string    `json:"protocol"`
+	State     string    `json:"state"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Service and Version identify what answered on an open port, e.g. "ssh"
+	// and "OpenSSH_9.6p1". Banner is the first line it sent, Title the
+	// title of an HTTP service's start page.
+	Service string `json:"service,omitempty" example:"http"`
+	Version string `json:"version,omitempty" example:"nginx/1.24.0"`
+	Banner  string `json:"banner,omitempty"`
+	Title   string `json:"title,omitempty"`
+}
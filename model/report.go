@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// AvailabilityStats summarises the status history of one or more devices over
+// a report window. Only time during which a device's status was known counts
+// as monitored; "degraded" counts as up.
+type AvailabilityStats struct {
+	MonitoredSeconds     float64 `json:"monitored_seconds"`
+	UptimeSeconds        float64 `json:"uptime_seconds"`
+	DowntimeSeconds      float64 `json:"downtime_seconds"`
+	AvailabilityPercent  float64 `json:"availability_percent"`
+	Outages              int     `json:"outages"`
+	LongestOutageSeconds float64 `json:"longest_outage_seconds"`
+	// MTTRSeconds is the mean duration of the outages that ended inside the
+	// window.
+	MTTRSeconds float64 `json:"mttr_seconds"`
+}
+
+type DeviceAvailability struct {
+	DeviceID  string   `json:"device_id"`
+	IPAddress string   `json:"ip_address"`
+	Hostname  string   `json:"hostname"`
+	Tags      []string `json:"tags"`
+	AvailabilityStats
+}
+
+type TagAvailability struct {
+	Tag     string `json:"tag"`
+	Devices int    `json:"devices"`
+	AvailabilityStats
+}
+
+type AvailabilityReport struct {
+	From    time.Time            `json:"from"`
+	To      time.Time            `json:"to"`
+	Devices []DeviceAvailability `json:"devices"`
+	Tags    []TagAvailability    `json:"tags"`
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// TagRule adds Tags to every device matching all of its set conditions.
+// Rules run in Position order after scans and polls; a matching rule with
+// Stop set ends evaluation for that device.
+type TagRule struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	Enabled   bool      `json:"enabled"`
+	Match     RuleMatch `json:"match"`
+	Tags      []string  `json:"tags"`
+	Stop      bool      `json:"stop,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RuleMatch holds the conditions of a tag rule. Empty fields are ignored.
+type RuleMatch struct {
+	// Manufacturer matches case-insensitively anywhere in the vendor name.
+	Manufacturer string `json:"manufacturer,omitempty" example:"Raspberry"`
+	// CIDR matches the primary or any IPv6 address.
+	CIDR string `json:"cidr,omitempty" example:"10.0.20.0/24"`
+	// Hostname is a regular expression.
+	Hostname string `json:"hostname,omitempty" example:"^printer-"`
+	// Ports must all have been found open.
+	Ports     []int  `json:"ports,omitempty"`
+	MACPrefix string `json:"mac_prefix,omitempty" example:"b8:27:eb"`
+}
+
+// TagRuleChange describes the tags rules would add to a device.
+type TagRuleChange struct {
+	DeviceID  string   `json:"device_id"`
+	IPAddress string   `json:"ip_address"`
+	Hostname  string   `json:"hostname"`
+	Tags      []string `json:"tags"`
+	Add       []string `json:"add"`
+	Rules     []string `json:"rules"`
+}
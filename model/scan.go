@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+const (
+	ScanQueued    = "queued"
+	ScanRunning   = "running"
+	ScanCancelled = "cancelled"
+	ScanFailed    = "failed"
+	ScanCompleted = "completed"
+)
+
+type ScanJob struct {
+	ID          string    `json:"id"`
+	Range       string    `json:"range"`
+	Exclude     []string  `json:"exclude,omitempty"`
+	Ports       string    `json:"ports,omitempty"`
+	Group       string    `json:"group,omitempty"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	HostsTotal  int       `json:"hosts_total"`
+	HostsProbed int       `json:"hosts_probed"`
+	HostsOnline int       `json:"hosts_online"`
+	CreatedAt   time.Time `json:"created_at"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+func (j ScanJob) Finished() bool {
+	return j.Status == ScanCancelled || j.Status == ScanFailed || j.Status == ScanCompleted
+}
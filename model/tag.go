@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Tag is a registered device tag. Devices still carry their tags by name;
+// DeviceCount is computed when tags are listed.
+type Tag struct {
+	Name        string    `json:"name"`
+	Color       string    `json:"color,omitempty" example:"#e53935"`
+	Description string    `json:"description,omitempty"`
+	DeviceCount int       `json:"device_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BulkTagResult reports how many devices a bulk tag operation selected and
+// how many of them actually changed.
+type BulkTagResult struct {
+	Matched int `json:"matched"`
+	Updated int `json:"updated"`
+}
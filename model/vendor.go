@@ -0,0 +1,8 @@
+package model
+
+// VendorUpdate reports the outcome of reloading or importing the vendor
+// database.
+type VendorUpdate struct {
+	Prefixes       int `json:"prefixes"`
+	DevicesUpdated int `json:"devices_updated"`
+}
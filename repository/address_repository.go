@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"network-scanner/model"
+	"time"
+)
+
+type AddressRepository interface {
+	// RecordAddress notes that a device answered on ip at the given time.
+	RecordAddress(deviceID, ip string, seen time.Time) error
+	// FindByDevice returns the address history of a device, most recently
+	// seen first.
+	FindByDevice(deviceID string) ([]model.DeviceAddress, error)
+	// Reassign moves the history of one device onto another, used when two
+	// records turn out to be the same device.
+	Reassign(fromID, toID string) error
+	Clear() error
+}
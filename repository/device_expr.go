@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"net/netip"
+	"network-scanner/model"
+	"strings"
+	"time"
+)
+
+// Expr is a compiled device search expression. SQLiteRepository turns it
+// into a WHERE clause and InMemoryRepository evaluates it per device; both
+// must agree on every node.
+type Expr interface {
+	match(d model.Device) bool
+	sql() (string, []any)
+}
+
+type AndExpr struct{ Left, Right Expr }
+type OrExpr struct{ Left, Right Expr }
+type NotExpr struct{ X Expr }
+
+// TextExpr matches a text field against a case-insensitive glob where "*"
+// stands for any run of characters. An empty Field searches the address,
+// hostname, MAC, manufacturer and every tag.
+type TextExpr struct {
+	Field   string
+	Pattern string
+}
+
+// Text fields a TextExpr can name.
+const (
+	FieldID           = "id"
+	FieldHostname     = "hostname"
+	FieldMAC          = "mac_address"
+	FieldManufacturer = "manufacturer"
+	FieldStatus       = "status"
+	FieldTag          = "tag"
+	FieldIP           = "ip_address"
+	FieldType         = "device_type"
+)
+
+// PrefixExpr matches devices whose primary address is inside Prefix.
+type PrefixExpr struct{ Prefix netip.Prefix }
+
+// TimeExpr compares first_seen or last_seen with an absolute time. Op is one
+// of "<", "<=", ">" and ">=".
+type TimeExpr struct {
+	Field string
+	Op    string
+	T     time.Time
+}
+
+func (e AndExpr) match(d model.Device) bool { return e.Left.match(d) && e.Right.match(d) }
+func (e OrExpr) match(d model.Device) bool  { return e.Left.match(d) || e.Right.match(d) }
+func (e NotExpr) match(d model.Device) bool { return !e.X.match(d) }
+
+func (e AndExpr) sql() (string, []any) { return binarySQL(e.Left, "AND", e.Right) }
+func (e OrExpr) sql() (string, []any)  { return binarySQL(e.Left, "OR", e.Right) }
+func (e NotExpr) sql() (string, []any) {
+	s, args := e.X.sql()
+	return "NOT (" + s + ")", args
+}
+
+func binarySQL(l Expr, op string, r Expr) (string, []any) {
+	ls, la := l.sql()
+	rs, ra := r.sql()
+	return "(" + ls + ") " + op + " (" + rs + ")", append(la, ra...)
+}
+
+func (e TextExpr) match(d model.Device) bool {
+	switch e.Field {
+	case FieldID:
+		return globMatch(e.Pattern, d.ID)
+	case FieldHostname:
+		return globMatch(e.Pattern, d.Hostname)
+	case FieldMAC:
+		return globMatch(e.Pattern, d.MACAddress)
+	case FieldManufacturer:
+		return globMatch(e.Pattern, d.Manufacturer)
+	case FieldStatus:
+		return globMatch(e.Pattern, d.Status)
+	case FieldType:
+		return globMatch(e.Pattern, d.DeviceType)
+	case FieldIP:
+		return globMatch(e.Pattern, d.IPAddress)
+	case FieldTag:
+		return e.anyTag(d)
+	}
+	return globMatch(e.Pattern, d.IPAddress) || globMatch(e.Pattern, d.Hostname) ||
+		globMatch(e.Pattern, d.MACAddress) || globMatch(e.Pattern, d.Manufacturer) || e.anyTag(d)
+}
+
+func (e TextExpr) anyTag(d model.Device) bool {
+	for _, t := range d.Tags {
+		if globMatch(e.Pattern, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// textColumns maps text fields onto their columns. Tags live in a JSON array
+// and are matched element by element.
+var textColumns = map[string]string{
+	FieldID:           "id",
+	FieldHostname:     "COALESCE(hostname, '')",
+	FieldMAC:          "COALESCE(mac_address, '')",
+	FieldManufacturer: "COALESCE(manufacturer, '')",
+	FieldStatus:       "COALESCE(status, '')",
+	FieldType:         "device_type",
+	FieldIP:           "ip_address",
+}
+
+const tagLikeSQL = `EXISTS (SELECT 1 FROM json_each(devices.tags) WHERE json_each.value LIKE ? ESCAPE '\')`
+
+func (e TextExpr) sql() (string, []any) {
+	like := globToLike(e.Pattern)
+	if e.Field == FieldTag {
+		return tagLikeSQL, []any{like}
+	}
+	if col, ok := textColumns[e.Field]; ok {
+		return col + ` LIKE ? ESCAPE '\'`, []any{like}
+	}
+	var parts []string
+	var args []any
+	for _, f := range []string{FieldIP, FieldHostname, FieldMAC, FieldManufacturer} {
+		parts = append(parts, textColumns[f]+` LIKE ? ESCAPE '\'`)
+		args = append(args, like)
+	}
+	parts = append(parts, tagLikeSQL)
+	return strings.Join(parts, " OR "), append(args, like)
+}
+
+func (e PrefixExpr) match(d model.Device) bool {
+	addr, err := netip.ParseAddr(d.IPAddress)
+	return err == nil && e.Prefix.Contains(addr.Unmap())
+}
+
+func (e PrefixExpr) sql() (string, []any) {
+	lo, hi := prefixKeys(e.Prefix)
+	return "ip_key BETWEEN ? AND ?", []any{lo, hi}
+}
+
+func (e TimeExpr) value(d model.Device) time.Time {
+	if e.Field == "first_seen" {
+		return d.FirstSeen
+	}
+	return d.LastSeen
+}
+
+// Times are compared at the one-second precision SQLite stores them with.
+func (e TimeExpr) match(d model.Device) bool {
+	c := e.value(d).UTC().Truncate(time.Second).Compare(e.T.UTC().Truncate(time.Second))
+	switch e.Op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+func (e TimeExpr) sql() (string, []any) {
+	col := "last_seen"
+	if e.Field == "first_seen" {
+		col = "first_seen"
+	}
+	op := ">="
+	switch e.Op {
+	case "<", "<=", ">":
+		op = e.Op
+	}
+	return col + " " + op + " ?", []any{e.T.UTC().Format(time.RFC3339)}
+}
+
+// globMatch reports whether s matches pattern case-insensitively, where "*"
+// matches any run of characters, the way SQLite's LIKE does with "%".
+func globMatch(pattern, s string) bool {
+	pattern, s = strings.ToLower(pattern), strings.ToLower(s)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, p := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, p)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(p):]
+	}
+	return strings.HasSuffix(s, last)
+}
+
+func globToLike(pattern string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return r.Replace(pattern)
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"network-scanner/model"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort field")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// DeviceQuery filters, sorts and pages a device listing. Zero values match
+// everything; a zero Limit returns all matching devices.
+type DeviceQuery struct {
+	Status string
+	Tag    string
+	Type   string
+	// Manufacturer matches case-insensitively anywhere in the name.
+	Manufacturer string
+	SeenSince    time.Time
+	// CIDR matches the primary address of a device.
+	CIDR netip.Prefix
+	// Match is a search expression the devices must also satisfy.
+	Match Expr
+
+	// Sort is one of DeviceSortFields, "ip_address" by default. Ties are
+	// broken by device ID.
+	Sort   string
+	Desc   bool
+	Limit  int
+	Cursor string
+}
+
+// deviceSortColumns maps the sortable fields onto their SQL expressions.
+// ip_address sorts by ip_key so addresses compare numerically.
+var deviceSortColumns = map[string]string{
+	"ip_address":   "ip_key",
+	"hostname":     "COALESCE(hostname, '')",
+	"mac_address":  "COALESCE(mac_address, '')",
+	"manufacturer": "COALESCE(manufacturer, '')",
+	"status":       "COALESCE(status, '')",
+	"first_seen":   "first_seen",
+	"last_seen":    "last_seen",
+}
+
+// DeviceSortFields lists the fields a device listing can be sorted by.
+func DeviceSortFields() []string {
+	fields := make([]string, 0, len(deviceSortColumns))
+	for f := range deviceSortColumns {
+		fields = append(fields, f)
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+func (q DeviceQuery) sortField() (string, error) {
+	if q.Sort == "" {
+		return "ip_address", nil
+	}
+	if _, ok := deviceSortColumns[q.Sort]; !ok {
+		return "", ErrInvalidSort
+	}
+	return q.Sort, nil
+}
+
+// deviceCursor is the position after the last device of a page: its sort key
+// and ID.
+type deviceCursor struct {
+	Key string `json:"k"`
+	ID  string `json:"id"`
+}
+
+func encodeCursor(c deviceCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*deviceCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c deviceCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// sortKey is the value a device is ordered by, in the same form SQLite
+// compares it.
+func sortKey(d model.Device, field string) string {
+	switch field {
+	case "hostname":
+		return d.Hostname
+	case "mac_address":
+		return d.MACAddress
+	case "manufacturer":
+		return d.Manufacturer
+	case "status":
+		return d.Status
+	case "first_seen":
+		return d.FirstSeen.UTC().Format(time.RFC3339)
+	case "last_seen":
+		return d.LastSeen.UTC().Format(time.RFC3339)
+	}
+	return ipKey(d.IPAddress)
+}
+
+// ipKey encodes an address as 32 hex digits of its 16-byte form, so string
+// order is numeric order and IPv4 addresses fall into ::ffff:0:0/96.
+func ipKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	b := addr.As16()
+	return hex.EncodeToString(b[:])
+}
+
+// prefixKeys returns the lowest and highest ipKey inside p.
+func prefixKeys(p netip.Prefix) (string, string) {
+	p = p.Masked()
+	bits := p.Bits()
+	if p.Addr().Is4() {
+		bits += 96
+	}
+	lo := p.Addr().As16()
+	hi := lo
+	for i := bits; i < 128; i++ {
+		hi[i/8] |= 1 << (7 - i%8)
+	}
+	return hex.EncodeToString(lo[:]), hex.EncodeToString(hi[:])
+}
+
+func cidrExpr(p netip.Prefix) Expr {
+	if !p.IsValid() {
+		return nil
+	}
+	return PrefixExpr{p}
+}
+
+func (q DeviceQuery) matches(d model.Device) bool {
+	if q.Status != "" && d.Status != q.Status {
+		return false
+	}
+	if q.Tag != "" && !slices.Contains(d.Tags, q.Tag) {
+		return false
+	}
+	if q.Type != "" && d.DeviceType != q.Type {
+		return false
+	}
+	if q.Manufacturer != "" && !strings.Contains(strings.ToLower(d.Manufacturer), strings.ToLower(q.Manufacturer)) {
+		return false
+	}
+	if !q.SeenSince.IsZero() && d.LastSeen.Before(q.SeenSince) {
+		return false
+	}
+	if q.CIDR.IsValid() && !(PrefixExpr{q.CIDR}).match(d) {
+		return false
+	}
+	return q.Match == nil || q.Match.match(d)
+}
+
+// listDevices applies q to an unordered set of devices.
+func listDevices(all []model.Device, q DeviceQuery) (model.DevicePage, error) {
+	page := model.DevicePage{Devices: []model.Device{}}
+	field, err := q.sortField()
+	if err != nil {
+		return page, err
+	}
+	cursor, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return page, err
+	}
+
+	var matched []model.Device
+	for _, d := range all {
+		if q.matches(d) {
+			matched = append(matched, d)
+		}
+	}
+	page.Total = len(matched)
+
+	// order compares a device with a position given by sort key and ID.
+	order := func(d model.Device, key, id string) int {
+		c := strings.Compare(sortKey(d, field), key)
+		if c == 0 {
+			c = strings.Compare(d.ID, id)
+		}
+		if q.Desc {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(matched, func(a, b model.Device) int { return order(a, sortKey(b, field), b.ID) })
+	if cursor != nil {
+		matched = slices.DeleteFunc(matched, func(d model.Device) bool { return order(d, cursor.Key, cursor.ID) <= 0 })
+	}
+
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+		last := matched[len(matched)-1]
+		page.NextCursor = encodeCursor(deviceCursor{Key: sortKey(last, field), ID: last.ID})
+	}
+	page.Devices = append(page.Devices, matched...)
+	return page, nil
+}
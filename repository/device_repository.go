@@ -4,11 +4,23 @@ import "network-scanner/model"
 
 type DeviceRepository interface {
 	Save(device model.Device)
+	// SaveBatch stores several devices in one transaction.
+	SaveBatch(devices []model.Device) error
 	GetAll() []model.Device
 	Clear()
+	// FindByIP matches the primary address as well as any IPv6 address.
 	FindByIP(ip string) *model.Device
+	FindByMAC(mac string) *model.Device
+	Delete(id string) error
 
 	FindByID(id string) (*model.Device, error)
 	UpdateTags(id string, tags []string) error
+	// SetTags replaces the tags of several devices, keyed by device ID, in
+	// one transaction.
+	SetTags(tags map[string][]string) error
+	// TagCounts returns how many devices carry each tag.
+	TagCounts() (map[string]int, error)
 	Search(query string) ([]model.Device, error)
+	// List returns one page of the devices matching q.
+	List(q DeviceQuery) (model.DevicePage, error)
 }
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"network-scanner/model"
+	"time"
+)
+
+// EventFilter narrows an event query. Zero values match everything.
+type EventFilter struct {
+	DeviceID string
+	Types    []string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+type EventRepository interface {
+	Append(events ...model.DeviceEvent) error
+	// Find returns matching events, newest first.
+	Find(f EventFilter) ([]model.DeviceEvent, error)
+	Reassign(fromID, toID string) error
+	Clear() error
+}
@@ -0,0 +1,12 @@
+package repository
+
+import "network-scanner/model"
+
+type GroupRepository interface {
+	Save(g model.DeviceGroup) error
+	GetAll() ([]model.DeviceGroup, error)
+	FindByID(id string) (*model.DeviceGroup, error)
+	Delete(id string) error
+	// Reassign moves static memberships from one device to another.
+	Reassign(fromID, toID string) error
+}
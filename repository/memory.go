@@ -2,6 +2,7 @@ package repository
 
 import (
 	"network-scanner/model"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -29,16 +30,30 @@ func (r *InMemoryRepository) Save(device model.Device) {
 		device.ID = id
 	}
 
-	if old, ok := r.byID[id]; ok && old.IPAddress != device.IPAddress && old.IPAddress != "" {
-		delete(r.ipIndex, old.IPAddress)
+	if old, ok := r.byID[id]; ok {
+		for _, ip := range append([]string{old.IPAddress}, old.IPv6Addresses...) {
+			if r.ipIndex[ip] == id {
+				delete(r.ipIndex, ip)
+			}
+		}
 	}
 
 	r.byID[id] = device
+	for _, ip := range device.IPv6Addresses {
+		r.ipIndex[ip] = id
+	}
 	if device.IPAddress != "" {
 		r.ipIndex[device.IPAddress] = id
 	}
 }
 
+func (r *InMemoryRepository) SaveBatch(devices []model.Device) error {
+	for _, d := range devices {
+		r.Save(d)
+	}
+	return nil
+}
+
 func (r *InMemoryRepository) GetAll() []model.Device {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -68,6 +83,33 @@ func (r *InMemoryRepository) FindByIP(ip string) *model.Device {
 	return nil
 }
 
+func (r *InMemoryRepository) FindByMAC(mac string) *model.Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if mac == "" {
+		return nil
+	}
+	for _, d := range r.byID {
+		if strings.EqualFold(d.MACAddress, mac) {
+			dd := d
+			return &dd
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for ip, owner := range r.ipIndex {
+		if owner == id {
+			delete(r.ipIndex, ip)
+		}
+	}
+	delete(r.byID, id)
+	return nil
+}
+
 func (r *InMemoryRepository) FindByID(id string) (*model.Device, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -104,6 +146,34 @@ func (r *InMemoryRepository) UpdateTags(id string, tags []string) error {
 	return nil
 }
 
+func (r *InMemoryRepository) SetTags(tags map[string][]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, t := range tags {
+		if d, ok := r.byID[id]; ok {
+			d.Tags = slices.Clone(t)
+			r.byID[id] = d
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryRepository) TagCounts() (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, d := range r.byID {
+		for _, t := range d.Tags {
+			counts[t]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *InMemoryRepository) List(q DeviceQuery) (model.DevicePage, error) {
+	return listDevices(r.GetAll(), q)
+}
+
 func (r *InMemoryRepository) Search(q string) ([]model.Device, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"network-scanner/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+type InMemoryAddressRepository struct {
+	mu       sync.RWMutex
+	byDevice map[string]map[string]model.DeviceAddress
+}
+
+func NewInMemoryAddressRepository() *InMemoryAddressRepository {
+	return &InMemoryAddressRepository{byDevice: make(map[string]map[string]model.DeviceAddress)}
+}
+
+func (r *InMemoryAddressRepository) RecordAddress(deviceID, ip string, seen time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record(deviceID, model.DeviceAddress{IPAddress: ip, FirstSeen: seen, LastSeen: seen})
+	return nil
+}
+
+func (r *InMemoryAddressRepository) record(deviceID string, a model.DeviceAddress) {
+	m, ok := r.byDevice[deviceID]
+	if !ok {
+		m = make(map[string]model.DeviceAddress)
+		r.byDevice[deviceID] = m
+	}
+	if old, ok := m[a.IPAddress]; ok {
+		if old.FirstSeen.Before(a.FirstSeen) {
+			a.FirstSeen = old.FirstSeen
+		}
+		if old.LastSeen.After(a.LastSeen) {
+			a.LastSeen = old.LastSeen
+		}
+	}
+	m[a.IPAddress] = a
+}
+
+func (r *InMemoryAddressRepository) FindByDevice(deviceID string) ([]model.DeviceAddress, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.DeviceAddress, 0, len(r.byDevice[deviceID]))
+	for _, a := range r.byDevice[deviceID] {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if !out[i].LastSeen.Equal(out[k].LastSeen) {
+			return out[i].LastSeen.After(out[k].LastSeen)
+		}
+		return out[i].IPAddress < out[k].IPAddress
+	})
+	return out, nil
+}
+
+func (r *InMemoryAddressRepository) Reassign(fromID, toID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, a := range r.byDevice[fromID] {
+		r.record(toID, a)
+	}
+	delete(r.byDevice, fromID)
+	return nil
+}
+
+func (r *InMemoryAddressRepository) Clear() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byDevice = make(map[string]map[string]model.DeviceAddress)
+	return nil
+}
+
+var _ AddressRepository = (*InMemoryAddressRepository)(nil)
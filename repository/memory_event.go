@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"network-scanner/model"
+	"slices"
+	"sync"
+)
+
+type InMemoryEventRepository struct {
+	mu     sync.RWMutex
+	events []model.DeviceEvent
+	nextID int64
+}
+
+func NewInMemoryEventRepository() *InMemoryEventRepository {
+	return &InMemoryEventRepository{}
+}
+
+func (r *InMemoryEventRepository) Append(events ...model.DeviceEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range events {
+		r.nextID++
+		e.ID = r.nextID
+		r.events = append(r.events, e)
+	}
+	return nil
+}
+
+func (r *InMemoryEventRepository) Find(f EventFilter) ([]model.DeviceEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []model.DeviceEvent{}
+	for i := len(r.events) - 1; i >= 0; i-- {
+		e := r.events[i]
+		if f.DeviceID != "" && e.DeviceID != f.DeviceID {
+			continue
+		}
+		if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+			continue
+		}
+		if !f.From.IsZero() && e.Timestamp.Before(f.From) {
+			continue
+		}
+		if !f.To.IsZero() && e.Timestamp.After(f.To) {
+			continue
+		}
+		out = append(out, e)
+	}
+	slices.SortStableFunc(out, func(a, b model.DeviceEvent) int { return b.Timestamp.Compare(a.Timestamp) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+func (r *InMemoryEventRepository) Reassign(fromID, toID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.events {
+		if r.events[i].DeviceID == fromID {
+			r.events[i].DeviceID = toID
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryEventRepository) Clear() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+	return nil
+}
+
+var _ EventRepository = (*InMemoryEventRepository)(nil)
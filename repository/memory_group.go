@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"network-scanner/model"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type InMemoryGroupRepository struct {
+	mu   sync.RWMutex
+	byID map[string]model.DeviceGroup
+}
+
+func NewInMemoryGroupRepository() *InMemoryGroupRepository {
+	return &InMemoryGroupRepository{byID: make(map[string]model.DeviceGroup)}
+}
+
+func (r *InMemoryGroupRepository) Save(g model.DeviceGroup) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g.DeviceIDs = slices.Clone(g.DeviceIDs)
+	r.byID[g.ID] = g
+	return nil
+}
+
+func (r *InMemoryGroupRepository) GetAll() ([]model.DeviceGroup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.DeviceGroup, 0, len(r.byID))
+	for _, g := range r.byID {
+		g.DeviceIDs = slices.Clone(g.DeviceIDs)
+		out = append(out, g)
+	}
+	slices.SortFunc(out, func(a, b model.DeviceGroup) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+func (r *InMemoryGroupRepository) FindByID(id string) (*model.DeviceGroup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if g, ok := r.byID[id]; ok {
+		g.DeviceIDs = slices.Clone(g.DeviceIDs)
+		return &g, nil
+	}
+	return nil, nil
+}
+
+func (r *InMemoryGroupRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byID, id)
+	return nil
+}
+
+func (r *InMemoryGroupRepository) Reassign(fromID, toID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, g := range r.byID {
+		if ids, ok := reassignMember(g.DeviceIDs, fromID, toID); ok {
+			g.DeviceIDs = ids
+			r.byID[id] = g
+		}
+	}
+	return nil
+}
+
+// reassignMember replaces fromID with toID in a member list, dropping the
+// duplicate if toID was already a member.
+func reassignMember(ids []string, fromID, toID string) ([]string, bool) {
+	i := slices.Index(ids, fromID)
+	if i < 0 {
+		return ids, false
+	}
+	ids = slices.Clone(ids)
+	if slices.Contains(ids, toID) {
+		return slices.Delete(ids, i, i+1), true
+	}
+	ids[i] = toID
+	return ids, true
+}
+
+var _ GroupRepository = (*InMemoryGroupRepository)(nil)
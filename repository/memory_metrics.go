@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"network-scanner/model"
+	"slices"
+	"sync"
+	"time"
+)
+
+type InMemoryMetricsRepository struct {
+	mu      sync.RWMutex
+	samples []model.LatencySample
+}
+
+func NewInMemoryMetricsRepository() *InMemoryMetricsRepository {
+	return &InMemoryMetricsRepository{}
+}
+
+func (r *InMemoryMetricsRepository) Append(samples ...model.LatencySample) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = append(r.samples, samples...)
+	return nil
+}
+
+func (r *InMemoryMetricsRepository) Find(deviceID string, from, to time.Time) ([]model.LatencySample, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []model.LatencySample{}
+	for _, s := range r.samples {
+		if s.DeviceID != deviceID {
+			continue
+		}
+		if !from.IsZero() && s.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && s.Timestamp.After(to) {
+			continue
+		}
+		out = append(out, s)
+	}
+	slices.SortStableFunc(out, func(a, b model.LatencySample) int { return a.Timestamp.Compare(b.Timestamp) })
+	return out, nil
+}
+
+func (r *InMemoryMetricsRepository) Prune(before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = slices.DeleteFunc(r.samples, func(s model.LatencySample) bool { return s.Timestamp.Before(before) })
+	return nil
+}
+
+func (r *InMemoryMetricsRepository) Reassign(fromID, toID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.samples {
+		if r.samples[i].DeviceID == fromID {
+			r.samples[i].DeviceID = toID
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryMetricsRepository) Clear() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = nil
+	return nil
+}
+
+var _ MetricsRepository = (*InMemoryMetricsRepository)(nil)
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"network-scanner/model"
+	"sort"
+	"sync"
+)
+
+type portKey struct {
+	port     int
+	protocol string
+}
+
+type InMemoryPortRepository struct {
+	mu       sync.RWMutex
+	byDevice map[string]map[portKey]model.Port
+}
+
+func NewInMemoryPortRepository() *InMemoryPortRepository {
+	return &InMemoryPortRepository{byDevice: make(map[string]map[portKey]model.Port)}
+}
+
+func (r *InMemoryPortRepository) SavePorts(deviceID string, ports []model.Port) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.byDevice[deviceID]
+	if !ok {
+		m = make(map[portKey]model.Port)
+		r.byDevice[deviceID] = m
+	}
+	for _, p := range ports {
+		m[portKey{p.Port, p.Protocol}] = p
+	}
+	return nil
+}
+
+func (r *InMemoryPortRepository) FindByDevice(deviceID string) ([]model.Port, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.Port, 0, len(r.byDevice[deviceID]))
+	for _, p := range r.byDevice[deviceID] {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].Port != out[k].Port {
+			return out[i].Port < out[k].Port
+		}
+		return out[i].Protocol < out[k].Protocol
+	})
+	return out, nil
+}
+
+func (r *InMemoryPortRepository) Reassign(fromID, toID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	from, ok := r.byDevice[fromID]
+	if !ok {
+		return nil
+	}
+	delete(r.byDevice, fromID)
+	to, ok := r.byDevice[toID]
+	if !ok {
+		r.byDevice[toID] = from
+		return nil
+	}
+	for k, p := range from {
+		if kept, ok := to[k]; ok {
+			if p.FirstSeen.Before(kept.FirstSeen) {
+				kept.FirstSeen = p.FirstSeen
+				to[k] = kept
+			}
+			continue
+		}
+		to[k] = p
+	}
+	return nil
+}
+
+func (r *InMemoryPortRepository) Clear() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byDevice = make(map[string]map[portKey]model.Port)
+	return nil
+}
+
+var _ PortRepository = (*InMemoryPortRepository)(nil)
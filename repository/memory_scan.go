@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"network-scanner/model"
+	"sort"
+	"sync"
+)
+
+type InMemoryScanRepository struct {
+	mu   sync.RWMutex
+	byID map[string]model.ScanJob
+}
+
+func NewInMemoryScanRepository() *InMemoryScanRepository {
+	return &InMemoryScanRepository{byID: make(map[string]model.ScanJob)}
+}
+
+func (r *InMemoryScanRepository) Save(job model.ScanJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byID[job.ID] = job
+	return nil
+}
+
+func (r *InMemoryScanRepository) FindByID(id string) (*model.ScanJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if j, ok := r.byID[id]; ok {
+		jj := j
+		return &jj, nil
+	}
+	return nil, nil
+}
+
+func (r *InMemoryScanRepository) GetAll() ([]model.ScanJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.ScanJob, 0, len(r.byID))
+	for _, j := range r.byID {
+		out = append(out, j)
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].CreatedAt.After(out[k].CreatedAt) })
+	return out, nil
+}
+
+var _ ScanRepository = (*InMemoryScanRepository)(nil)
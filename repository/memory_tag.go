@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"network-scanner/model"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type InMemoryTagRepository struct {
+	mu     sync.RWMutex
+	byName map[string]model.Tag
+	// devices receives the device side of a cascade.
+	devices DeviceRepository
+}
+
+func NewInMemoryTagRepository(devices DeviceRepository) *InMemoryTagRepository {
+	return &InMemoryTagRepository{byName: make(map[string]model.Tag), devices: devices}
+}
+
+func (r *InMemoryTagRepository) Save(t model.Tag) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t.DeviceCount = 0
+	r.byName[t.Name] = t
+	return nil
+}
+
+func (r *InMemoryTagRepository) GetAll() ([]model.Tag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.Tag, 0, len(r.byName))
+	for _, t := range r.byName {
+		out = append(out, t)
+	}
+	slices.SortFunc(out, func(a, b model.Tag) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+func (r *InMemoryTagRepository) FindByName(name string) (*model.Tag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if t, ok := r.byName[name]; ok {
+		return &t, nil
+	}
+	return nil, nil
+}
+
+func (r *InMemoryTagRepository) Ensure(names []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, name := range names {
+		if _, ok := r.byName[name]; !ok {
+			r.byName[name] = model.Tag{Name: name, CreatedAt: now}
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryTagRepository) Delete(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byName, name)
+	return nil
+}
+
+// Cascade writes the device tags first; the registry change that follows
+// cannot fail.
+func (r *InMemoryTagRepository) Cascade(name string, to *model.Tag, devices map[string][]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(devices) > 0 {
+		if err := r.devices.SetTags(devices); err != nil {
+			return err
+		}
+	}
+	delete(r.byName, name)
+	if to != nil {
+		t := *to
+		t.DeviceCount = 0
+		r.byName[t.Name] = t
+	}
+	return nil
+}
+
+var _ TagRepository = (*InMemoryTagRepository)(nil)
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"cmp"
+	"network-scanner/model"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type InMemoryTagRuleRepository struct {
+	mu   sync.RWMutex
+	byID map[string]model.TagRule
+}
+
+func NewInMemoryTagRuleRepository() *InMemoryTagRuleRepository {
+	return &InMemoryTagRuleRepository{byID: make(map[string]model.TagRule)}
+}
+
+func (r *InMemoryTagRuleRepository) Save(rule model.TagRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byID[rule.ID] = cloneRule(rule)
+	return nil
+}
+
+func (r *InMemoryTagRuleRepository) GetAll() ([]model.TagRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.TagRule, 0, len(r.byID))
+	for _, rule := range r.byID {
+		out = append(out, cloneRule(rule))
+	}
+	slices.SortFunc(out, func(a, b model.TagRule) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), strings.Compare(a.Name, b.Name))
+	})
+	return out, nil
+}
+
+func (r *InMemoryTagRuleRepository) FindByID(id string) (*model.TagRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if rule, ok := r.byID[id]; ok {
+		rule = cloneRule(rule)
+		return &rule, nil
+	}
+	return nil, nil
+}
+
+func (r *InMemoryTagRuleRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byID, id)
+	return nil
+}
+
+func cloneRule(rule model.TagRule) model.TagRule {
+	rule.Tags = slices.Clone(rule.Tags)
+	rule.Match.Ports = slices.Clone(rule.Match.Ports)
+	return rule
+}
+
+var _ TagRuleRepository = (*InMemoryTagRuleRepository)(nil)
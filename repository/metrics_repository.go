@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"network-scanner/model"
+	"time"
+)
+
+type MetricsRepository interface {
+	Append(samples ...model.LatencySample) error
+	// Find returns the samples of a device between from and to, oldest first.
+	// A zero bound is open.
+	Find(deviceID string, from, to time.Time) ([]model.LatencySample, error)
+	// Prune drops samples taken before the given time.
+	Prune(before time.Time) error
+	Reassign(fromID, toID string) error
+	Clear() error
+}
@@ -0,0 +1,14 @@
+package repository
+
+import "network-scanner/model"
+
+type PortRepository interface {
+	// SavePorts upserts the given ports of a device; ports not listed are
+	// left untouched.
+	SavePorts(deviceID string, ports []model.Port) error
+	FindByDevice(deviceID string) ([]model.Port, error)
+	// Reassign moves the ports of one device onto another. A port both
+	// devices know keeps the target's state and the earlier first sighting.
+	Reassign(fromID, toID string) error
+	Clear() error
+}
@@ -1,12 +1,24 @@
 package repository
 
 import (
+	"database/sql"
+	"net/netip"
 	"network-scanner/model"
 	"reflect"
+	"slices"
 	"testing"
 	"time"
+
+	_ "github.com/mattn/go-sqlite3"
 )
 
+type dummyLogger struct{}
+
+func (l *dummyLogger) Info(args ...interface{})  {}
+func (l *dummyLogger) Error(args ...interface{}) {}
+func (l *dummyLogger) Debug(args ...interface{}) {}
+func (l *dummyLogger) Warn(args ...interface{})  {}
+
 func TestSaveAndGetAll_NewModel(t *testing.T) {
 	repo := NewInMemoryRepository()
 
@@ -148,3 +160,623 @@ func ids(devs []model.Device) []string {
 	}
 	return out
 }
+
+func TestSQLiteIPRangeRepository_Exclude(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	repo := NewSQLiteIPRangeRepository(db, &dummyLogger{})
+	in := model.IPRange{ID: "r1", Name: "office", Range: "10.0.1.0/24", Exclude: []string{"10.0.1.1", "10.0.1.200/29"}}
+	if err := repo.Save(in); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	all, err := repo.GetAll()
+	if err != nil || len(all) != 1 {
+		t.Fatalf("expected 1 range, got %v (err %v)", all, err)
+	}
+	if !reflect.DeepEqual(all[0], in) {
+		t.Errorf("expected %+v, got %+v", in, all[0])
+	}
+}
+
+func TestSQLiteRepository_SaveAndFind(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	repo, err := NewSQLiteRepositoryWithDB(db, &dummyLogger{})
+	if err != nil {
+		t.Fatalf("failed to create repo: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	dev := model.Device{
+		ID:             "d1",
+		IPAddress:      "10.0.0.5",
+		MACAddress:     "AA:BB:CC:DD:EE:FF",
+		Hostname:       "printer",
+		Status:         "online",
+		Tags:           []string{"office"},
+		LastSeen:       now,
+		FirstSeen:      now,
+		LivenessMethod: "tcp",
+		DeviceType:     model.TypePrinter,
+		TypeConfidence: 0.91,
+		TypeManual:     true,
+		OS:             &model.OSGuess{Family: model.OSLinux, Confidence: 0.76, Evidence: []string{"ttl 64 (initial 64)"}, TTL: 64},
+	}
+	repo.Save(dev)
+
+	got, err := repo.FindByID("d1")
+	if err != nil {
+		t.Fatalf("FindByID error: %v", err)
+	}
+	if !reflect.DeepEqual(*got, dev) {
+		t.Errorf("expected %+v, got %+v", dev, *got)
+	}
+	if byIP := repo.FindByIP("10.0.0.5"); byIP == nil || byIP.LivenessMethod != "tcp" {
+		t.Errorf("expected FindByIP to return liveness method, got %+v", byIP)
+	}
+}
+
+func TestSQLiteRepository_ReleasedAddressesAreNotUnique(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	repo, err := NewSQLiteRepositoryWithDB(db, &dummyLogger{})
+	if err != nil {
+		t.Fatalf("failed to create repo: %v", err)
+	}
+	repo.Save(model.Device{ID: "d1", MACAddress: "aa:aa:aa:aa:aa:01", Status: "offline"})
+	repo.Save(model.Device{ID: "d2", MACAddress: "aa:aa:aa:aa:aa:02", Status: "offline"})
+	if n := len(repo.GetAll()); n != 2 {
+		t.Errorf("expected both devices without an address to be kept, got %d", n)
+	}
+	if d := repo.FindByMAC("AA:AA:AA:AA:AA:02"); d == nil || d.ID != "d2" {
+		t.Errorf("expected case-insensitive MAC lookup to find d2, got %+v", d)
+	}
+	if err := repo.Delete("d1"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if d, _ := repo.FindByID("d1"); d != nil {
+		t.Errorf("expected d1 to be deleted, got %+v", d)
+	}
+}
+
+func TestSQLiteAddressRepository_Reassign(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	repo := NewSQLiteAddressRepository(db, &dummyLogger{})
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_ = repo.RecordAddress("old", "10.0.0.5", t0)
+	_ = repo.RecordAddress("new", "10.0.0.5", t0.Add(time.Hour))
+	_ = repo.RecordAddress("new", "10.0.0.9", t0.Add(2*time.Hour))
+
+	if err := repo.Reassign("old", "new"); err != nil {
+		t.Fatalf("Reassign error: %v", err)
+	}
+	got, err := repo.FindByDevice("new")
+	if err != nil {
+		t.Fatalf("FindByDevice error: %v", err)
+	}
+	want := []model.DeviceAddress{
+		{IPAddress: "10.0.0.9", FirstSeen: t0.Add(2 * time.Hour), LastSeen: t0.Add(2 * time.Hour)},
+		{IPAddress: "10.0.0.5", FirstSeen: t0, LastSeen: t0.Add(time.Hour)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if old, _ := repo.FindByDevice("old"); len(old) != 0 {
+		t.Errorf("expected history of old device to be moved, got %+v", old)
+	}
+}
+
+func TestSQLiteEventRepository_Find(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	repo := NewSQLiteEventRepository(db, &dummyLogger{})
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = repo.Append(
+		model.DeviceEvent{DeviceID: "d1", Type: model.EventDiscovered, NewValue: "10.0.0.5", Timestamp: t0},
+		model.DeviceEvent{DeviceID: "d1", Type: model.EventStatusChanged, OldValue: "online", NewValue: "offline", Timestamp: t0.Add(time.Hour)},
+		model.DeviceEvent{DeviceID: "d2", Type: model.EventStatusChanged, OldValue: "offline", NewValue: "online", Timestamp: t0.Add(2 * time.Hour)},
+	)
+	if err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	all, _ := repo.Find(EventFilter{})
+	if len(all) != 3 || all[0].DeviceID != "d2" {
+		t.Errorf("expected 3 events newest first, got %+v", all)
+	}
+	byDevice, _ := repo.Find(EventFilter{DeviceID: "d1", Types: []string{model.EventStatusChanged}})
+	if len(byDevice) != 1 || byDevice[0].OldValue != "online" {
+		t.Errorf("expected d1 status change only, got %+v", byDevice)
+	}
+	window, _ := repo.Find(EventFilter{From: t0.Add(30 * time.Minute), To: t0.Add(90 * time.Minute)})
+	if len(window) != 1 || window[0].Type != model.EventStatusChanged || window[0].DeviceID != "d1" {
+		t.Errorf("expected one event in window, got %+v", window)
+	}
+	limited, _ := repo.Find(EventFilter{Limit: 1})
+	if len(limited) != 1 {
+		t.Errorf("expected limit to apply, got %d", len(limited))
+	}
+}
+
+func TestSQLiteMetricsRepository_FindAndPrune(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	repo := NewSQLiteMetricsRepository(db, &dummyLogger{})
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = repo.Append(
+		model.LatencySample{DeviceID: "d1", Timestamp: t0.Add(time.Hour), Sent: 3, Received: 3, RTTAvgMs: 1.5},
+		model.LatencySample{DeviceID: "d1", Timestamp: t0, Sent: 3, Received: 2, LossPercent: 33.3},
+		model.LatencySample{DeviceID: "d2", Timestamp: t0, Sent: 3},
+	)
+	if err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	all, _ := repo.Find("d1", time.Time{}, time.Time{})
+	if len(all) != 2 || !all[0].Timestamp.Equal(t0) || all[1].RTTAvgMs != 1.5 {
+		t.Errorf("expected 2 samples oldest first, got %+v", all)
+	}
+	window, _ := repo.Find("d1", t0.Add(30*time.Minute), time.Time{})
+	if len(window) != 1 || window[0].Received != 3 {
+		t.Errorf("expected one sample in window, got %+v", window)
+	}
+
+	if err := repo.Prune(t0.Add(30 * time.Minute)); err != nil {
+		t.Fatalf("Prune error: %v", err)
+	}
+	if left, _ := repo.Find("d2", time.Time{}, time.Time{}); len(left) != 0 {
+		t.Errorf("expected old samples to be pruned, got %+v", left)
+	}
+}
+
+func TestList_SQLiteMatchesInMemory(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	sqliteRepo, err := NewSQLiteRepositoryWithDB(db, &dummyLogger{})
+	if err != nil {
+		t.Fatalf("failed to create repo: %v", err)
+	}
+	memRepo := NewInMemoryRepository()
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	devices := []model.Device{
+		{ID: "a", IPAddress: "10.0.1.10", Status: "online", Manufacturer: "Cisco Systems", DeviceType: model.TypeRouter, Tags: []string{"prod"}, LastSeen: t0.Add(3 * time.Hour), FirstSeen: t0},
+		{ID: "b", IPAddress: "10.0.1.9", Status: "offline", Manufacturer: "Dell", Tags: []string{"prod"}, LastSeen: t0.Add(time.Hour), FirstSeen: t0},
+		{ID: "c", IPAddress: "10.0.2.1", Status: "online", Manufacturer: "cisco", DeviceType: model.TypeSwitch, LastSeen: t0.Add(2 * time.Hour), FirstSeen: t0},
+		{ID: "d", IPAddress: "2001:db8::1", Status: "degraded", Tags: []string{"lab"}, LastSeen: t0.Add(4 * time.Hour), FirstSeen: t0},
+		{ID: "e", IPAddress: "10.0.1.200", Status: "online", Tags: []string{"prod"}, LastSeen: t0.Add(3 * time.Hour), FirstSeen: t0},
+	}
+	for _, d := range devices {
+		sqliteRepo.Save(d)
+		memRepo.Save(d)
+	}
+
+	ids := func(p model.DevicePage) []string {
+		var out []string
+		for _, d := range p.Devices {
+			out = append(out, d.ID)
+		}
+		return out
+	}
+	cases := []struct {
+		name  string
+		q     DeviceQuery
+		want  []string
+		total int
+	}{
+		{"default sorts numerically by ip", DeviceQuery{}, []string{"b", "a", "e", "c", "d"}, 5},
+		{"status", DeviceQuery{Status: "online"}, []string{"a", "e", "c"}, 3},
+		{"tag", DeviceQuery{Tag: "prod"}, []string{"b", "a", "e"}, 3},
+		{"manufacturer", DeviceQuery{Manufacturer: "CISCO"}, []string{"a", "c"}, 2},
+		{"type", DeviceQuery{Type: model.TypeSwitch}, []string{"c"}, 1},
+		{"seen since", DeviceQuery{SeenSince: t0.Add(3 * time.Hour)}, []string{"a", "e", "d"}, 3},
+		{"cidr", DeviceQuery{CIDR: netip.MustParsePrefix("10.0.1.0/24")}, []string{"b", "a", "e"}, 3},
+		{"ipv6 cidr", DeviceQuery{CIDR: netip.MustParsePrefix("2001:db8::/32")}, []string{"d"}, 1},
+		{"sort desc with id tiebreak", DeviceQuery{Sort: "last_seen", Desc: true}, []string{"d", "e", "a", "c", "b"}, 5},
+		{"limit", DeviceQuery{Sort: "last_seen", Desc: true, Limit: 2}, []string{"d", "e"}, 5},
+	}
+	for _, tc := range cases {
+		for name, repo := range map[string]DeviceRepository{"sqlite": sqliteRepo, "memory": memRepo} {
+			page, err := repo.List(tc.q)
+			if err != nil {
+				t.Fatalf("%s/%s: List error: %v", name, tc.name, err)
+			}
+			if !reflect.DeepEqual(ids(page), tc.want) || page.Total != tc.total {
+				t.Errorf("%s/%s: expected %v (total %d), got %v (total %d)", name, tc.name, tc.want, tc.total, ids(page), page.Total)
+			}
+		}
+	}
+
+	for name, repo := range map[string]DeviceRepository{"sqlite": sqliteRepo, "memory": memRepo} {
+		var all []string
+		q := DeviceQuery{Sort: "last_seen", Desc: true, Limit: 2}
+		for {
+			page, err := repo.List(q)
+			if err != nil {
+				t.Fatalf("%s: List error: %v", name, err)
+			}
+			all = append(all, ids(page)...)
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		if want := []string{"d", "e", "a", "c", "b"}; !reflect.DeepEqual(all, want) {
+			t.Errorf("%s: expected pages to cover %v, got %v", name, want, all)
+		}
+		if _, err := repo.List(DeviceQuery{Cursor: "not-a-cursor"}); err != ErrInvalidCursor {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", name, err)
+		}
+		if _, err := repo.List(DeviceQuery{Sort: "password"}); err != ErrInvalidSort {
+			t.Errorf("%s: expected ErrInvalidSort, got %v", name, err)
+		}
+	}
+}
+
+func TestExpr_SQLiteMatchesInMemory(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	sqliteRepo, err := NewSQLiteRepositoryWithDB(db, &dummyLogger{})
+	if err != nil {
+		t.Fatalf("failed to create repo: %v", err)
+	}
+	memRepo := NewInMemoryRepository()
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, d := range []model.Device{
+		{ID: "a", IPAddress: "10.0.1.10", Hostname: "core_sw", Manufacturer: "Cisco Systems", Status: "offline", Tags: []string{"prod"}, LastSeen: t0},
+		{ID: "b", IPAddress: "10.0.2.10", Hostname: "web%1", Manufacturer: "Dell", Status: "online", Tags: []string{"prod", "web"}, LastSeen: t0.Add(time.Hour)},
+		{ID: "c", IPAddress: "192.168.0.1", Hostname: "coresw", Status: "online", Tags: []string{"lab"}, LastSeen: t0.Add(2 * time.Hour)},
+	} {
+		sqliteRepo.Save(d)
+		memRepo.Save(d)
+	}
+
+	cases := []struct {
+		name string
+		expr Expr
+		want []string
+	}{
+		{"tag glob matches elements", TextExpr{Field: FieldTag, Pattern: "*e*"}, []string{"b"}},
+		{"tag glob ignores the JSON encoding", TextExpr{Field: FieldTag, Pattern: `*"*`}, nil},
+		{"underscore is literal", TextExpr{Field: FieldHostname, Pattern: "core_*"}, []string{"a"}},
+		{"percent is literal", TextExpr{Field: FieldHostname, Pattern: "web%1"}, []string{"b"}},
+		{"case-insensitive", TextExpr{Field: FieldManufacturer, Pattern: "cisco*"}, []string{"a"}},
+		{"free text", TextExpr{Pattern: "*LAB*"}, []string{"c"}},
+		{"prefix", PrefixExpr{Prefix: netip.MustParsePrefix("10.0.0.0/16")}, []string{"a", "b"}},
+		{"time", TimeExpr{Field: "last_seen", Op: ">", T: t0}, []string{"b", "c"}},
+		{"not and or", OrExpr{
+			Left:  AndExpr{Left: TextExpr{Field: FieldTag, Pattern: "prod"}, Right: NotExpr{X: TextExpr{Field: FieldStatus, Pattern: "online"}}},
+			Right: TextExpr{Field: FieldID, Pattern: "c"},
+		}, []string{"a", "c"}},
+	}
+	for _, tc := range cases {
+		for name, repo := range map[string]DeviceRepository{"sqlite": sqliteRepo, "memory": memRepo} {
+			page, err := repo.List(DeviceQuery{Match: tc.expr, Sort: "hostname"})
+			if err != nil {
+				t.Fatalf("%s/%s: List error: %v", name, tc.name, err)
+			}
+			var got []string
+			for _, d := range page.Devices {
+				got = append(got, d.ID)
+			}
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s/%s: expected %v, got %v", name, tc.name, tc.want, got)
+			}
+		}
+	}
+}
+
+func TestSQLiteGroupRepository(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	repo := NewSQLiteGroupRepository(db, &dummyLogger{})
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dyn := model.DeviceGroup{ID: "g1", Name: "offline printers", Type: model.GroupDynamic, Query: "tag:printer AND status:offline", CreatedAt: created}
+	static := model.DeviceGroup{ID: "g2", Name: "core", Type: model.GroupStatic, DeviceIDs: []string{"old", "keep"}, CreatedAt: created}
+	if err := repo.Save(dyn); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	if err := repo.Save(static); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	if err := repo.Save(model.DeviceGroup{ID: "g3", Name: "core", Type: model.GroupStatic, CreatedAt: created}); err == nil {
+		t.Errorf("expected group names to be unique")
+	}
+
+	got, _ := repo.FindByID("g1")
+	if got == nil || !reflect.DeepEqual(*got, dyn) {
+		t.Errorf("expected %+v, got %+v", dyn, got)
+	}
+	if missing, err := repo.FindByID("nope"); missing != nil || err != nil {
+		t.Errorf("expected nil for a missing group, got %+v, %v", missing, err)
+	}
+
+	if err := repo.Reassign("old", "keep"); err != nil {
+		t.Fatalf("Reassign error: %v", err)
+	}
+	got, _ = repo.FindByID("g2")
+	if !reflect.DeepEqual(got.DeviceIDs, []string{"keep"}) {
+		t.Errorf("expected merged member to be deduplicated, got %v", got.DeviceIDs)
+	}
+
+	repo.Delete("g1")
+	all, _ := repo.GetAll()
+	if len(all) != 1 || all[0].ID != "g2" {
+		t.Errorf("expected only g2 left, got %+v", all)
+	}
+}
+
+func TestSQLiteTagRepository(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	repo := NewSQLiteTagRepository(db, &dummyLogger{})
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	prod := model.Tag{Name: "prod", Color: "#ff0000", Description: "production", CreatedAt: created}
+	if err := repo.Save(prod); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	if err := repo.Ensure([]string{"prod", "lab"}); err != nil {
+		t.Fatalf("Ensure error: %v", err)
+	}
+	got, _ := repo.FindByName("prod")
+	if got == nil || !reflect.DeepEqual(*got, prod) {
+		t.Errorf("expected Ensure to keep %+v, got %+v", prod, got)
+	}
+
+	prod.Color = "#00ff00"
+	prod.CreatedAt = created.Add(time.Hour)
+	repo.Save(prod)
+	got, _ = repo.FindByName("prod")
+	if got.Color != "#00ff00" || !got.CreatedAt.Equal(created) {
+		t.Errorf("expected color update keeping created_at, got %+v", got)
+	}
+
+	repo.Delete("lab")
+	all, _ := repo.GetAll()
+	if len(all) != 1 || all[0].Name != "prod" {
+		t.Errorf("expected only prod left, got %+v", all)
+	}
+	if missing, err := repo.FindByName("lab"); missing != nil || err != nil {
+		t.Errorf("expected nil for a missing tag, got %+v, %v", missing, err)
+	}
+}
+
+func TestSetTagsAndTagCounts(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	sqlite, err := NewSQLiteRepositoryWithDB(db, &dummyLogger{})
+	if err != nil {
+		t.Fatalf("failed to create repo: %v", err)
+	}
+
+	for name, repo := range map[string]DeviceRepository{"memory": NewInMemoryRepository(), "sqlite": sqlite} {
+		repo.Save(model.Device{ID: "a", IPAddress: "10.0.0.1", Tags: []string{"prod"}})
+		repo.Save(model.Device{ID: "b", IPAddress: "10.0.0.2", Tags: []string{"prod", "web"}})
+		repo.Save(model.Device{ID: "c", IPAddress: "10.0.0.3"})
+
+		if err := repo.SetTags(map[string][]string{"a": {"web"}, "c": {"web", "lab"}, "missing": {"x"}}); err != nil {
+			t.Fatalf("%s: SetTags error: %v", name, err)
+		}
+		d, _ := repo.FindByID("c")
+		if !reflect.DeepEqual(d.Tags, []string{"web", "lab"}) {
+			t.Errorf("%s: expected c tags [web lab], got %v", name, d.Tags)
+		}
+		counts, err := repo.TagCounts()
+		if err != nil {
+			t.Fatalf("%s: TagCounts error: %v", name, err)
+		}
+		want := map[string]int{"prod": 1, "web": 3, "lab": 1}
+		if !reflect.DeepEqual(counts, want) {
+			t.Errorf("%s: expected %v, got %v", name, want, counts)
+		}
+	}
+}
+
+func TestSQLiteTagRuleRepository(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	repo := NewSQLiteTagRuleRepository(db, &dummyLogger{})
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pis := model.TagRule{
+		ID: "r1", Name: "pis", Position: 2, Enabled: true, Stop: true,
+		Match:     model.RuleMatch{Manufacturer: "Raspberry", Ports: []int{22}},
+		Tags:      []string{"iot"},
+		CreatedAt: created,
+	}
+	printers := model.TagRule{ID: "r2", Name: "printers", Position: 1, Match: model.RuleMatch{Hostname: "^prn"}, Tags: []string{"printer"}, CreatedAt: created}
+	for _, r := range []model.TagRule{pis, printers} {
+		if err := repo.Save(r); err != nil {
+			t.Fatalf("Save error: %v", err)
+		}
+	}
+
+	got, _ := repo.FindByID("r1")
+	if got == nil || !reflect.DeepEqual(*got, pis) {
+		t.Errorf("expected %+v, got %+v", pis, got)
+	}
+	all, _ := repo.GetAll()
+	if len(all) != 2 || all[0].ID != "r2" || all[1].ID != "r1" {
+		t.Errorf("expected rules ordered by position, got %+v", all)
+	}
+
+	repo.Delete("r2")
+	if missing, err := repo.FindByID("r2"); missing != nil || err != nil {
+		t.Errorf("expected nil for a deleted rule, got %+v, %v", missing, err)
+	}
+}
+
+func TestSQLitePortRepository_Services(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	repo := NewSQLitePortRepository(db, &dummyLogger{})
+	seen := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ports := []model.Port{
+		{Port: 22, Protocol: "tcp", State: model.PortOpen, Service: "ssh", Version: "OpenSSH_9.6p1", Banner: "SSH-2.0-OpenSSH_9.6p1", FirstSeen: seen, LastSeen: seen},
+		{Port: 80, Protocol: "tcp", State: model.PortOpen, Service: "http", Version: "nginx/1.24.0", Banner: "HTTP/1.1 200 OK", Title: "Router", FirstSeen: seen, LastSeen: seen},
+	}
+	if err := repo.SavePorts("d1", ports); err != nil {
+		t.Fatalf("SavePorts error: %v", err)
+	}
+	got, err := repo.FindByDevice("d1")
+	if err != nil || !reflect.DeepEqual(got, ports) {
+		t.Errorf("expected %+v, got %+v, %v", ports, got, err)
+	}
+}
+
+func TestPortRepository_Reassign(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	early := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := early.Add(24 * time.Hour)
+	for name, repo := range map[string]PortRepository{
+		"memory": NewInMemoryPortRepository(),
+		"sqlite": NewSQLitePortRepository(db, &dummyLogger{}),
+	} {
+		repo.SavePorts("keep", []model.Port{{Port: 22, Protocol: "tcp", State: model.PortOpen, FirstSeen: late, LastSeen: late}})
+		repo.SavePorts("drop", []model.Port{
+			{Port: 22, Protocol: "tcp", State: model.PortClosed, FirstSeen: early, LastSeen: early},
+			{Port: 80, Protocol: "tcp", State: model.PortOpen, Service: "http", FirstSeen: early, LastSeen: early},
+		})
+		if err := repo.Reassign("drop", "keep"); err != nil {
+			t.Fatalf("%s: Reassign error: %v", name, err)
+		}
+
+		want := []model.Port{
+			{Port: 22, Protocol: "tcp", State: model.PortOpen, FirstSeen: early, LastSeen: late},
+			{Port: 80, Protocol: "tcp", State: model.PortOpen, Service: "http", FirstSeen: early, LastSeen: early},
+		}
+		if got, _ := repo.FindByDevice("keep"); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+		if got, _ := repo.FindByDevice("drop"); len(got) != 0 {
+			t.Errorf("%s: expected no ports left on drop, got %+v", name, got)
+		}
+	}
+}
+
+func TestTagRepository_Cascade(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	sqlite, err := NewSQLiteRepositoryWithDB(db, &dummyLogger{})
+	if err != nil {
+		t.Fatalf("failed to create repo: %v", err)
+	}
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	memDevices := NewInMemoryRepository()
+	for name, c := range map[string]struct {
+		devices DeviceRepository
+		tags    TagRepository
+	}{
+		"memory": {memDevices, NewInMemoryTagRepository(memDevices)},
+		"sqlite": {sqlite, NewSQLiteTagRepository(db, &dummyLogger{})},
+	} {
+		c.devices.Save(model.Device{ID: "a", IPAddress: "10.0.0.1", Tags: []string{"old", "web"}})
+		c.tags.Save(model.Tag{Name: "old", Color: "#ff0000", CreatedAt: created})
+
+		to := model.Tag{Name: "new", Color: "#ff0000", CreatedAt: created}
+		if err := c.tags.Cascade("old", &to, map[string][]string{"a": {"new", "web"}}); err != nil {
+			t.Fatalf("%s: Cascade error: %v", name, err)
+		}
+		if d, _ := c.devices.FindByID("a"); !reflect.DeepEqual(d.Tags, []string{"new", "web"}) {
+			t.Errorf("%s: expected the device to be retagged, got %v", name, d.Tags)
+		}
+		if all, _ := c.tags.GetAll(); len(all) != 1 || !reflect.DeepEqual(all[0], to) {
+			t.Errorf("%s: expected only %+v registered, got %+v", name, to, all)
+		}
+
+		if err := c.tags.Cascade("new", nil, map[string][]string{"a": {"web"}}); err != nil {
+			t.Fatalf("%s: Cascade delete error: %v", name, err)
+		}
+		if all, _ := c.tags.GetAll(); len(all) != 0 {
+			t.Errorf("%s: expected the tag to be deleted, got %+v", name, all)
+		}
+	}
+
+	// A failed device update leaves the registry untouched.
+	tags := NewSQLiteTagRepository(db, &dummyLogger{})
+	tags.Save(model.Tag{Name: "kept", CreatedAt: created})
+	if _, err := db.Exec(`DROP TABLE devices`); err != nil {
+		t.Fatal(err)
+	}
+	if err := tags.Cascade("kept", nil, map[string][]string{"a": nil}); err == nil {
+		t.Fatal("expected Cascade to fail without a devices table")
+	}
+	if got, _ := tags.FindByName("kept"); got == nil {
+		t.Error("expected the tag to survive the failed cascade")
+	}
+}
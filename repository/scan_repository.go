@@ -0,0 +1,9 @@
+package repository
+
+import "network-scanner/model"
+
+type ScanRepository interface {
+	Save(job model.ScanJob) error
+	FindByID(id string) (*model.ScanJob, error)
+	GetAll() ([]model.ScanJob, error)
+}
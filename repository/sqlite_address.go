@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"database/sql"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLiteAddressRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+func NewSQLiteAddressRepository(db *sql.DB, logger logger.Logger) *SQLiteAddressRepository {
+	if err := ensureAddressesTable(db); err != nil {
+		logger.Error("failed to create device_addresses table", err)
+	}
+	return &SQLiteAddressRepository{db: db, logger: logger}
+}
+
+func ensureAddressesTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS device_addresses (
+			device_id TEXT NOT NULL,
+			ip_address TEXT NOT NULL,
+			first_seen DATETIME,
+			last_seen DATETIME,
+			PRIMARY KEY (device_id, ip_address)
+		);
+		CREATE INDEX IF NOT EXISTS idx_device_addresses_ip ON device_addresses(ip_address);
+	`)
+	return err
+}
+
+func (r *SQLiteAddressRepository) RecordAddress(deviceID, ip string, seen time.Time) error {
+	ts := seen.UTC().Format(time.RFC3339)
+	_, err := r.db.Exec(`
+		INSERT INTO device_addresses (device_id, ip_address, first_seen, last_seen)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (device_id, ip_address) DO UPDATE SET last_seen = excluded.last_seen
+	`, deviceID, ip, ts, ts)
+	return err
+}
+
+func (r *SQLiteAddressRepository) FindByDevice(deviceID string) ([]model.DeviceAddress, error) {
+	rows, err := r.db.Query(`
+		SELECT ip_address, first_seen, last_seen
+		FROM device_addresses WHERE device_id = ?
+		ORDER BY last_seen DESC, ip_address
+	`, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []model.DeviceAddress{}
+	for rows.Next() {
+		var a model.DeviceAddress
+		var firstSeenStr, lastSeenStr string
+		if err := rows.Scan(&a.IPAddress, &firstSeenStr, &lastSeenStr); err == nil {
+			a.FirstSeen, _ = time.Parse(time.RFC3339, firstSeenStr)
+			a.LastSeen, _ = time.Parse(time.RFC3339, lastSeenStr)
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (r *SQLiteAddressRepository) Reassign(fromID, toID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO device_addresses (device_id, ip_address, first_seen, last_seen)
+		SELECT ?, ip_address, first_seen, last_seen FROM device_addresses WHERE device_id = ?
+		ON CONFLICT (device_id, ip_address) DO UPDATE SET
+			first_seen = min(first_seen, excluded.first_seen),
+			last_seen = max(last_seen, excluded.last_seen)
+	`, toID, fromID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM device_addresses WHERE device_id = ?`, fromID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteAddressRepository) Clear() error {
+	_, err := r.db.Exec(`DELETE FROM device_addresses`)
+	return err
+}
+
+var _ AddressRepository = (*SQLiteAddressRepository)(nil)
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"database/sql"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLiteEventRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+func NewSQLiteEventRepository(db *sql.DB, logger logger.Logger) *SQLiteEventRepository {
+	if err := ensureEventsTable(db); err != nil {
+		logger.Error("failed to create device_events table", err)
+	}
+	return &SQLiteEventRepository{db: db, logger: logger}
+}
+
+func ensureEventsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS device_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			old_value TEXT,
+			new_value TEXT,
+			timestamp DATETIME NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_device_events_device ON device_events(device_id, timestamp);
+		CREATE INDEX IF NOT EXISTS idx_device_events_time ON device_events(timestamp);
+	`)
+	return err
+}
+
+func (r *SQLiteEventRepository) Append(events ...model.DeviceEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO device_events (device_id, type, old_value, new_value, timestamp)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		if _, err := stmt.Exec(e.DeviceID, e.Type, e.OldValue, e.NewValue, e.Timestamp.UTC().Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteEventRepository) Find(f EventFilter) ([]model.DeviceEvent, error) {
+	var where []string
+	var args []any
+	if f.DeviceID != "" {
+		where = append(where, "device_id = ?")
+		args = append(args, f.DeviceID)
+	}
+	if len(f.Types) > 0 {
+		where = append(where, "type IN (?"+strings.Repeat(", ?", len(f.Types)-1)+")")
+		for _, t := range f.Types {
+			args = append(args, t)
+		}
+	}
+	if !f.From.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, f.From.UTC().Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "timestamp <= ?")
+		args = append(args, f.To.UTC().Format(time.RFC3339))
+	}
+
+	query := `SELECT id, device_id, type, old_value, new_value, timestamp FROM device_events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY timestamp DESC, id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []model.DeviceEvent{}
+	for rows.Next() {
+		var e model.DeviceEvent
+		var oldValue, newValue sql.NullString
+		var ts string
+		if err := rows.Scan(&e.ID, &e.DeviceID, &e.Type, &oldValue, &newValue, &ts); err == nil {
+			e.OldValue = oldValue.String
+			e.NewValue = newValue.String
+			e.Timestamp, _ = time.Parse(time.RFC3339, ts)
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (r *SQLiteEventRepository) Reassign(fromID, toID string) error {
+	_, err := r.db.Exec(`UPDATE device_events SET device_id = ? WHERE device_id = ?`, toID, fromID)
+	return err
+}
+
+func (r *SQLiteEventRepository) Clear() error {
+	_, err := r.db.Exec(`DELETE FROM device_events`)
+	return err
+}
+
+var _ EventRepository = (*SQLiteEventRepository)(nil)
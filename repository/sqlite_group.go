@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLiteGroupRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+func NewSQLiteGroupRepository(db *sql.DB, logger logger.Logger) *SQLiteGroupRepository {
+	if err := ensureGroupsTable(db); err != nil {
+		logger.Error("failed to create device_groups table", err)
+	}
+	return &SQLiteGroupRepository{db: db, logger: logger}
+}
+
+func ensureGroupsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS device_groups (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			type TEXT NOT NULL,
+			query TEXT,
+			device_ids TEXT,
+			created_at DATETIME NOT NULL
+		);
+	`)
+	return err
+}
+
+func (r *SQLiteGroupRepository) Save(g model.DeviceGroup) error {
+	idsJSON, _ := json.Marshal(g.DeviceIDs)
+	_, err := r.db.Exec(`
+		INSERT INTO device_groups (id, name, type, query, device_ids, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			type = excluded.type,
+			query = excluded.query,
+			device_ids = excluded.device_ids
+	`, g.ID, g.Name, g.Type, g.Query, string(idsJSON), g.CreatedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+const groupColumns = `id, name, type, query, device_ids, created_at`
+
+func (r *SQLiteGroupRepository) GetAll() ([]model.DeviceGroup, error) {
+	rows, err := r.db.Query(`SELECT ` + groupColumns + ` FROM device_groups ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []model.DeviceGroup{}
+	for rows.Next() {
+		if g, err := scanGroup(rows); err == nil {
+			out = append(out, g)
+		}
+	}
+	return out, nil
+}
+
+func (r *SQLiteGroupRepository) FindByID(id string) (*model.DeviceGroup, error) {
+	g, err := scanGroup(r.db.QueryRow(`SELECT `+groupColumns+` FROM device_groups WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *SQLiteGroupRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM device_groups WHERE id = ?`, id)
+	return err
+}
+
+func (r *SQLiteGroupRepository) Reassign(fromID, toID string) error {
+	groups, err := r.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if ids, ok := reassignMember(g.DeviceIDs, fromID, toID); ok {
+			g.DeviceIDs = ids
+			if err := r.Save(g); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func scanGroup(row rowScanner) (model.DeviceGroup, error) {
+	var g model.DeviceGroup
+	var query, idsRaw sql.NullString
+	var created string
+	if err := row.Scan(&g.ID, &g.Name, &g.Type, &query, &idsRaw, &created); err != nil {
+		return g, err
+	}
+	g.Query = query.String
+	_ = json.Unmarshal([]byte(defaultIfEmpty(idsRaw.String, "null")), &g.DeviceIDs)
+	g.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	return g, nil
+}
+
+var _ GroupRepository = (*SQLiteGroupRepository)(nil)
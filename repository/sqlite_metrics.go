@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLiteMetricsRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+func NewSQLiteMetricsRepository(db *sql.DB, logger logger.Logger) *SQLiteMetricsRepository {
+	if err := ensureMetricsTable(db); err != nil {
+		logger.Error("failed to create device_metrics table", err)
+	}
+	return &SQLiteMetricsRepository{db: db, logger: logger}
+}
+
+func ensureMetricsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS device_metrics (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id TEXT NOT NULL,
+			timestamp DATETIME NOT NULL,
+			sent INTEGER NOT NULL,
+			received INTEGER NOT NULL,
+			loss_percent REAL NOT NULL,
+			rtt_min_ms REAL NOT NULL,
+			rtt_avg_ms REAL NOT NULL,
+			rtt_max_ms REAL NOT NULL,
+			jitter_ms REAL NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_device_metrics_device ON device_metrics(device_id, timestamp);
+		CREATE INDEX IF NOT EXISTS idx_device_metrics_time ON device_metrics(timestamp);
+	`)
+	return err
+}
+
+func (r *SQLiteMetricsRepository) Append(samples ...model.LatencySample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO device_metrics (device_id, timestamp, sent, received, loss_percent, rtt_min_ms, rtt_avg_ms, rtt_max_ms, jitter_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, s := range samples {
+		if _, err := stmt.Exec(s.DeviceID, s.Timestamp.UTC().Format(time.RFC3339), s.Sent, s.Received,
+			s.LossPercent, s.RTTMinMs, s.RTTAvgMs, s.RTTMaxMs, s.JitterMs); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteMetricsRepository) Find(deviceID string, from, to time.Time) ([]model.LatencySample, error) {
+	where := []string{"device_id = ?"}
+	args := []any{deviceID}
+	if !from.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		where = append(where, "timestamp <= ?")
+		args = append(args, to.UTC().Format(time.RFC3339))
+	}
+
+	rows, err := r.db.Query(`
+		SELECT device_id, timestamp, sent, received, loss_percent, rtt_min_ms, rtt_avg_ms, rtt_max_ms, jitter_ms
+		FROM device_metrics WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY timestamp, id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []model.LatencySample{}
+	for rows.Next() {
+		var s model.LatencySample
+		var ts string
+		if err := rows.Scan(&s.DeviceID, &ts, &s.Sent, &s.Received, &s.LossPercent,
+			&s.RTTMinMs, &s.RTTAvgMs, &s.RTTMaxMs, &s.JitterMs); err == nil {
+			s.Timestamp, _ = time.Parse(time.RFC3339, ts)
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (r *SQLiteMetricsRepository) Prune(before time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM device_metrics WHERE timestamp < ?`, before.UTC().Format(time.RFC3339))
+	return err
+}
+
+func (r *SQLiteMetricsRepository) Reassign(fromID, toID string) error {
+	_, err := r.db.Exec(`UPDATE device_metrics SET device_id = ? WHERE device_id = ?`, toID, fromID)
+	return err
+}
+
+func (r *SQLiteMetricsRepository) Clear() error {
+	_, err := r.db.Exec(`DELETE FROM device_metrics`)
+	return err
+}
+
+var _ MetricsRepository = (*SQLiteMetricsRepository)(nil)
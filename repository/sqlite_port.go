@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLitePortRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+func NewSQLitePortRepository(db *sql.DB, logger logger.Logger) *SQLitePortRepository {
+	if err := ensurePortsTable(db); err != nil {
+		logger.Error("failed to create device_ports table", err)
+	}
+	return &SQLitePortRepository{db: db, logger: logger}
+}
+
+func ensurePortsTable(db *sql.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS device_ports (
+			device_id TEXT NOT NULL,
+			port INTEGER NOT NULL,
+			protocol TEXT NOT NULL,
+			state TEXT NOT NULL,
+			first_seen DATETIME,
+			last_seen DATETIME,
+			PRIMARY KEY (device_id, port, protocol)
+		);
+	`); err != nil {
+		return err
+	}
+	for _, col := range []string{"service", "version", "banner", "title"} {
+		if err := ensureColumn(db, "device_ports", col, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLitePortRepository) SavePorts(deviceID string, ports []model.Port) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO device_ports
+			(device_id, port, protocol, state, first_seen, last_seen, service, version, banner, title)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, p := range ports {
+		if _, err := stmt.Exec(
+			deviceID,
+			p.Port,
+			p.Protocol,
+			p.State,
+			p.FirstSeen.UTC().Format(time.RFC3339),
+			p.LastSeen.UTC().Format(time.RFC3339),
+			p.Service,
+			p.Version,
+			p.Banner,
+			p.Title,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLitePortRepository) FindByDevice(deviceID string) ([]model.Port, error) {
+	rows, err := r.db.Query(`
+		SELECT port, protocol, state, first_seen, last_seen, service, version, banner, title
+		FROM device_ports WHERE device_id = ?
+		ORDER BY port, protocol
+	`, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []model.Port{}
+	for rows.Next() {
+		var p model.Port
+		var firstSeenStr, lastSeenStr string
+		if err := rows.Scan(&p.Port, &p.Protocol, &p.State, &firstSeenStr, &lastSeenStr, &p.Service, &p.Version, &p.Banner, &p.Title); err == nil {
+			p.FirstSeen, _ = time.Parse(time.RFC3339, firstSeenStr)
+			p.LastSeen, _ = time.Parse(time.RFC3339, lastSeenStr)
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (r *SQLitePortRepository) Reassign(fromID, toID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO device_ports
+			(device_id, port, protocol, state, first_seen, last_seen, service, version, banner, title)
+		SELECT ?, port, protocol, state, first_seen, last_seen, service, version, banner, title
+		FROM device_ports WHERE device_id = ?
+		ON CONFLICT (device_id, port, protocol) DO UPDATE SET
+			first_seen = min(first_seen, excluded.first_seen)
+	`, toID, fromID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM device_ports WHERE device_id = ?`, fromID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLitePortRepository) Clear() error {
+	_, err := r.db.Exec(`DELETE FROM device_ports`)
+	return err
+}
+
+var _ PortRepository = (*SQLitePortRepository)(nil)
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"network-scanner/logger"
 	"network-scanner/model"
 
@@ -14,23 +15,20 @@ type SQLiteIPRangeRepository struct {
 }
 
 func NewSQLiteIPRangeRepository(db *sql.DB, logger logger.Logger) *SQLiteIPRangeRepository {
-	_, _ = db.Exec(`
-		CREATE TABLE IF NOT EXISTS ip_ranges (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			range TEXT NOT NULL
-		);
-	`)
+	if err := ensureIPRangesTable(db); err != nil {
+		logger.Error("failed to create ip_ranges table", err)
+	}
 	return &SQLiteIPRangeRepository{db: db, logger: logger}
 }
 
 func (r *SQLiteIPRangeRepository) Save(x model.IPRange) error {
-	_, err := r.db.Exec(`INSERT OR REPLACE INTO ip_ranges(id,name,range) VALUES (?,?,?)`, x.ID, x.Name, x.Range)
+	excludeJSON, _ := json.Marshal(x.Exclude)
+	_, err := r.db.Exec(`INSERT OR REPLACE INTO ip_ranges(id,name,range,exclude) VALUES (?,?,?,?)`, x.ID, x.Name, x.Range, string(excludeJSON))
 	return err
 }
 
 func (r *SQLiteIPRangeRepository) GetAll() ([]model.IPRange, error) {
-	rows, err := r.db.Query(`SELECT id,name,range FROM ip_ranges ORDER BY name`)
+	rows, err := r.db.Query(`SELECT id,name,range,exclude FROM ip_ranges ORDER BY name`)
 	if err != nil {
 		return nil, err
 	}
@@ -38,7 +36,9 @@ func (r *SQLiteIPRangeRepository) GetAll() ([]model.IPRange, error) {
 	var out []model.IPRange
 	for rows.Next() {
 		var x model.IPRange
-		if err := rows.Scan(&x.ID, &x.Name, &x.Range); err == nil {
+		var excludeRaw sql.NullString
+		if err := rows.Scan(&x.ID, &x.Name, &x.Range, &excludeRaw); err == nil {
+			_ = json.Unmarshal([]byte(defaultIfEmpty(excludeRaw.String, "null")), &x.Exclude)
 			out = append(out, x)
 		}
 	}
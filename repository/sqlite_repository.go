@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"network-scanner/logger"
 	"network-scanner/model"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -57,9 +58,51 @@ func ensureDevicesTable(db *sql.DB) error {
 			last_seen DATETIME,
 			first_seen DATETIME
 		);
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_devices_ip ON devices(ip_address);
 		CREATE INDEX IF NOT EXISTS idx_devices_hostname ON devices(hostname);
 	`)
+	if err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "devices", "liveness_method", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "devices", "ipv6_addresses", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "devices", "flap_count", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "devices", "flapping", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "devices", "ip_key", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "devices", "device_type", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "devices", "type_confidence", "REAL NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "devices", "type_manual", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "devices", "os_guess", "TEXT"); err != nil {
+		return err
+	}
+	if err := backfillIPKeys(db); err != nil {
+		return err
+	}
+	// Devices are identified by MAC, so a device whose address was handed to
+	// another host keeps its row with an empty ip_address. Only non-empty
+	// addresses have to be unique.
+	_, err = db.Exec(`
+		DROP INDEX IF EXISTS idx_devices_ip;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_devices_ip_address ON devices(ip_address) WHERE ip_address != '';
+		CREATE INDEX IF NOT EXISTS idx_devices_mac ON devices(mac_address);
+		CREATE INDEX IF NOT EXISTS idx_devices_ip_key ON devices(ip_key);
+		CREATE INDEX IF NOT EXISTS idx_devices_last_seen ON devices(last_seen);
+	`)
 	return err
 }
 
@@ -71,22 +114,105 @@ func ensureIPRangesTable(db *sql.DB) error {
 			range TEXT NOT NULL
 		);
 	`)
+	if err != nil {
+		return err
+	}
+	return ensureColumn(db, "ip_ranges", "exclude", "TEXT")
+}
+
+// ensureColumn adds a column to an existing table if it is missing, so
+// databases created by older versions pick up new fields.
+func ensureColumn(db *sql.DB, table, column, decl string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + decl)
 	return err
 }
 
+// backfillIPKeys fills ip_key for rows written before the column existed.
+func backfillIPKeys(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, ip_address FROM devices WHERE ip_key = '' AND ip_address != ''`)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]string)
+	for rows.Next() {
+		var id, ip string
+		if err := rows.Scan(&id, &ip); err != nil {
+			rows.Close()
+			return err
+		}
+		keys[id] = ipKey(ip)
+	}
+	rows.Close()
+	for id, key := range keys {
+		if _, err := db.Exec(`UPDATE devices SET ip_key = ? WHERE id = ?`, key, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *SQLiteRepository) Save(d model.Device) {
+	if err := r.save(r.db, d); err != nil {
+		r.logger.Error("SQLite Save error", err)
+	}
+}
+
+func (r *SQLiteRepository) SaveBatch(devices []model.Device) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, d := range devices {
+		if err := r.save(tx, d); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (r *SQLiteRepository) save(db execer, d model.Device) error {
 	tagsJSON, _ := json.Marshal(d.Tags)
-	existing := r.FindByIP(d.IPAddress)
-	if existing != nil {
+	ipv6JSON, _ := json.Marshal(d.IPv6Addresses)
+	osJSON, _ := json.Marshal(d.OS)
+	existing, err := scanDevice(db.QueryRow(`SELECT `+deviceColumns+` FROM devices WHERE id = ?`, d.ID))
+	if err != nil && d.IPAddress != "" {
+		existing, err = scanDevice(db.QueryRow(`SELECT `+deviceColumns+` FROM devices WHERE ip_address = ?`, d.IPAddress))
+	}
+	if err == nil {
 		if len(d.Tags) == 0 {
 			d.Tags = existing.Tags
 		}
-		d.FirstSeen = existing.FirstSeen
+		if !existing.FirstSeen.IsZero() && (d.FirstSeen.IsZero() || existing.FirstSeen.Before(d.FirstSeen)) {
+			d.FirstSeen = existing.FirstSeen
+		}
 	}
-	_, err := r.db.Exec(`
-		INSERT OR REPLACE INTO devices
-			(id, ip_address, mac_address, hostname, status, manufacturer, tags, last_seen, first_seen)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	_, err = db.Exec(`
+		INSERT OR REPLACE INTO devices (`+deviceColumns+`, ip_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		d.ID,
 		d.IPAddress,
@@ -97,17 +223,21 @@ func (r *SQLiteRepository) Save(d model.Device) {
 		string(tagsJSON),
 		d.LastSeen.UTC().Format(time.RFC3339),
 		d.FirstSeen.UTC().Format(time.RFC3339),
+		d.LivenessMethod,
+		string(ipv6JSON),
+		d.FlapCount,
+		d.Flapping,
+		d.DeviceType,
+		d.TypeConfidence,
+		d.TypeManual,
+		string(osJSON),
+		ipKey(d.IPAddress),
 	)
-	if err != nil {
-		r.logger.Error("SQLite Save error", err)
-	}
+	return err
 }
 
 func (r *SQLiteRepository) GetAll() []model.Device {
-	rows, err := r.db.Query(`
-		SELECT id, ip_address, mac_address, hostname, status, manufacturer, tags, last_seen, first_seen
-		FROM devices
-	`)
+	rows, err := r.db.Query(`SELECT ` + deviceColumns + ` FROM devices`)
 	if err != nil {
 		r.logger.Error("SQLite GetAll error", err)
 		return nil
@@ -116,17 +246,7 @@ func (r *SQLiteRepository) GetAll() []model.Device {
 
 	var out []model.Device
 	for rows.Next() {
-		var d model.Device
-		var tagsRaw string
-		var lastSeenStr, firstSeenStr string
-		if err := rows.Scan(&d.ID, &d.IPAddress, &d.MACAddress, &d.Hostname, &d.Status, &d.Manufacturer, &tagsRaw, &lastSeenStr, &firstSeenStr); err == nil {
-			_ = json.Unmarshal([]byte(defaultIfEmpty(tagsRaw, "[]")), &d.Tags)
-			if lastSeenStr != "" {
-				d.LastSeen, _ = time.Parse(time.RFC3339, lastSeenStr)
-			}
-			if firstSeenStr != "" {
-				d.FirstSeen, _ = time.Parse(time.RFC3339, firstSeenStr)
-			}
+		if d, err := scanDevice(rows); err == nil {
 			out = append(out, d)
 		}
 	}
@@ -140,24 +260,17 @@ func (r *SQLiteRepository) Clear() {
 	}
 }
 
+func (r *SQLiteRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM devices WHERE id = ?`, id)
+	return err
+}
+
 func (r *SQLiteRepository) FindByID(id string) (*model.Device, error) {
-	row := r.db.QueryRow(`
-		SELECT id, ip_address, mac_address, hostname, status, manufacturer, tags, last_seen, first_seen
-		FROM devices WHERE id = ?
-	`, id)
-	var d model.Device
-	var tagsRaw string
-	var lastSeenStr, firstSeenStr string
-	if err := row.Scan(&d.ID, &d.IPAddress, &d.MACAddress, &d.Hostname, &d.Status, &d.Manufacturer, &tagsRaw, &lastSeenStr, &firstSeenStr); err != nil {
+	row := r.db.QueryRow(`SELECT `+deviceColumns+` FROM devices WHERE id = ?`, id)
+	d, err := scanDevice(row)
+	if err != nil {
 		return nil, err
 	}
-	_ = json.Unmarshal([]byte(defaultIfEmpty(tagsRaw, "[]")), &d.Tags)
-	if lastSeenStr != "" {
-		d.LastSeen, _ = time.Parse(time.RFC3339, lastSeenStr)
-	}
-	if firstSeenStr != "" {
-		d.FirstSeen, _ = time.Parse(time.RFC3339, firstSeenStr)
-	}
 	return &d, nil
 }
 
@@ -167,16 +280,139 @@ func (r *SQLiteRepository) UpdateTags(id string, tags []string) error {
 	return err
 }
 
+func (r *SQLiteRepository) SetTags(tags map[string][]string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for id, t := range tags {
+		b, _ := json.Marshal(t)
+		if _, err := tx.Exec(`UPDATE devices SET tags = ? WHERE id = ?`, string(b), id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteRepository) TagCounts() (map[string]int, error) {
+	rows, err := r.db.Query(`
+		SELECT json_each.value, COUNT(*)
+		FROM devices, json_each(devices.tags)
+		WHERE json_each.type = 'text'
+		GROUP BY json_each.value
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var tag string
+		var n int
+		if err := rows.Scan(&tag, &n); err != nil {
+			return nil, err
+		}
+		counts[tag] = n
+	}
+	return counts, rows.Err()
+}
+
+func (r *SQLiteRepository) List(q DeviceQuery) (model.DevicePage, error) {
+	page := model.DevicePage{Devices: []model.Device{}}
+	field, err := q.sortField()
+	if err != nil {
+		return page, err
+	}
+	cursor, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return page, err
+	}
+
+	var where []string
+	var args []any
+	if q.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, q.Status)
+	}
+	if q.Tag != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM json_each(devices.tags) WHERE json_each.value = ?)")
+		args = append(args, q.Tag)
+	}
+	if q.Type != "" {
+		where = append(where, "device_type = ?")
+		args = append(args, q.Type)
+	}
+	if q.Manufacturer != "" {
+		where = append(where, "instr(lower(manufacturer), lower(?)) > 0")
+		args = append(args, q.Manufacturer)
+	}
+	if !q.SeenSince.IsZero() {
+		where = append(where, "last_seen >= ?")
+		args = append(args, q.SeenSince.UTC().Format(time.RFC3339))
+	}
+	for _, e := range []Expr{cidrExpr(q.CIDR), q.Match} {
+		if e != nil {
+			s, a := e.sql()
+			where = append(where, "("+s+")")
+			args = append(args, a...)
+		}
+	}
+	filter := ""
+	if len(where) > 0 {
+		filter = ` WHERE ` + strings.Join(where, " AND ")
+	}
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM devices`+filter, args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	col, dir, cmp := deviceSortColumns[field], "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if cursor != nil {
+		where = append(where, "("+col+" "+cmp+" ? OR ("+col+" = ? AND id "+cmp+" ?))")
+		args = append(args, cursor.Key, cursor.Key, cursor.ID)
+	}
+	query := `SELECT ` + deviceColumns + ` FROM devices`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY ` + col + ` ` + dir + `, id ` + dir
+	if q.Limit > 0 {
+		// One extra row tells whether there is a next page.
+		query += ` LIMIT ?`
+		args = append(args, q.Limit+1)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if d, err := scanDevice(rows); err == nil {
+			page.Devices = append(page.Devices, d)
+		}
+	}
+	if q.Limit > 0 && len(page.Devices) > q.Limit {
+		page.Devices = page.Devices[:q.Limit]
+		last := page.Devices[len(page.Devices)-1]
+		page.NextCursor = encodeCursor(deviceCursor{Key: sortKey(last, field), ID: last.ID})
+	}
+	return page, nil
+}
+
 func (r *SQLiteRepository) Search(q string) ([]model.Device, error) {
 	like := "%" + q + "%"
 	rows, err := r.db.Query(`
-    SELECT id, ip_address, mac_address, hostname, status, manufacturer, tags, last_seen, first_seen
+    SELECT `+deviceColumns+`
     FROM devices
     WHERE ip_address LIKE ? 
     OR mac_address LIKE ? 
     OR hostname LIKE ? 
     OR manufacturer LIKE ?
-    OR tags LIKE ?
+    OR EXISTS (SELECT 1 FROM json_each(devices.tags) WHERE json_each.value LIKE ?)
 `, like, like, like, like, like)
 
 	if err != nil {
@@ -186,17 +422,7 @@ func (r *SQLiteRepository) Search(q string) ([]model.Device, error) {
 
 	var out []model.Device
 	for rows.Next() {
-		var d model.Device
-		var tagsRaw string
-		var lastSeenStr, firstSeenStr string
-		if err := rows.Scan(&d.ID, &d.IPAddress, &d.MACAddress, &d.Hostname, &d.Status, &d.Manufacturer, &tagsRaw, &lastSeenStr, &firstSeenStr); err == nil {
-			_ = json.Unmarshal([]byte(defaultIfEmpty(tagsRaw, "[]")), &d.Tags)
-			if lastSeenStr != "" {
-				d.LastSeen, _ = time.Parse(time.RFC3339, lastSeenStr)
-			}
-			if firstSeenStr != "" {
-				d.FirstSeen, _ = time.Parse(time.RFC3339, firstSeenStr)
-			}
+		if d, err := scanDevice(rows); err == nil {
 			out = append(out, d)
 		}
 	}
@@ -205,13 +431,13 @@ func (r *SQLiteRepository) Search(q string) ([]model.Device, error) {
 
 func (r *SQLiteRepository) FindByIP(ip string) *model.Device {
 	row := r.db.QueryRow(`
-		SELECT id, ip_address, mac_address, hostname, status, manufacturer, tags, last_seen, first_seen
-		FROM devices WHERE ip_address = ?
-	`, ip)
-	var d model.Device
-	var tagsRaw string
-	var lastSeenStr, firstSeenStr string
-	err := row.Scan(&d.ID, &d.IPAddress, &d.MACAddress, &d.Hostname, &d.Status, &d.Manufacturer, &tagsRaw, &lastSeenStr, &firstSeenStr)
+		SELECT `+deviceColumns+` FROM devices
+		WHERE ip_address = ?
+		   OR EXISTS (SELECT 1 FROM json_each(devices.ipv6_addresses) WHERE json_each.value = ?)
+		ORDER BY ip_address = ? DESC
+		LIMIT 1
+	`, ip, ip, ip)
+	d, err := scanDevice(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			r.logger.Debug("Device not found in DB:", ip)
@@ -220,6 +446,40 @@ func (r *SQLiteRepository) FindByIP(ip string) *model.Device {
 		r.logger.Error("SQLite FindByIP error:", err)
 		return nil
 	}
+	return &d
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *SQLiteRepository) FindByMAC(mac string) *model.Device {
+	if mac == "" {
+		return nil
+	}
+	row := r.db.QueryRow(`SELECT `+deviceColumns+` FROM devices WHERE lower(mac_address) = lower(?) LIMIT 1`, mac)
+	d, err := scanDevice(row)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			r.logger.Error("SQLite FindByMAC error:", err)
+		}
+		return nil
+	}
+	return &d
+}
+
+const deviceColumns = `id, ip_address, mac_address, hostname, status, manufacturer, tags, last_seen, first_seen, liveness_method, ipv6_addresses, flap_count, flapping, device_type, type_confidence, type_manual, os_guess`
+
+func scanDevice(row rowScanner) (model.Device, error) {
+	var d model.Device
+	var tagsRaw string
+	var lastSeenStr, firstSeenStr string
+	var liveness, ipv6Raw, osRaw sql.NullString
+	if err := row.Scan(&d.ID, &d.IPAddress, &d.MACAddress, &d.Hostname, &d.Status, &d.Manufacturer, &tagsRaw, &lastSeenStr, &firstSeenStr, &liveness, &ipv6Raw, &d.FlapCount, &d.Flapping, &d.DeviceType, &d.TypeConfidence, &d.TypeManual, &osRaw); err != nil {
+		return d, err
+	}
+	_ = json.Unmarshal([]byte(defaultIfEmpty(ipv6Raw.String, "null")), &d.IPv6Addresses)
+	_ = json.Unmarshal([]byte(defaultIfEmpty(osRaw.String, "null")), &d.OS)
 	_ = json.Unmarshal([]byte(defaultIfEmpty(tagsRaw, "[]")), &d.Tags)
 	if lastSeenStr != "" {
 		d.LastSeen, _ = time.Parse(time.RFC3339, lastSeenStr)
@@ -227,7 +487,8 @@ func (r *SQLiteRepository) FindByIP(ip string) *model.Device {
 	if firstSeenStr != "" {
 		d.FirstSeen, _ = time.Parse(time.RFC3339, firstSeenStr)
 	}
-	return &d
+	d.LivenessMethod = liveness.String
+	return d, nil
 }
 
 func defaultIfEmpty(s, def string) string {
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLiteScanRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+func NewSQLiteScanRepository(db *sql.DB, logger logger.Logger) *SQLiteScanRepository {
+	if err := ensureScansTable(db); err != nil {
+		logger.Error("failed to create scans table", err)
+	}
+	return &SQLiteScanRepository{db: db, logger: logger}
+}
+
+func ensureScansTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS scans (
+			id TEXT PRIMARY KEY,
+			range TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT,
+			hosts_total INTEGER,
+			hosts_probed INTEGER,
+			hosts_online INTEGER,
+			created_at DATETIME,
+			started_at DATETIME,
+			finished_at DATETIME
+		);
+		CREATE INDEX IF NOT EXISTS idx_scans_created ON scans(created_at);
+	`)
+	if err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "scans", "exclude", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "scans", "ports", "TEXT"); err != nil {
+		return err
+	}
+	return ensureColumn(db, "scans", "group_ref", "TEXT")
+}
+
+func (r *SQLiteScanRepository) Save(j model.ScanJob) error {
+	excludeJSON, _ := json.Marshal(j.Exclude)
+	_, err := r.db.Exec(`
+		INSERT OR REPLACE INTO scans
+			(id, range, exclude, ports, group_ref, status, error, hosts_total, hosts_probed, hosts_online, created_at, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		j.ID,
+		j.Range,
+		string(excludeJSON),
+		j.Ports,
+		j.Group,
+		j.Status,
+		j.Error,
+		j.HostsTotal,
+		j.HostsProbed,
+		j.HostsOnline,
+		j.CreatedAt.UTC().Format(time.RFC3339),
+		j.StartedAt.UTC().Format(time.RFC3339),
+		j.FinishedAt.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+func (r *SQLiteScanRepository) FindByID(id string) (*model.ScanJob, error) {
+	row := r.db.QueryRow(`
+		SELECT id, range, exclude, ports, group_ref, status, error, hosts_total, hosts_probed, hosts_online, created_at, started_at, finished_at
+		FROM scans WHERE id = ?
+	`, id)
+	j, err := scanJobRow(row)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (r *SQLiteScanRepository) GetAll() ([]model.ScanJob, error) {
+	rows, err := r.db.Query(`
+		SELECT id, range, exclude, ports, group_ref, status, error, hosts_total, hosts_probed, hosts_online, created_at, started_at, finished_at
+		FROM scans ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.ScanJob
+	for rows.Next() {
+		if j, err := scanJobRow(rows); err == nil {
+			out = append(out, j)
+		}
+	}
+	return out, nil
+}
+
+func scanJobRow(row rowScanner) (model.ScanJob, error) {
+	var j model.ScanJob
+	var excludeRaw, ports, group, errText sql.NullString
+	var createdStr, startedStr, finishedStr string
+	if err := row.Scan(&j.ID, &j.Range, &excludeRaw, &ports, &group, &j.Status, &errText, &j.HostsTotal, &j.HostsProbed, &j.HostsOnline, &createdStr, &startedStr, &finishedStr); err != nil {
+		return j, err
+	}
+	_ = json.Unmarshal([]byte(defaultIfEmpty(excludeRaw.String, "null")), &j.Exclude)
+	j.Ports = ports.String
+	j.Group = group.String
+	j.Error = errText.String
+	j.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
+	j.StartedAt, _ = time.Parse(time.RFC3339, startedStr)
+	j.FinishedAt, _ = time.Parse(time.RFC3339, finishedStr)
+	return j, nil
+}
+
+var _ ScanRepository = (*SQLiteScanRepository)(nil)
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLiteTagRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+func NewSQLiteTagRepository(db *sql.DB, logger logger.Logger) *SQLiteTagRepository {
+	if err := ensureTagsTable(db); err != nil {
+		logger.Error("failed to create tags table", err)
+	}
+	return &SQLiteTagRepository{db: db, logger: logger}
+}
+
+func ensureTagsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS tags (
+			name TEXT PRIMARY KEY,
+			color TEXT,
+			description TEXT,
+			created_at DATETIME NOT NULL
+		);
+	`)
+	return err
+}
+
+const saveTagSQL = `
+	INSERT INTO tags (name, color, description, created_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT(name) DO UPDATE SET
+		color = excluded.color,
+		description = excluded.description
+`
+
+func (r *SQLiteTagRepository) Save(t model.Tag) error {
+	_, err := r.db.Exec(saveTagSQL, t.Name, t.Color, t.Description, t.CreatedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+const tagColumns = `name, color, description, created_at`
+
+func (r *SQLiteTagRepository) GetAll() ([]model.Tag, error) {
+	rows, err := r.db.Query(`SELECT ` + tagColumns + ` FROM tags ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []model.Tag{}
+	for rows.Next() {
+		if t, err := scanTag(rows); err == nil {
+			out = append(out, t)
+		}
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteTagRepository) FindByName(name string) (*model.Tag, error) {
+	t, err := scanTag(r.db.QueryRow(`SELECT `+tagColumns+` FROM tags WHERE name = ?`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *SQLiteTagRepository) Ensure(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, name := range names {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (name, created_at) VALUES (?, ?)`, name, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteTagRepository) Delete(name string) error {
+	_, err := r.db.Exec(`DELETE FROM tags WHERE name = ?`, name)
+	return err
+}
+
+// Cascade updates the devices table directly, so the repository must share
+// its database with the device repository.
+func (r *SQLiteTagRepository) Cascade(name string, to *model.Tag, devices map[string][]string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for id, t := range devices {
+		b, _ := json.Marshal(t)
+		if _, err := tx.Exec(`UPDATE devices SET tags = ? WHERE id = ?`, string(b), id); err != nil {
+			return err
+		}
+	}
+	if to != nil {
+		if _, err := tx.Exec(saveTagSQL, to.Name, to.Color, to.Description, to.CreatedAt.UTC().Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM tags WHERE name = ?`, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanTag(row rowScanner) (model.Tag, error) {
+	var t model.Tag
+	var color, description sql.NullString
+	var created string
+	if err := row.Scan(&t.Name, &color, &description, &created); err != nil {
+		return t, err
+	}
+	t.Color = color.String
+	t.Description = description.String
+	t.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	return t, nil
+}
+
+var _ TagRepository = (*SQLiteTagRepository)(nil)
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"network-scanner/logger"
+	"network-scanner/model"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type SQLiteTagRuleRepository struct {
+	db     *sql.DB
+	logger logger.Logger
+}
+
+func NewSQLiteTagRuleRepository(db *sql.DB, logger logger.Logger) *SQLiteTagRuleRepository {
+	if err := ensureTagRulesTable(db); err != nil {
+		logger.Error("failed to create tag_rules table", err)
+	}
+	return &SQLiteTagRuleRepository{db: db, logger: logger}
+}
+
+func ensureTagRulesTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS tag_rules (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			enabled INTEGER NOT NULL DEFAULT 1,
+			conditions TEXT NOT NULL,
+			tags TEXT NOT NULL,
+			stop INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		);
+	`)
+	return err
+}
+
+func (r *SQLiteTagRuleRepository) Save(rule model.TagRule) error {
+	matchJSON, _ := json.Marshal(rule.Match)
+	tagsJSON, _ := json.Marshal(rule.Tags)
+	_, err := r.db.Exec(`
+		INSERT OR REPLACE INTO tag_rules (id, name, position, enabled, conditions, tags, stop, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, rule.ID, rule.Name, rule.Position, rule.Enabled, string(matchJSON), string(tagsJSON), rule.Stop,
+		rule.CreatedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+const tagRuleColumns = `id, name, position, enabled, conditions, tags, stop, created_at`
+
+func (r *SQLiteTagRuleRepository) GetAll() ([]model.TagRule, error) {
+	rows, err := r.db.Query(`SELECT ` + tagRuleColumns + ` FROM tag_rules ORDER BY position, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []model.TagRule{}
+	for rows.Next() {
+		if rule, err := scanTagRule(rows); err == nil {
+			out = append(out, rule)
+		}
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteTagRuleRepository) FindByID(id string) (*model.TagRule, error) {
+	rule, err := scanTagRule(r.db.QueryRow(`SELECT `+tagRuleColumns+` FROM tag_rules WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *SQLiteTagRuleRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM tag_rules WHERE id = ?`, id)
+	return err
+}
+
+func scanTagRule(row rowScanner) (model.TagRule, error) {
+	var rule model.TagRule
+	var matchRaw, tagsRaw, created string
+	if err := row.Scan(&rule.ID, &rule.Name, &rule.Position, &rule.Enabled, &matchRaw, &tagsRaw, &rule.Stop, &created); err != nil {
+		return rule, err
+	}
+	_ = json.Unmarshal([]byte(matchRaw), &rule.Match)
+	_ = json.Unmarshal([]byte(tagsRaw), &rule.Tags)
+	rule.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	return rule, nil
+}
+
+var _ TagRuleRepository = (*SQLiteTagRuleRepository)(nil)
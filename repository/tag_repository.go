@@ -0,0 +1,17 @@
+package repository
+
+import "network-scanner/model"
+
+type TagRepository interface {
+	Save(t model.Tag) error
+	GetAll() ([]model.Tag, error)
+	FindByName(name string) (*model.Tag, error)
+	// Ensure registers the names that are not known yet, without touching
+	// existing entries.
+	Ensure(names []string) error
+	Delete(name string) error
+	// Cascade renames or deletes the tag name: it replaces the tags of the
+	// given devices, keyed by device ID, saves to unless it is nil and
+	// removes name from the registry, all in one transaction.
+	Cascade(name string, to *model.Tag, devices map[string][]string) error
+}
@@ -0,0 +1,11 @@
+package repository
+
+import "network-scanner/model"
+
+type TagRuleRepository interface {
+	Save(r model.TagRule) error
+	// GetAll returns the rules in evaluation order.
+	GetAll() ([]model.TagRule, error)
+	FindByID(id string) (*model.TagRule, error)
+	Delete(id string) error
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"network-scanner/model"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBannerLen caps the banner and title stored per port.
+const maxBannerLen = 256
+
+// bannerProbe is one attempt at making a service identify itself. A probe
+// without a request waits for the server to speak first.
+type bannerProbe struct {
+	request func(ip string) []byte
+	// done reports whether a response is complete, so the read can stop
+	// before the deadline.
+	done func(resp []byte) bool
+}
+
+var (
+	greetingProbe = bannerProbe{done: greetingDone}
+	httpProbe     = bannerProbe{
+		request: func(ip string) []byte {
+			host := ip
+			if isIPv6(ip) {
+				host = "[" + ip + "]"
+			}
+			return []byte("GET / HTTP/1.0\r\nHost: " + host + "\r\nUser-Agent: network-scanner\r\nAccept: text/html\r\n\r\n")
+		},
+		done: func(resp []byte) bool { return bytes.Contains(bytes.ToLower(resp), []byte("</title>")) },
+	}
+	redisProbe = bannerProbe{
+		request: func(string) []byte { return []byte("INFO server\r\n") },
+		done:    redisDone,
+	}
+)
+
+// redisPorts are tried with the Redis probe before HTTP.
+var redisPorts = []int{6379, 6380}
+
+// probesFor lists the probes for a port in the order they are tried. Servers
+// that greet are always given the first chance, since a client-first probe
+// can make them hang up.
+func probesFor(port int) []bannerProbe {
+	if slices.Contains(redisPorts, port) {
+		return []bannerProbe{greetingProbe, redisProbe, httpProbe}
+	}
+	return []bannerProbe{greetingProbe, httpProbe, redisProbe}
+}
+
+// detectServices identifies the service behind every open port in ports,
+// updating them in place. Ports that cannot be identified keep what an
+// earlier scan found.
+func (s *ScannerService) detectServices(ctx context.Context, ip string, ports []model.Port) {
+	var open []int
+	for i, p := range ports {
+		if p.State == model.PortOpen && p.Protocol == "tcp" {
+			open = append(open, i)
+		}
+	}
+	runParallel(ctx, s.cfg.PortScan.Concurrency, open, func(i int) {
+		if info, ok := s.grabBanner(ctx, ip, ports[i].Port); ok {
+			ports[i].Service = info.Service
+			ports[i].Version = info.Version
+			ports[i].Banner = info.Banner
+			ports[i].Title = info.Title
+		}
+	})
+}
+
+// grabBanner runs the probes for a port until one gets a response, all
+// within the configured timeout. The greeting probe only gets a quarter of
+// it, since most client-first services never send anything.
+func (s *ScannerService) grabBanner(ctx context.Context, ip string, port int) (model.Port, bool) {
+	cfg := s.cfg.PortScan.Banners
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	var fallback model.Port
+	for i, probe := range probesFor(port) {
+		wait := deadline
+		if i == 0 {
+			wait = time.Now().Add(cfg.Timeout / 4)
+		}
+		resp := s.exchange(ctx, addr, probe, wait, cfg.MaxBytes)
+		if ctx.Err() != nil {
+			break
+		}
+		if len(resp) == 0 {
+			continue
+		}
+		info, ok := identifyService(resp, port)
+		if ok {
+			return info, true
+		}
+		if fallback.Banner == "" {
+			fallback = info
+		}
+	}
+	return fallback, fallback.Banner != ""
+}
+
+// exchange sends the probe's request on a fresh connection and reads the
+// response until it is complete, the connection closes, wait passes or
+// maxBytes have been read.
+func (s *ScannerService) exchange(ctx context.Context, addr string, probe bannerProbe, wait time.Time, maxBytes int) []byte {
+	if err := s.probes.Acquire(ctx, 1); err != nil {
+		return nil
+	}
+	defer s.probes.Release(1)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	conn.SetDeadline(wait)
+	if probe.request != nil {
+		host, _, _ := net.SplitHostPort(addr)
+		if _, err := conn.Write(probe.request(host)); err != nil {
+			return nil
+		}
+	}
+
+	buf := make([]byte, 0, min(maxBytes, 1024))
+	chunk := make([]byte, 1024)
+	for len(buf) < maxBytes {
+		n, err := conn.Read(chunk[:min(len(chunk), maxBytes-len(buf))])
+		buf = append(buf, chunk[:n]...)
+		if err != nil || probe.done(buf) {
+			break
+		}
+	}
+	return buf
+}
+
+// greetingDone accepts a first line of text or a complete MySQL packet.
+func greetingDone(resp []byte) bool {
+	if n, ok := mysqlPacketLen(resp); ok {
+		return len(resp) >= 4+n
+	}
+	return bytes.IndexByte(resp, '\n') >= 0
+}
+
+func redisDone(resp []byte) bool {
+	if len(resp) > 0 && (resp[0] == '-' || resp[0] == '+') {
+		return bytes.IndexByte(resp, '\n') >= 0
+	}
+	i := bytes.Index(resp, []byte("redis_version:"))
+	return i >= 0 && bytes.IndexByte(resp[i:], '\n') >= 0
+}
+
+// mysqlPacketLen reads the header of a MySQL packet: a three byte
+// little-endian payload length and a sequence number of 0.
+func mysqlPacketLen(resp []byte) (int, bool) {
+	if len(resp) < 5 || resp[3] != 0 {
+		return 0, false
+	}
+	n := int(resp[0]) | int(resp[1])<<8 | int(resp[2])<<16
+	// A handshake starts with protocol version 10, an error with 0xff.
+	if n == 0 || n > 1<<16 || (resp[4] != 10 && resp[4] != 0xff) {
+		return 0, false
+	}
+	return n, true
+}
+
+var (
+	titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	// productVersionRe finds "Product 1.2.3" or "Product/1.2.3" in a
+	// greeting.
+	productVersionRe = regexp.MustCompile(`([A-Za-z][\w-]*)[ /_]v?(\d+(?:\.\d+)+[\w.-]*)`)
+)
+
+// identifyService recognises a response to any of the probes. When the
+// protocol is unknown it still returns the banner, with ok false.
+func identifyService(resp []byte, port int) (model.Port, bool) {
+	if n, ok := mysqlPacketLen(resp); ok {
+		payload := resp[4:min(len(resp), 4+n)]
+		info := model.Port{Service: "mysql"}
+		if payload[0] == 10 {
+			version, _, _ := bytes.Cut(payload[1:], []byte{0})
+			info.Version = cleanBanner(string(version))
+		}
+		info.Banner = cleanBanner(string(payload[1:]))
+		return info, true
+	}
+
+	text := string(resp)
+	first, _, _ := strings.Cut(text, "\n")
+	info := model.Port{Banner: cleanBanner(first)}
+	switch {
+	case strings.HasPrefix(text, "SSH-"):
+		// SSH-protoversion-softwareversion SP comments
+		info.Service = "ssh"
+		if parts := strings.SplitN(strings.TrimSpace(first), "-", 3); len(parts) == 3 {
+			info.Version = cleanBanner(parts[2])
+		}
+	case strings.HasPrefix(text, "HTTP/"):
+		info.Service = "http"
+		head, body, _ := strings.Cut(text, "\r\n\r\n")
+		for _, line := range strings.Split(head, "\r\n")[1:] {
+			if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "server") {
+				info.Version = cleanBanner(value)
+			}
+		}
+		if m := titleRe.FindStringSubmatch(body); m != nil {
+			info.Title = cleanBanner(m[1])
+		}
+	case strings.HasPrefix(text, "220"):
+		info.Service = "smtp"
+		if port == 21 || strings.Contains(strings.ToLower(first), "ftp") {
+			info.Service = "ftp"
+		}
+		if m := productVersionRe.FindStringSubmatch(first); m != nil {
+			info.Version = m[1] + " " + m[2]
+		}
+	case strings.Contains(text, "redis_version:"):
+		info.Service = "redis"
+		_, rest, _ := strings.Cut(text, "redis_version:")
+		version, _, _ := strings.Cut(rest, "\n")
+		info.Version = cleanBanner(version)
+		info.Banner = ""
+	case strings.HasPrefix(text, "-NOAUTH"), strings.HasPrefix(text, "-DENIED"):
+		info.Service = "redis"
+	default:
+		return info, false
+	}
+	return info, true
+}
+
+// cleanBanner keeps the printable ASCII of s, collapses whitespace and
+// truncates the result to maxBannerLen.
+func cleanBanner(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\r' || r == '\n':
+			return ' '
+		case r < 0x20 || r > 0x7e:
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxBannerLen {
+		s = s[:maxBannerLen]
+	}
+	return s
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"network-scanner/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// greeter sends banner as soon as a client connects.
+func greeter(banner string) func(net.Conn) {
+	return func(conn net.Conn) {
+		io.WriteString(conn, banner)
+		io.Copy(io.Discard, conn)
+	}
+}
+
+// responder answers the first request line that starts with prefix, and
+// hangs up on anything else.
+func responder(prefix, response string) func(net.Conn) {
+	return func(conn net.Conn) {
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil || !strings.HasPrefix(line, prefix) {
+			return
+		}
+		io.WriteString(conn, response)
+	}
+}
+
+func bannerService(timeout time.Duration) *ScannerService {
+	cfg := DefaultScannerConfig()
+	cfg.PortScan.Banners.Timeout = timeout
+	return NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithConfig(cfg)
+}
+
+func TestGrabBanner_LocalServers(t *testing.T) {
+	mysqlHandshake := "\x0a8.0.36\x00\x08\x00\x00\x00abcdefgh\x00\xff\xf7"
+	cases := []struct {
+		name   string
+		handle func(net.Conn)
+		want   model.Port
+	}{
+		{
+			"ssh",
+			greeter("SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n"),
+			model.Port{Service: "ssh", Version: "OpenSSH_9.6p1 Ubuntu-3ubuntu13", Banner: "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13"},
+		},
+		{
+			"http",
+			responder("GET / ", "HTTP/1.1 200 OK\r\nServer: nginx/1.24.0\r\nContent-Type: text/html\r\n\r\n<html><head><title>\n  Router Login </title></head></html>"),
+			model.Port{Service: "http", Version: "nginx/1.24.0", Banner: "HTTP/1.1 200 OK", Title: "Router Login"},
+		},
+		{
+			"smtp",
+			greeter("220 mx.example.com ESMTP Exim 4.96 Mon, 01 Jan 2024\r\n"),
+			model.Port{Service: "smtp", Version: "Exim 4.96", Banner: "220 mx.example.com ESMTP Exim 4.96 Mon, 01 Jan 2024"},
+		},
+		{
+			"ftp",
+			greeter("220 (vsFTPd 3.0.5)\r\n"),
+			model.Port{Service: "ftp", Version: "vsFTPd 3.0.5", Banner: "220 (vsFTPd 3.0.5)"},
+		},
+		{
+			"redis",
+			responder("INFO", "$52\r\n# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n\r\n"),
+			model.Port{Service: "redis", Version: "7.2.4"},
+		},
+		{
+			"mysql",
+			greeter(string([]byte{byte(len(mysqlHandshake)), 0, 0, 0}) + mysqlHandshake),
+			model.Port{Service: "mysql", Version: "8.0.36", Banner: "8.0.36abcdefgh"},
+		},
+	}
+
+	svc := bannerService(2 * time.Second)
+	for _, c := range cases {
+		port, _ := listenLocal(t, c.handle)
+		got, ok := svc.grabBanner(context.Background(), "127.0.0.1", port)
+		if !ok || got != c.want {
+			t.Errorf("%s: expected %+v, got %+v (ok=%v)", c.name, c.want, got, ok)
+		}
+	}
+}
+
+func TestGrabBanner_SilentServerTimesOut(t *testing.T) {
+	port, _ := listenLocal(t, func(conn net.Conn) { io.Copy(io.Discard, conn) })
+	svc := bannerService(200 * time.Millisecond)
+
+	start := time.Now()
+	got, ok := svc.grabBanner(context.Background(), "127.0.0.1", port)
+	if ok {
+		t.Errorf("expected nothing from a silent server, got %+v", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the banner timeout to bound the grab, took %v", elapsed)
+	}
+}
+
+func TestGrabBanner_SizeLimit(t *testing.T) {
+	port, _ := listenLocal(t, func(conn net.Conn) {
+		junk := strings.Repeat("A", 1024)
+		for {
+			if _, err := io.WriteString(conn, junk); err != nil {
+				return
+			}
+		}
+	})
+	svc := bannerService(time.Second)
+	svc.cfg.PortScan.Banners.MaxBytes = 64
+
+	got, ok := svc.grabBanner(context.Background(), "127.0.0.1", port)
+	if !ok || got.Service != "" || got.Banner != strings.Repeat("A", 64) {
+		t.Errorf("expected an unidentified 64 byte banner, got %+v (ok=%v)", got, ok)
+	}
+}
+
+func TestCleanBanner(t *testing.T) {
+	if got := cleanBanner("  SSH-2.0-x\x00\x1b[31m\t\r\n"); got != "SSH-2.0-x[31m" {
+		t.Errorf("expected control characters to be dropped, got %q", got)
+	}
+	if got := cleanBanner(strings.Repeat("ab", maxBannerLen)); len(got) != maxBannerLen {
+		t.Errorf("expected the banner to be truncated to %d, got %d", maxBannerLen, len(got))
+	}
+}
+
+func TestScanDevicePorts_DetectsServices(t *testing.T) {
+	sshPort, _ := listenLocal(t, greeter("SSH-2.0-dropbear_2022.83\r\n"))
+	silentPort, _ := listenLocal(t, func(conn net.Conn) { io.Copy(io.Discard, conn) })
+	svc := bannerService(200 * time.Millisecond)
+	d := model.Device{ID: "d1", IPAddress: "127.0.0.1"}
+
+	// A service identified once is kept when a later scan cannot tell.
+	svc.ports.SavePorts("d1", []model.Port{{Port: silentPort, Protocol: "tcp", State: model.PortOpen, Service: "telnet"}})
+	svc.scanDevicePorts(context.Background(), d, []int{sshPort, silentPort})
+
+	ports, _ := svc.ports.FindByDevice("d1")
+	services := map[int]string{}
+	for _, p := range ports {
+		services[p.Port] = p.Service + " " + p.Version
+	}
+	if services[sshPort] != "ssh dropbear_2022.83" {
+		t.Errorf("expected ssh on %d, got %q", sshPort, services[sshPort])
+	}
+	if services[silentPort] != "telnet " {
+		t.Errorf("expected the earlier service on %d to be kept, got %q", silentPort, services[silentPort])
+	}
+
+	svc.cfg.PortScan.Banners.Enabled = false
+	svc.ports.Clear()
+	svc.scanDevicePorts(context.Background(), d, []int{sshPort})
+	ports, _ = svc.ports.FindByDevice("d1")
+	if len(ports) != 1 || ports[0].Service != "" {
+		t.Errorf("expected no detection when disabled, got %+v", ports)
+	}
+}
+
+func TestClassifyInput_Banners(t *testing.T) {
+	svc := groupFixture(t)
+	svc.ports.SavePorts("s1", []model.Port{
+		{Port: 22, Protocol: "tcp", State: model.PortOpen, Service: "ssh", Version: "OpenSSH_9.6p1", Banner: "SSH-2.0-OpenSSH_9.6p1"},
+		{Port: 80, Protocol: "tcp", State: model.PortClosed, Service: "http", Banner: "HTTP/1.1 200 OK"},
+	})
+	d, _ := svc.FindByID("s1")
+	in := svc.classifyInput(*d)
+	if len(in.Ports) != 1 || len(in.Banners) != 2 || in.Banners[0] != "SSH-2.0-OpenSSH_9.6p1" {
+		t.Errorf("expected the open port and its banners, got %+v", in)
+	}
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"network-scanner/model"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidDeviceType = errors.New("invalid device type")
+
+// minConfidence is the score below which a device is left unclassified.
+const minConfidence = 0.3
+
+// hints weighs the evidence a signal gives for each candidate, such as a
+// device type, from 0 (none) to 1 (certain).
+type hints map[string]float64
+
+// scores combines hints as independent evidence: a candidate scores
+// 1 - Π(1 - weight) over every hint for it.
+type scores map[string]float64
+
+func (s scores) add(hs hints) {
+	for c, weight := range hs {
+		if _, ok := s[c]; !ok {
+			s[c] = 1
+		}
+		s[c] *= 1 - weight
+	}
+}
+
+// best returns the highest scoring candidate, rounded to two decimals, with
+// ties going to the earlier one. Below minConfidence it returns "".
+func (s scores) best(candidates []string) (string, float64) {
+	best, score := "", 0.0
+	for _, c := range candidates {
+		if miss, ok := s[c]; ok && 1-miss > score {
+			best, score = c, 1-miss
+		}
+	}
+	if score < minConfidence {
+		return "", 0
+	}
+	return best, math.Round(score*100) / 100
+}
+
+// manufacturerHints are matched as lower-case substrings of the manufacturer.
+var manufacturerHints = []struct {
+	substr string
+	hints  hints
+}{
+	{"cisco", hints{model.TypeRouter: 0.4, model.TypeSwitch: 0.4}},
+	{"juniper", hints{model.TypeRouter: 0.6}},
+	{"mikrotik", hints{model.TypeRouter: 0.7}},
+	{"routerboard", hints{model.TypeRouter: 0.7}},
+	{"ubiquiti", hints{model.TypeRouter: 0.5}},
+	{"tp-link", hints{model.TypeRouter: 0.5}},
+	{"netgear", hints{model.TypeRouter: 0.5}},
+	{"d-link", hints{model.TypeRouter: 0.5}},
+	{"zyxel", hints{model.TypeRouter: 0.5}},
+	{"arris", hints{model.TypeRouter: 0.6}},
+	{"technicolor", hints{model.TypeRouter: 0.6}},
+	{"sagemcom", hints{model.TypeRouter: 0.6}},
+	{"arista", hints{model.TypeSwitch: 0.7}},
+	{"extreme networks", hints{model.TypeSwitch: 0.6}},
+	{"brocade", hints{model.TypeSwitch: 0.6}},
+	{"brother", hints{model.TypePrinter: 0.7}},
+	{"canon", hints{model.TypePrinter: 0.6}},
+	{"epson", hints{model.TypePrinter: 0.7}},
+	{"lexmark", hints{model.TypePrinter: 0.8}},
+	{"xerox", hints{model.TypePrinter: 0.8}},
+	{"kyocera", hints{model.TypePrinter: 0.7}},
+	{"ricoh", hints{model.TypePrinter: 0.7}},
+	{"konica", hints{model.TypePrinter: 0.7}},
+	{"hp inc", hints{model.TypePrinter: 0.3, model.TypeWorkstation: 0.2}},
+	{"apple", hints{model.TypePhone: 0.3, model.TypeWorkstation: 0.3}},
+	{"samsung", hints{model.TypePhone: 0.4}},
+	{"xiaomi", hints{model.TypePhone: 0.4, model.TypeIoT: 0.2}},
+	{"huawei", hints{model.TypePhone: 0.3, model.TypeRouter: 0.2}},
+	{"oneplus", hints{model.TypePhone: 0.6}},
+	{"motorola", hints{model.TypePhone: 0.5}},
+	{"espressif", hints{model.TypeIoT: 0.7}},
+	{"tuya", hints{model.TypeIoT: 0.7}},
+	{"shelly", hints{model.TypeIoT: 0.7}},
+	{"sonos", hints{model.TypeIoT: 0.7}},
+	{"signify", hints{model.TypeIoT: 0.7}},
+	{"nest labs", hints{model.TypeIoT: 0.7}},
+	{"amazon", hints{model.TypeIoT: 0.4}},
+	{"raspberry", hints{model.TypeIoT: 0.3, model.TypeServer: 0.2}},
+	{"hikvision", hints{model.TypeCamera: 0.8}},
+	{"dahua", hints{model.TypeCamera: 0.8}},
+	{"axis communications", hints{model.TypeCamera: 0.7}},
+	{"reolink", hints{model.TypeCamera: 0.8}},
+	{"vivotek", hints{model.TypeCamera: 0.8}},
+	{"vmware", hints{model.TypeServer: 0.5}},
+	{"supermicro", hints{model.TypeServer: 0.5}},
+	{"synology", hints{model.TypeServer: 0.6}},
+	{"qnap", hints{model.TypeServer: 0.6}},
+	{"dell", hints{model.TypeWorkstation: 0.3, model.TypeServer: 0.2}},
+	{"lenovo", hints{model.TypeWorkstation: 0.4}},
+	{"intel", hints{model.TypeWorkstation: 0.3}},
+	{"realtek", hints{model.TypeWorkstation: 0.2}},
+}
+
+var hostnameHints = []struct {
+	re    *regexp.Regexp
+	hints hints
+}{
+	{regexp.MustCompile(`(?i)router|gateway|^(gw|rtr|fw)\d*([-_.]|$)|firewall`), hints{model.TypeRouter: 0.6}},
+	{regexp.MustCompile(`(?i)switch|(^|[-_.])sw\d*([-_.]|$)`), hints{model.TypeSwitch: 0.6}},
+	{regexp.MustCompile(`(?i)printer|laserjet|officejet|deskjet|^npi[0-9a-f]{6}`), hints{model.TypePrinter: 0.7}},
+	{regexp.MustCompile(`(?i)iphone|android|galaxy|pixel|phone`), hints{model.TypePhone: 0.7}},
+	{regexp.MustCompile(`(?i)esxi|proxmox|(^|[-_.])pve\d*([-_.]|$)|hyper-?v|xenserver`), hints{model.TypeHypervisor: 0.7}},
+	{regexp.MustCompile(`(?i)camera|(^|[-_.])(ip)?cam\d*([-_.]|$)|nvr|dvr`), hints{model.TypeCamera: 0.6}},
+	{regexp.MustCompile(`(?i)^desktop-|laptop|macbook|imac|workstation|(^|[-_.])(pc|ws)\d*([-_.]|$)`), hints{model.TypeWorkstation: 0.6}},
+	{regexp.MustCompile(`(?i)server|(^|[-_.])(srv|db|nas|web)\d*([-_.]|$)`), hints{model.TypeServer: 0.5}},
+	{regexp.MustCompile(`(?i)^esp[-_]|shelly|tasmota|sonoff|chromecast|echo|(^|[-_.])hue([-_.]|$)`), hints{model.TypeIoT: 0.6}},
+}
+
+// portHints map open TCP ports to the devices that usually expose them.
+var portHints = map[int]hints{
+	22:    {model.TypeServer: 0.2},
+	23:    {model.TypeRouter: 0.2, model.TypeSwitch: 0.2},
+	25:    {model.TypeServer: 0.4},
+	53:    {model.TypeRouter: 0.3},
+	139:   {model.TypeWorkstation: 0.2},
+	179:   {model.TypeRouter: 0.6},
+	445:   {model.TypeWorkstation: 0.2},
+	515:   {model.TypePrinter: 0.5},
+	554:   {model.TypeCamera: 0.5},
+	631:   {model.TypePrinter: 0.4},
+	902:   {model.TypeHypervisor: 0.6},
+	1433:  {model.TypeServer: 0.5},
+	1883:  {model.TypeIoT: 0.5},
+	3306:  {model.TypeServer: 0.5},
+	3389:  {model.TypeWorkstation: 0.4},
+	5432:  {model.TypeServer: 0.5},
+	5900:  {model.TypeWorkstation: 0.3},
+	6379:  {model.TypeServer: 0.5},
+	6668:  {model.TypeIoT: 0.5},
+	8006:  {model.TypeHypervisor: 0.7},
+	8009:  {model.TypeIoT: 0.5},
+	8883:  {model.TypeIoT: 0.5},
+	9100:  {model.TypePrinter: 0.7},
+	27017: {model.TypeServer: 0.5},
+	37777: {model.TypeCamera: 0.6},
+	62078: {model.TypePhone: 0.8},
+}
+
+// bannerHints are matched as lower-case substrings of service banners.
+var bannerHints = []struct {
+	substr string
+	hints  hints
+}{
+	{"cisco ios", hints{model.TypeRouter: 0.5, model.TypeSwitch: 0.5}},
+	{"routeros", hints{model.TypeRouter: 0.7}},
+	{"junos", hints{model.TypeRouter: 0.6}},
+	{"openwrt", hints{model.TypeRouter: 0.7}},
+	{"dd-wrt", hints{model.TypeRouter: 0.7}},
+	{"esxi", hints{model.TypeHypervisor: 0.8}},
+	{"proxmox", hints{model.TypeHypervisor: 0.8}},
+	{"hikvision", hints{model.TypeCamera: 0.7}},
+	{"webcam", hints{model.TypeCamera: 0.6}},
+	{"jetdirect", hints{model.TypePrinter: 0.7}},
+	{"cups", hints{model.TypePrinter: 0.5}},
+	{"printer", hints{model.TypePrinter: 0.5}},
+	{"openssh", hints{model.TypeServer: 0.3}},
+	{"nginx", hints{model.TypeServer: 0.3}},
+	{"apache", hints{model.TypeServer: 0.3}},
+	{"postfix", hints{model.TypeServer: 0.4}},
+	{"mysql", hints{model.TypeServer: 0.4}},
+	{"redis", hints{model.TypeServer: 0.4}},
+	{"microsoft-iis", hints{model.TypeServer: 0.4}},
+}
+
+// ttlHints guesses from the initial TTL of a reply: 255 is typical of
+// network operating systems and 128 of Windows.
+func ttlHints(ttl int) hints {
+	switch initialTTL(ttl) {
+	case 0, 32, 64:
+		return nil
+	case 128:
+		return hints{model.TypeWorkstation: 0.2}
+	default:
+		return hints{model.TypeRouter: 0.3, model.TypeSwitch: 0.3}
+	}
+}
+
+// classifyInput is what the classifier knows about a device.
+type classifyInput struct {
+	Manufacturer string
+	Hostname     string
+	Ports        []int
+	// TTL is the TTL of a reply to a liveness probe, 0 if unknown.
+	TTL     int
+	Banners []string
+}
+
+// classifyDevice scores every type from the hints that apply and returns
+// the best one, or "" if the evidence is too weak.
+func classifyDevice(in classifyInput) (string, float64) {
+	sc := scores{}
+
+	manufacturer := strings.ToLower(in.Manufacturer)
+	if manufacturer != "" && manufacturer != strings.ToLower(LocallyAdministered) {
+		for _, m := range manufacturerHints {
+			if strings.Contains(manufacturer, m.substr) {
+				sc.add(m.hints)
+				break
+			}
+		}
+	}
+	if in.Hostname != "" {
+		for _, h := range hostnameHints {
+			if h.re.MatchString(in.Hostname) {
+				sc.add(h.hints)
+			}
+		}
+	}
+	for _, p := range in.Ports {
+		sc.add(portHints[p])
+	}
+	sc.add(ttlHints(in.TTL))
+	for _, b := range in.Banners {
+		b = strings.ToLower(b)
+		for _, m := range bannerHints {
+			if strings.Contains(b, m.substr) {
+				sc.add(m.hints)
+			}
+		}
+	}
+
+	return sc.best(model.DeviceTypes)
+}
+
+func (s *ScannerService) classifyInput(d model.Device) classifyInput {
+	in := classifyInput{
+		Manufacturer: d.Manufacturer,
+		Hostname:     d.Hostname,
+	}
+	ports, err := s.ports.FindByDevice(d.ID)
+	if err != nil {
+		s.logger.Error("Failed to load ports of ", d.ID, ": ", err)
+	}
+	for _, p := range ports {
+		if p.State != model.PortOpen {
+			continue
+		}
+		in.Ports = append(in.Ports, p.Port)
+		for _, b := range []string{p.Banner, p.Version, p.Title} {
+			if b != "" {
+				in.Banners = append(in.Banners, b)
+			}
+		}
+	}
+	if d.OS != nil {
+		in.TTL = d.OS.TTL
+	}
+	return in
+}
+
+// classifyDevices reclassifies the given devices, or all of them when ids is
+// nil. Devices with a manual type are skipped.
+func (s *ScannerService) classifyDevices(ids []string) {
+	_, err := s.updateDevices(ids, func(d *model.Device) bool {
+		if d.TypeManual {
+			return false
+		}
+		typ, confidence := classifyDevice(s.classifyInput(*d))
+		if typ == d.DeviceType && confidence == d.TypeConfidence {
+			return false
+		}
+		d.DeviceType, d.TypeConfidence = typ, confidence
+		return true
+	})
+	if err != nil {
+		s.logger.Error("Failed to save device types: ", err)
+	}
+}
+
+// SetDeviceType overrides the classified type of a device. The override is
+// kept across rescans until cleared with ClearDeviceType.
+func (s *ScannerService) SetDeviceType(id, typ string) (*model.Device, error) {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if !slices.Contains(model.DeviceTypes, typ) {
+		return nil, fmt.Errorf("%w: %q, expected one of %s", ErrInvalidDeviceType, typ, strings.Join(model.DeviceTypes, ", "))
+	}
+	d, err := s.repo.FindByID(id)
+	if err != nil || d == nil {
+		return nil, err
+	}
+	old := *d
+	d.DeviceType, d.TypeConfidence, d.TypeManual = typ, 1, true
+	s.saveDevice(&old, *d)
+	return d, nil
+}
+
+// ClearDeviceType drops a manual override and classifies the device again.
+func (s *ScannerService) ClearDeviceType(id string) (*model.Device, error) {
+	d, err := s.repo.FindByID(id)
+	if err != nil || d == nil {
+		return nil, err
+	}
+	old := *d
+	d.DeviceType, d.TypeConfidence = classifyDevice(s.classifyInput(*d))
+	d.TypeManual = false
+	s.saveDevice(&old, *d)
+	return d, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"testing"
+)
+
+func TestClassifyDevice(t *testing.T) {
+	cases := []struct {
+		name string
+		in   classifyInput
+		want string
+	}{
+		{"printer by vendor and port", classifyInput{Manufacturer: "Brother Industries, Ltd.", Ports: []int{9100}}, model.TypePrinter},
+		{"printer by hostname", classifyInput{Hostname: "NPI3A4F21"}, model.TypePrinter},
+		{"router by vendor", classifyInput{Manufacturer: "Routerboard.com", Ports: []int{53}}, model.TypeRouter},
+		{"camera by rtsp and hostname", classifyInput{Hostname: "ipcam-lobby", Ports: []int{554}}, model.TypeCamera},
+		{"iphone", classifyInput{Manufacturer: "Apple, Inc.", Ports: []int{62078}}, model.TypePhone},
+		{"proxmox host", classifyInput{Hostname: "pve1.lab", Ports: []int{22, 8006}}, model.TypeHypervisor},
+		{"database server", classifyInput{Hostname: "db01", Ports: []int{22, 5432}}, model.TypeServer},
+		{"windows desktop", classifyInput{Hostname: "DESKTOP-4F2K1", Ports: []int{445, 3389}, TTL: 128}, model.TypeWorkstation},
+		{"esp module", classifyInput{Manufacturer: "Espressif Inc."}, model.TypeIoT},
+		{"switch banner and ttl", classifyInput{TTL: 255, Banners: []string{"Cisco IOS Software, C2960 Software"}, Hostname: "core-sw1"}, model.TypeSwitch},
+		{"too little evidence", classifyInput{Ports: []int{22}}, ""},
+		{"randomized mac", classifyInput{Manufacturer: LocallyAdministered}, ""},
+		{"nothing known", classifyInput{}, ""},
+	}
+	for _, c := range cases {
+		got, confidence := classifyDevice(c.in)
+		if got != c.want {
+			t.Errorf("%s: expected %q, got %q (%.2f)", c.name, c.want, got, confidence)
+		}
+		if got != "" && (confidence < minConfidence || confidence > 1) {
+			t.Errorf("%s: confidence %.2f out of range", c.name, confidence)
+		}
+	}
+
+	// More agreeing evidence means more confidence.
+	_, weak := classifyDevice(classifyInput{Ports: []int{9100}})
+	_, strong := classifyDevice(classifyInput{Manufacturer: "Lexmark International", Hostname: "printer-3f", Ports: []int{9100, 631}})
+	if strong <= weak {
+		t.Errorf("expected combined evidence to raise confidence, got %.2f <= %.2f", strong, weak)
+	}
+}
+
+func TestClassifyDevices_ManualOverride(t *testing.T) {
+	svc := groupFixture(t)
+	svc.ports.SavePorts("p1", []model.Port{{Port: 9100, Protocol: "tcp", State: model.PortOpen}})
+	svc.ports.SavePorts("s1", []model.Port{
+		{Port: 22, Protocol: "tcp", State: model.PortOpen},
+		{Port: 3306, Protocol: "tcp", State: model.PortOpen},
+	})
+
+	svc.classifyDevices(nil)
+	p1, _ := svc.FindByID("p1")
+	if p1.DeviceType != model.TypePrinter || p1.TypeConfidence != 0.7 {
+		t.Errorf("expected p1 to be a printer with confidence 0.7, got %q %.2f", p1.DeviceType, p1.TypeConfidence)
+	}
+	page, _ := svc.ListDevices(repository.DeviceQuery{Type: model.TypeServer})
+	if len(page.Devices) != 1 || page.Devices[0].ID != "s1" {
+		t.Errorf("expected s1 to be the only server, got %+v", page.Devices)
+	}
+	found, _ := svc.SearchDevices("type:printer")
+	if len(found) != 1 || found[0].ID != "p1" {
+		t.Errorf("expected type:printer to find p1, got %+v", found)
+	}
+
+	if _, err := svc.SetDeviceType("p1", "toaster"); !errors.Is(err, ErrInvalidDeviceType) {
+		t.Errorf("expected ErrInvalidDeviceType, got %v", err)
+	}
+	if d, err := svc.SetDeviceType("missing", model.TypeCamera); err != nil || d != nil {
+		t.Errorf("expected nil for an unknown device, got %+v, %v", d, err)
+	}
+	d, err := svc.SetDeviceType("p1", "Camera")
+	if err != nil || d.DeviceType != model.TypeCamera || !d.TypeManual || d.TypeConfidence != 1 {
+		t.Fatalf("expected a manual camera, got %+v, %v", d, err)
+	}
+
+	// Rescans leave the override alone.
+	svc.classifyDevices([]string{"p1"})
+	p1, _ = svc.FindByID("p1")
+	if p1.DeviceType != model.TypeCamera {
+		t.Errorf("expected the override to stick, got %q", p1.DeviceType)
+	}
+	d, err = svc.ClearDeviceType("p1")
+	if err != nil || d.DeviceType != model.TypePrinter || d.TypeManual {
+		t.Errorf("expected p1 to be reclassified as a printer, got %+v, %v", d, err)
+	}
+}
+
+func TestClassifyDevices_KeptAcrossScans(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.5", MAC: "b8:27:eb:00:00:05", Hostname: "office-printer"})
+	svc := NewScannerService(repo, &dummyLogger{}).WithConfig(fastPolling()).WithProber(net)
+
+	job, _ := svc.StartScan("10.0.0.5")
+	waitForScan(t, svc, job.ID)
+	d := repo.FindByIP("10.0.0.5")
+	if d == nil || d.DeviceType != model.TypePrinter {
+		t.Fatalf("expected the scanned device to be a printer, got %+v", d)
+	}
+	history, _ := svc.DeviceHistory(d.ID, repository.EventFilter{Types: []string{model.EventTypeChanged}})
+	if len(history) != 1 || history[0].NewValue != model.TypePrinter {
+		t.Errorf("expected a type_changed event, got %+v", history)
+	}
+
+	svc.SetDeviceType(d.ID, model.TypeIoT)
+	job, _ = svc.StartScan("10.0.0.5")
+	waitForScan(t, svc, job.ID)
+	d = repo.FindByIP("10.0.0.5")
+	if d.DeviceType != model.TypeIoT || !d.TypeManual {
+		t.Errorf("expected the manual type to survive a rescan, got %q manual=%v", d.DeviceType, d.TypeManual)
+	}
+}
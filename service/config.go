@@ -0,0 +1,287 @@
+package service
+
+import "time"
+
+// ScannerConfig holds the tunables of ScannerService. It is decoded from the
+// "scanner" section of config.json on top of DefaultScannerConfig.
+type ScannerConfig struct {
+	// Mode selects how probes reach the network: "privileged" uses raw ICMP
+	// and ARP sockets, "unprivileged" uses ICMP datagram sockets and the
+	// kernel neighbour table, "auto" picks based on available permissions.
+	Mode string `koanf:"mode"`
+	// MaxConcurrentScans caps how many scan jobs run at once; further jobs
+	// stay queued until a slot frees up.
+	MaxConcurrentScans int `koanf:"max_concurrent_scans"`
+	// MaxConcurrentProbes is shared by all scans and bounds the number of
+	// in-flight network probes (pings, ARP and DNS lookups).
+	MaxConcurrentProbes int `koanf:"max_concurrent_probes"`
+	// HostParallelism is the number of hosts a single scan enriches with
+	// hostname and MAC lookups in parallel.
+	HostParallelism int `koanf:"host_parallelism"`
+	// MaxHostsPerScan rejects scan requests whose range expands to more
+	// addresses than this.
+	MaxHostsPerScan int `koanf:"max_hosts_per_scan"`
+	// ChunkSize is how many addresses are pinged per round; job progress is
+	// persisted after every chunk. It is capped at MaxConcurrentProbes.
+	ChunkSize int `koanf:"chunk_size"`
+	// MaxTagsPerDevice caps the number of tags a device can carry.
+	MaxTagsPerDevice int `koanf:"max_tags_per_device"`
+
+	PortScan PortScanConfig `koanf:"port_scan"`
+	Liveness LivenessConfig `koanf:"liveness"`
+	IPv6     IPv6Config     `koanf:"ipv6"`
+	Status   StatusConfig   `koanf:"status"`
+	Polling  PollingConfig  `koanf:"polling"`
+	Metrics  MetricsConfig  `koanf:"metrics"`
+	Vendors  VendorsConfig  `koanf:"vendors"`
+}
+
+type PortScanConfig struct {
+	// DefaultPorts is the port spec used when a scan request does not name
+	// one; leave empty to skip port scanning by default.
+	DefaultPorts string        `koanf:"default_ports"`
+	Timeout      time.Duration `koanf:"timeout"`
+	// Concurrency is the number of ports probed at once per host.
+	Concurrency int          `koanf:"concurrency"`
+	Banners     BannerConfig `koanf:"banners"`
+}
+
+// BannerConfig controls service and version detection on open ports.
+type BannerConfig struct {
+	Enabled bool `koanf:"enabled"`
+	// Timeout bounds all connections made to identify one port.
+	Timeout time.Duration `koanf:"timeout"`
+	// MaxBytes caps how much of each response is read.
+	MaxBytes int `koanf:"max_bytes"`
+}
+
+type LivenessConfig struct {
+	// Methods is the ordered probe chain ("icmp", "tcp", "arp"); a host is
+	// online as soon as one method reaches it.
+	Methods []string `koanf:"methods"`
+	// TCPPorts are dialled by the "tcp" method.
+	TCPPorts []int `koanf:"tcp_ports"`
+	// Timeout bounds each TCP connect and ARP request.
+	Timeout time.Duration `koanf:"timeout"`
+}
+
+type IPv6Config struct {
+	// MaxEnumerate is the largest IPv6 range probed address by address.
+	// Bigger ranges (e.g. a /64) are scanned by neighbour discovery instead
+	// and do not count towards MaxHostsPerScan.
+	MaxEnumerate int `koanf:"max_enumerate"`
+	// DiscoveryTimeout is how long to collect all-nodes multicast replies.
+	DiscoveryTimeout time.Duration `koanf:"discovery_timeout"`
+}
+
+// StatusConfig controls how status polling debounces probe results.
+type StatusConfig struct {
+	// FailuresToOffline is the number of consecutive missed polls before an
+	// online device is marked offline; until then it is "degraded".
+	FailuresToOffline int `koanf:"failures_to_offline"`
+	// SuccessesToOnline is the number of consecutive answered polls before an
+	// offline device is marked online again.
+	SuccessesToOnline int `koanf:"successes_to_online"`
+	// A device that changes between online and offline FlapThreshold times
+	// within FlapWindow is flagged as flapping.
+	FlapWindow    time.Duration `koanf:"flap_window"`
+	FlapThreshold int           `koanf:"flap_threshold"`
+}
+
+// PollingConfig schedules status polling per device.
+type PollingConfig struct {
+	// Interval is the default time between two polls of a device. The
+	// device list is also reloaded from the repository at this rate.
+	Interval time.Duration `koanf:"interval"`
+	// TagIntervals overrides Interval for devices carrying a tag; the
+	// shortest matching interval wins.
+	TagIntervals map[string]time.Duration `koanf:"tag_intervals"`
+	// DeviceIntervals overrides Interval for a device ID or IP address and
+	// takes precedence over group and tag intervals.
+	DeviceIntervals map[string]time.Duration `koanf:"device_intervals"`
+	// GroupIntervals overrides Interval for members of a device group, by
+	// ID or name, and takes precedence over tag intervals.
+	GroupIntervals map[string]time.Duration `koanf:"group_intervals"`
+	// Groups limits polling to members of these device groups. Empty polls
+	// every device.
+	Groups []string `koanf:"groups"`
+	// Devices offline for longer than BackoffAfter are polled at twice
+	// their previous interval after every miss, up to MaxInterval.
+	BackoffAfter time.Duration `koanf:"backoff_after"`
+	MaxInterval  time.Duration `koanf:"max_interval"`
+	// Jitter spreads polls by up to this fraction of the interval so
+	// devices discovered together are not probed in lockstep.
+	Jitter float64 `koanf:"jitter"`
+	// LastSeenPrecision limits writes for devices that stay online: their
+	// last-seen time is only persisted once it has moved by this much.
+	LastSeenPrecision time.Duration `koanf:"last_seen_precision"`
+	// Tick is how often the scheduler looks for devices that are due.
+	Tick time.Duration `koanf:"tick"`
+}
+
+// MetricsConfig controls the latency samples taken while polling devices
+// that answer ICMP.
+type MetricsConfig struct {
+	// EchoCount is the number of echo requests sent per poll; RTT and loss
+	// statistics are computed over them.
+	EchoCount int `koanf:"echo_count"`
+	// Retention is how long samples are kept.
+	Retention time.Duration `koanf:"retention"`
+}
+
+// VendorsConfig locates the MAC vendor database.
+type VendorsConfig struct {
+	// Path is the vendor CSV shipped with the project.
+	Path string `koanf:"path"`
+	// ImportDir holds IEEE registry files uploaded through the API; they
+	// take precedence over Path for the same prefix.
+	ImportDir string `koanf:"import_dir"`
+	// WatchInterval is how often the files are checked for changes.
+	WatchInterval time.Duration `koanf:"watch_interval"`
+}
+
+func DefaultScannerConfig() ScannerConfig {
+	return ScannerConfig{
+		Mode:                ModeAuto,
+		MaxConcurrentScans:  4,
+		MaxConcurrentProbes: 256,
+		HostParallelism:     16,
+		MaxHostsPerScan:     65536,
+		ChunkSize:           256,
+		MaxTagsPerDevice:    32,
+		PortScan: PortScanConfig{
+			Timeout:     500 * time.Millisecond,
+			Concurrency: 64,
+			Banners: BannerConfig{
+				Enabled:  true,
+				Timeout:  3 * time.Second,
+				MaxBytes: 4096,
+			},
+		},
+		Liveness: LivenessConfig{
+			Methods:  []string{LivenessICMP, LivenessTCP, LivenessARP},
+			TCPPorts: []int{22, 80, 443, 3389},
+			Timeout:  500 * time.Millisecond,
+		},
+		IPv6: IPv6Config{
+			MaxEnumerate:     256,
+			DiscoveryTimeout: 2 * time.Second,
+		},
+		Status: StatusConfig{
+			FailuresToOffline: 3,
+			SuccessesToOnline: 2,
+			FlapWindow:        time.Hour,
+			FlapThreshold:     5,
+		},
+		Polling: PollingConfig{
+			Interval:          10 * time.Second,
+			BackoffAfter:      10 * time.Minute,
+			MaxInterval:       10 * time.Minute,
+			Jitter:            0.1,
+			LastSeenPrecision: time.Minute,
+			Tick:              time.Second,
+		},
+		Metrics: MetricsConfig{
+			EchoCount: 3,
+			Retention: 7 * 24 * time.Hour,
+		},
+		Vendors: VendorsConfig{
+			Path:          "data/mac-vendors.csv",
+			ImportDir:     "data/vendors",
+			WatchInterval: time.Minute,
+		},
+	}
+}
+
+func (c ScannerConfig) withDefaults() ScannerConfig {
+	def := DefaultScannerConfig()
+	if c.Mode == "" {
+		c.Mode = def.Mode
+	}
+	if c.MaxConcurrentScans <= 0 {
+		c.MaxConcurrentScans = def.MaxConcurrentScans
+	}
+	if c.MaxConcurrentProbes <= 0 {
+		c.MaxConcurrentProbes = def.MaxConcurrentProbes
+	}
+	if c.HostParallelism <= 0 {
+		c.HostParallelism = def.HostParallelism
+	}
+	if c.MaxHostsPerScan <= 0 {
+		c.MaxHostsPerScan = def.MaxHostsPerScan
+	}
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = def.ChunkSize
+	}
+	c.ChunkSize = min(c.ChunkSize, c.MaxConcurrentProbes)
+	if c.MaxTagsPerDevice <= 0 {
+		c.MaxTagsPerDevice = def.MaxTagsPerDevice
+	}
+	if c.PortScan.Timeout <= 0 {
+		c.PortScan.Timeout = def.PortScan.Timeout
+	}
+	if c.PortScan.Concurrency <= 0 {
+		c.PortScan.Concurrency = def.PortScan.Concurrency
+	}
+	if c.PortScan.Banners.Timeout <= 0 {
+		c.PortScan.Banners.Timeout = def.PortScan.Banners.Timeout
+	}
+	if c.PortScan.Banners.MaxBytes <= 0 {
+		c.PortScan.Banners.MaxBytes = def.PortScan.Banners.MaxBytes
+	}
+	if len(c.Liveness.Methods) == 0 {
+		c.Liveness.Methods = def.Liveness.Methods
+	}
+	if c.Liveness.Timeout <= 0 {
+		c.Liveness.Timeout = def.Liveness.Timeout
+	}
+	if c.IPv6.MaxEnumerate <= 0 {
+		c.IPv6.MaxEnumerate = def.IPv6.MaxEnumerate
+	}
+	if c.IPv6.DiscoveryTimeout <= 0 {
+		c.IPv6.DiscoveryTimeout = def.IPv6.DiscoveryTimeout
+	}
+	if c.Status.FailuresToOffline <= 0 {
+		c.Status.FailuresToOffline = def.Status.FailuresToOffline
+	}
+	if c.Status.SuccessesToOnline <= 0 {
+		c.Status.SuccessesToOnline = def.Status.SuccessesToOnline
+	}
+	if c.Status.FlapWindow <= 0 {
+		c.Status.FlapWindow = def.Status.FlapWindow
+	}
+	if c.Status.FlapThreshold <= 0 {
+		c.Status.FlapThreshold = def.Status.FlapThreshold
+	}
+	if c.Polling.Interval <= 0 {
+		c.Polling.Interval = def.Polling.Interval
+	}
+	if c.Polling.BackoffAfter <= 0 {
+		c.Polling.BackoffAfter = def.Polling.BackoffAfter
+	}
+	if c.Polling.MaxInterval < c.Polling.Interval {
+		c.Polling.MaxInterval = max(def.Polling.MaxInterval, c.Polling.Interval)
+	}
+	if c.Polling.Jitter < 0 || c.Polling.Jitter >= 1 {
+		c.Polling.Jitter = def.Polling.Jitter
+	}
+	if c.Polling.LastSeenPrecision <= 0 {
+		c.Polling.LastSeenPrecision = def.Polling.LastSeenPrecision
+	}
+	if c.Polling.Tick <= 0 {
+		c.Polling.Tick = min(def.Polling.Tick, c.Polling.Interval)
+	}
+	if c.Metrics.EchoCount <= 0 {
+		c.Metrics.EchoCount = def.Metrics.EchoCount
+	}
+	if c.Metrics.Retention <= 0 {
+		c.Metrics.Retention = def.Metrics.Retention
+	}
+	if c.Vendors.Path == "" {
+		c.Vendors.Path = def.Vendors.Path
+	}
+	if c.Vendors.WatchInterval <= 0 {
+		c.Vendors.WatchInterval = def.Vendors.WatchInterval
+	}
+	return c
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// echoReply is the answer of one address to an ICMP echo request.
+type echoReply struct {
+	RTT time.Duration
+	// TTL is the TTL or hop limit the reply arrived with, 0 if the socket
+	// did not report it.
+	TTL int
+}
+
+// echoAll sends one echo request per address, IPv4 and IPv6 in parallel, and
+// collects the replies until timeout or until every address has answered.
+// privileged selects raw ICMP sockets over unprivileged datagram ones.
+func echoAll(ips []string, timeout time.Duration, privileged bool) (map[string]echoReply, error) {
+	var v4, v6 []string
+	for _, ip := range ips {
+		if isIPv6(ip) {
+			v6 = append(v6, ip)
+		} else {
+			v4 = append(v4, ip)
+		}
+	}
+
+	results := make(map[string]echoReply, len(ips))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	for _, batch := range [][]string{v4, v6} {
+		if len(batch) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(batch []string) {
+			defer wg.Done()
+			replies, err := echoFamily(batch, timeout, privileged)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			for ip, r := range replies {
+				results[ip] = r
+			}
+		}(batch)
+	}
+	wg.Wait()
+	if len(errs) > 0 && len(results) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return results, nil
+}
+
+// echoFamily pings addresses of a single family. On datagram sockets the
+// kernel rewrites the echo ID, so replies are matched by source address; raw
+// sockets see every ICMP packet on the host and also check the ID.
+func echoFamily(ips []string, timeout time.Duration, privileged bool) (map[string]echoReply, error) {
+	v6 := isIPv6(ips[0])
+	network, laddr := "udp4", "0.0.0.0"
+	var echoType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if v6 {
+		network, laddr = "udp6", "::"
+		echoType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	if privileged {
+		network = "ip4:icmp"
+		if v6 {
+			network = "ip6:ipv6-icmp"
+		}
+	}
+
+	c, err := icmp.ListenPacket(network, laddr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	read := readWithTTL(c)
+
+	id := os.Getpid() & 0xffff
+	sent := make(map[string]time.Time, len(ips))
+	results := make(map[string]echoReply, len(ips))
+	for i, ip := range ips {
+		var dst net.Addr
+		if privileged {
+			dst, err = net.ResolveIPAddr(network, ip)
+		} else {
+			dst, err = net.ResolveUDPAddr(network, net.JoinHostPort(ip, "0"))
+		}
+		if err != nil {
+			continue
+		}
+		msg := icmp.Message{
+			Type: echoType,
+			Body: &icmp.Echo{ID: id, Seq: i & 0xffff, Data: []byte("network-scanner")},
+		}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			return nil, err
+		}
+		sent[ip] = time.Now()
+		if _, err := c.WriteTo(b, dst); err != nil {
+			delete(sent, ip)
+			continue
+		}
+	}
+
+	if err := c.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1500)
+	remaining := len(sent)
+	for remaining > 0 {
+		n, ttl, peer, err := read(buf)
+		if err != nil {
+			break
+		}
+		m, err := icmp.ParseMessage(echoType.Protocol(), buf[:n])
+		if err != nil || m.Type != replyType {
+			continue
+		}
+		if echo, ok := m.Body.(*icmp.Echo); privileged && (!ok || echo.ID != id) {
+			continue
+		}
+		ip := peer.String()
+		if udp, ok := peer.(*net.UDPAddr); ok {
+			ip = (&net.IPAddr{IP: udp.IP, Zone: udp.Zone}).String()
+		}
+		_, answered := results[ip]
+		if start, asked := sent[ip]; asked && !answered {
+			results[ip] = echoReply{RTT: time.Since(start), TTL: ttl}
+			remaining--
+		}
+	}
+	return results, nil
+}
+
+// readWithTTL returns a reader for c that also reports the TTL (IPv4) or hop
+// limit (IPv6) of each packet. Where the socket cannot report it, the TTL is
+// 0.
+func readWithTTL(c *icmp.PacketConn) func(b []byte) (int, int, net.Addr, error) {
+	if p := c.IPv4PacketConn(); p != nil && p.SetControlMessage(ipv4.FlagTTL, true) == nil {
+		return func(b []byte) (int, int, net.Addr, error) {
+			n, cm, peer, err := p.ReadFrom(b)
+			if cm == nil {
+				return n, 0, peer, err
+			}
+			return n, cm.TTL, peer, err
+		}
+	}
+	if p := c.IPv6PacketConn(); p != nil && p.SetControlMessage(ipv6.FlagHopLimit, true) == nil {
+		return func(b []byte) (int, int, net.Addr, error) {
+			n, cm, peer, err := p.ReadFrom(b)
+			if cm == nil {
+				return n, 0, peer, err
+			}
+			return n, cm.HopLimit, peer, err
+		}
+	}
+	return func(b []byte) (int, int, net.Addr, error) {
+		n, peer, err := c.ReadFrom(b)
+		return n, 0, peer, err
+	}
+}
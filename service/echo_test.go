@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestEchoAll_LoopbackTTL(t *testing.T) {
+	cases := []struct {
+		name       string
+		ip         string
+		privileged bool
+	}{
+		{"raw ipv4", "127.0.0.1", true},
+		{"datagram ipv4", "127.0.0.1", false},
+		{"raw ipv6", "::1", true},
+		{"datagram ipv6", "::1", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := echoAll([]string{c.ip}, time.Second, c.privileged)
+			if err != nil {
+				t.Skipf("ICMP socket unavailable: %v", err)
+			}
+			r, ok := got[c.ip]
+			if !ok {
+				t.Fatalf("expected %s to answer, got %v", c.ip, got)
+			}
+			if r.RTT <= 0 || r.RTT > time.Second {
+				t.Errorf("expected a round-trip time within the timeout, got %v", r.RTT)
+			}
+			if runtime.GOOS == "linux" && r.TTL != 64 {
+				t.Errorf("expected a TTL of 64 from the local stack, got %d", r.TTL)
+			}
+		})
+	}
+}
+
+func TestPingBatchedRTT_ObservesTTL(t *testing.T) {
+	cfg := DefaultScannerConfig()
+	cfg.Mode = ModePrivileged
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithConfig(cfg)
+	rtts, err := svc.pingBatchedRTT(context.Background(), []string{"127.0.0.1"})
+	if err != nil {
+		t.Skipf("raw ICMP unavailable: %v", err)
+	}
+	if rtts["127.0.0.1"] <= 0 {
+		t.Fatalf("expected 127.0.0.1 to answer, got %v", rtts)
+	}
+	if st, ok := svc.stack("127.0.0.1"); !ok || st.TTL == 0 {
+		t.Errorf("expected the reply TTL to be kept, got %+v", st)
+	}
+}
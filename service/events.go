@@ -0,0 +1,129 @@
+package service
+
+import (
+	"network-scanner/model"
+	"network-scanner/repository"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (s *ScannerService) WithEventRepository(r repository.EventRepository) *ScannerService {
+	s.events = r
+	return s
+}
+
+// saveDevice persists d and appends an event for every transition from old,
+// which is the stored record before the change or nil for a new device.
+func (s *ScannerService) saveDevice(old *model.Device, d model.Device) {
+	s.repo.Save(d)
+	s.appendEvents(diffDevice(old, d, time.Now()))
+}
+
+// loadDevices returns the given devices, or all of them when ids is nil.
+// Unknown IDs are skipped.
+func (s *ScannerService) loadDevices(ids []string) []model.Device {
+	if ids == nil {
+		return s.repo.GetAll()
+	}
+	var devices []model.Device
+	for _, id := range ids {
+		if d, err := s.repo.FindByID(id); err == nil && d != nil {
+			devices = append(devices, *d)
+		}
+	}
+	return devices
+}
+
+// updateDevices applies update to the given devices, or all of them when ids
+// is nil, and saves the ones it changed in one batch. It returns how many
+// were saved.
+func (s *ScannerService) updateDevices(ids []string, update func(d *model.Device) bool) (int, error) {
+	now := time.Now()
+	var batch []model.Device
+	var events []model.DeviceEvent
+	for _, d := range s.loadDevices(ids) {
+		old := d
+		if !update(&d) {
+			continue
+		}
+		batch = append(batch, d)
+		events = append(events, diffDevice(&old, d, now)...)
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := s.repo.SaveBatch(batch); err != nil {
+		return 0, err
+	}
+	s.appendEvents(events)
+	return len(batch), nil
+}
+
+func (s *ScannerService) appendEvents(events []model.DeviceEvent) {
+	if len(events) == 0 {
+		return
+	}
+	if err := s.events.Append(events...); err != nil {
+		s.logger.Error("Failed to append device events: ", err)
+	}
+}
+
+// diffDevice lists the events between two versions of a device. A device is
+// "discovered" the first time it is seen online; offline placeholders created
+// for silent addresses produce no events. Attribute changes to an empty value
+// are ignored since they usually mean a lookup failed, not that the value
+// went away.
+func diffDevice(old *model.Device, d model.Device, now time.Time) []model.DeviceEvent {
+	event := func(typ, oldValue, newValue string) model.DeviceEvent {
+		return model.DeviceEvent{DeviceID: d.ID, Type: typ, OldValue: oldValue, NewValue: newValue, Timestamp: now}
+	}
+
+	if old == nil || old.FirstSeen.IsZero() {
+		if d.FirstSeen.IsZero() {
+			return nil
+		}
+		return []model.DeviceEvent{event(model.EventDiscovered, "", d.IPAddress)}
+	}
+
+	var out []model.DeviceEvent
+	if old.Status != d.Status {
+		out = append(out, event(model.EventStatusChanged, old.Status, d.Status))
+	}
+	if d.Hostname != "" && old.Hostname != d.Hostname {
+		out = append(out, event(model.EventHostnameChanged, old.Hostname, d.Hostname))
+	}
+	if d.MACAddress != "" && !strings.EqualFold(old.MACAddress, d.MACAddress) {
+		out = append(out, event(model.EventMACChanged, old.MACAddress, d.MACAddress))
+	}
+	if d.Manufacturer != "" && old.Manufacturer != d.Manufacturer {
+		out = append(out, event(model.EventManufacturerChanged, old.Manufacturer, d.Manufacturer))
+	}
+	if old.DeviceType != d.DeviceType {
+		out = append(out, event(model.EventTypeChanged, old.DeviceType, d.DeviceType))
+	}
+	if d.OS != nil && (old.OS == nil || old.OS.Family != d.OS.Family) {
+		var oldFamily string
+		if old.OS != nil {
+			oldFamily = old.OS.Family
+		}
+		out = append(out, event(model.EventOSChanged, oldFamily, d.OS.Family))
+	}
+	if old.Flapping != d.Flapping {
+		out = append(out, event(model.EventFlappingChanged, strconv.FormatBool(old.Flapping), strconv.FormatBool(d.Flapping)))
+	}
+	if !slices.Equal(old.Tags, d.Tags) {
+		out = append(out, event(model.EventTagsChanged, strings.Join(old.Tags, ","), strings.Join(d.Tags, ",")))
+	}
+	return out
+}
+
+func (s *ScannerService) DeviceHistory(id string, f repository.EventFilter) ([]model.DeviceEvent, error) {
+	f.DeviceID = id
+	return s.events.Find(f)
+}
+
+func (s *ScannerService) Events(f repository.EventFilter) ([]model.DeviceEvent, error) {
+	return s.events.Find(f)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"network-scanner/model"
+	"network-scanner/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func eventTypes(events []model.DeviceEvent) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.Type)
+	}
+	return out
+}
+
+func TestDiffDevice(t *testing.T) {
+	now := time.Now()
+	seen := model.Device{ID: "d1", IPAddress: "10.0.0.5", Status: "online", Hostname: "a", MACAddress: "aa:aa:aa:aa:aa:01", FirstSeen: now}
+
+	cases := []struct {
+		name string
+		old  *model.Device
+		new  func(d model.Device) model.Device
+		want []string
+	}{
+		{"offline placeholder", nil, func(d model.Device) model.Device { d.FirstSeen = time.Time{}; d.Status = "offline"; return d }, nil},
+		{"first seen", nil, func(d model.Device) model.Device { return d }, []string{model.EventDiscovered}},
+		{"unchanged", &seen, func(d model.Device) model.Device { return d }, nil},
+		{"goes offline", &seen, func(d model.Device) model.Device { d.Status = "offline"; return d }, []string{model.EventStatusChanged}},
+		{"renamed and retagged", &seen, func(d model.Device) model.Device { d.Hostname = "b"; d.Tags = []string{"x"}; return d }, []string{model.EventHostnameChanged, model.EventTagsChanged}},
+		{"failed lookup", &seen, func(d model.Device) model.Device { d.Hostname = ""; d.MACAddress = ""; return d }, nil},
+		{"new MAC", &seen, func(d model.Device) model.Device { d.MACAddress = "aa:aa:aa:aa:aa:02"; return d }, []string{model.EventMACChanged}},
+	}
+	for _, c := range cases {
+		got := eventTypes(diffDevice(c.old, c.new(seen), now))
+		if len(got) != len(c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestEvents_ScanPollAndTags(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.5", MAC: "aa:aa:aa:aa:aa:05"})
+	svc := NewScannerService(repo, &dummyLogger{}).WithConfig(fastPolling()).WithProber(net)
+
+	job, _ := svc.StartScan("10.0.0.5")
+	waitForScan(t, svc, job.ID)
+	d := repo.FindByIP("10.0.0.5")
+	if d == nil {
+		t.Fatal("expected device to be recorded")
+	}
+	if err := svc.UpdateTags(d.ID, []string{"prod"}); err != nil {
+		t.Fatalf("UpdateTags error: %v", err)
+	}
+
+	net.SetDown("10.0.0.5", true)
+	svc.StartStatusPolling()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cur := repo.FindByIP("10.0.0.5"); cur.Status == "offline" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	svc.StopStatusPolling()
+
+	history, err := svc.DeviceHistory(d.ID, repository.EventFilter{})
+	if err != nil {
+		t.Fatalf("DeviceHistory error: %v", err)
+	}
+	got := eventTypes(history)
+	want := []string{model.EventStatusChanged, model.EventStatusChanged, model.EventTagsChanged, model.EventDiscovered}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected %v newest first, got %v", want, got)
+	}
+	if history[1].OldValue != "online" || history[1].NewValue != "degraded" || history[0].NewValue != "offline" {
+		t.Errorf("expected online -> degraded -> offline, got %+v", history[:2])
+	}
+
+	filtered, _ := svc.Events(repository.EventFilter{Types: []string{model.EventTagsChanged}})
+	if len(filtered) != 1 || filtered[0].NewValue != "prod" {
+		t.Errorf("expected only the tag edit, got %+v", filtered)
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// FakeHost is a simulated machine on a FakeNetwork.
+type FakeHost struct {
+	IP       string
+	MAC      string
+	Hostname string
+	// Latency is how long the host takes to answer a probe. Hosts slower
+	// than the network's timeout never answer.
+	Latency time.Duration
+	// Loss is the probability in [0, 1] that a single probe is dropped.
+	Loss float64
+	// Down hosts never answer but still keep their MAC and hostname.
+	Down bool
+	// Stack, if set, is what OS fingerprinting sees of the host.
+	Stack *StackTraits
+}
+
+// FakeNetwork is an in-memory Prober for tests. Every liveness method sees
+// the same set of hosts; answers are subject to each host's latency and
+// packet loss, with loss drawn from a seeded source so runs are repeatable.
+type FakeNetwork struct {
+	mu      sync.Mutex
+	hosts   map[string]FakeHost
+	rnd     *rand.Rand
+	timeout time.Duration
+}
+
+func NewFakeNetwork(seed int64, hosts ...FakeHost) *FakeNetwork {
+	n := &FakeNetwork{
+		hosts:   make(map[string]FakeHost),
+		rnd:     rand.New(rand.NewSource(seed)),
+		timeout: time.Second,
+	}
+	for _, h := range hosts {
+		n.AddHost(h)
+	}
+	return n
+}
+
+// WithTimeout sets how long a probe waits for slow hosts.
+func (n *FakeNetwork) WithTimeout(d time.Duration) *FakeNetwork {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.timeout = d
+	return n
+}
+
+func (n *FakeNetwork) AddHost(h FakeHost) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.hosts[h.IP] = h
+}
+
+func (n *FakeNetwork) RemoveHost(ip string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.hosts, ip)
+}
+
+func (n *FakeNetwork) SetDown(ip string, down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if h, ok := n.hosts[ip]; ok {
+		h.Down = down
+		n.hosts[ip] = h
+	}
+}
+
+func (n *FakeNetwork) Liveness(method string) LivenessFunc {
+	switch method {
+	case LivenessICMP, LivenessTCP, LivenessARP:
+		return n.probe
+	}
+	return nil
+}
+
+// probe answers for every reachable host after the slowest responder's
+// latency, or returns early if ctx is cancelled.
+func (n *FakeNetwork) probe(ctx context.Context, ips []string) (map[string]bool, error) {
+	n.mu.Lock()
+	alive := make(map[string]bool)
+	var wait time.Duration
+	for _, ip := range ips {
+		h, ok := n.hosts[ip]
+		if !ok || h.Down || h.Latency > n.timeout {
+			continue
+		}
+		if h.Loss > 0 && n.rnd.Float64() < h.Loss {
+			continue
+		}
+		alive[ip] = true
+		wait = max(wait, h.Latency)
+	}
+	n.mu.Unlock()
+
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return alive, nil
+}
+
+// Echo answers count rounds of echo requests. Each reply takes the host's
+// latency and every request is subject to its loss.
+func (n *FakeNetwork) Echo(ctx context.Context, ips []string, count int) (map[string][]time.Duration, error) {
+	out := make(map[string][]time.Duration)
+	for range count {
+		n.mu.Lock()
+		var wait time.Duration
+		for _, ip := range ips {
+			h, ok := n.hosts[ip]
+			if !ok || h.Down || h.Latency > n.timeout {
+				continue
+			}
+			if h.Loss > 0 && n.rnd.Float64() < h.Loss {
+				continue
+			}
+			out[ip] = append(out[ip], h.Latency)
+			wait = max(wait, h.Latency)
+		}
+		n.mu.Unlock()
+
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return nil, ctx.Err()
+			}
+		}
+	}
+	return out, nil
+}
+
+func (n *FakeNetwork) MAC(ctx context.Context, ip string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.hosts[ip].MAC
+}
+
+func (n *FakeNetwork) Hostname(ctx context.Context, ip string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.hosts[ip].Hostname
+}
+
+func (n *FakeNetwork) Stack(ip string) (StackTraits, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	h, ok := n.hosts[ip]
+	if !ok || h.Stack == nil {
+		return StackTraits{}, false
+	}
+	return *h.Stack, true
+}
+
+var (
+	_ Prober      = (*FakeNetwork)(nil)
+	_ StackProber = (*FakeNetwork)(nil)
+)
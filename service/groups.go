@@ -0,0 +1,173 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrGroupNotFound = errors.New("group not found")
+	ErrInvalidGroup  = errors.New("invalid group")
+)
+
+func (s *ScannerService) WithGroupRepository(r repository.GroupRepository) *ScannerService {
+	s.groups = r
+	return s
+}
+
+// SaveGroup creates a group when g has no ID and replaces it otherwise.
+// Dynamic groups must carry a valid query; static groups list existing
+// devices.
+func (s *ScannerService) SaveGroup(g model.DeviceGroup) (model.DeviceGroup, error) {
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Name == "" {
+		return g, fmt.Errorf("%w: name is required", ErrInvalidGroup)
+	}
+	all, err := s.groups.GetAll()
+	if err != nil {
+		return g, err
+	}
+	for _, other := range all {
+		if other.ID != g.ID && strings.EqualFold(other.Name, g.Name) {
+			return g, fmt.Errorf("%w: name %q is already used", ErrInvalidGroup, g.Name)
+		}
+	}
+
+	switch g.Type {
+	case model.GroupDynamic:
+		if strings.TrimSpace(g.Query) == "" {
+			return g, fmt.Errorf("%w: a dynamic group needs a query", ErrInvalidGroup)
+		}
+		if _, err := ParseQuery(g.Query, time.Now()); err != nil {
+			return g, err
+		}
+		g.DeviceIDs = nil
+	case model.GroupStatic:
+		if g.Query != "" {
+			return g, fmt.Errorf("%w: a static group cannot have a query", ErrInvalidGroup)
+		}
+		ids := make([]string, 0, len(g.DeviceIDs))
+		for _, id := range g.DeviceIDs {
+			if slices.Contains(ids, id) {
+				continue
+			}
+			if d, err := s.repo.FindByID(id); err != nil || d == nil {
+				return g, fmt.Errorf("%w: unknown device %q", ErrInvalidGroup, id)
+			}
+			ids = append(ids, id)
+		}
+		g.DeviceIDs = ids
+	default:
+		return g, fmt.Errorf("%w: type must be %q or %q", ErrInvalidGroup, model.GroupDynamic, model.GroupStatic)
+	}
+
+	if g.ID == "" {
+		g.ID = uuid.New().String()
+		g.CreatedAt = time.Now()
+	} else {
+		old, err := s.Group(g.ID)
+		if err != nil {
+			return g, err
+		}
+		g.CreatedAt = old.CreatedAt
+	}
+	return g, s.groups.Save(g)
+}
+
+func (s *ScannerService) Groups() ([]model.DeviceGroup, error) {
+	return s.groups.GetAll()
+}
+
+func (s *ScannerService) Group(id string) (*model.DeviceGroup, error) {
+	g, err := s.groups.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, ErrGroupNotFound
+	}
+	return g, nil
+}
+
+func (s *ScannerService) DeleteGroup(id string) error {
+	if _, err := s.Group(id); err != nil {
+		return err
+	}
+	return s.groups.Delete(id)
+}
+
+func (s *ScannerService) GroupDevices(id string) ([]model.Device, error) {
+	g, err := s.Group(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupMembers(*g)
+}
+
+// findGroup looks a group up by ID or, failing that, by name, so scans and
+// the polling config can refer to groups by name.
+func (s *ScannerService) findGroup(ref string) (*model.DeviceGroup, error) {
+	if g, err := s.groups.FindByID(ref); err != nil || g != nil {
+		return g, err
+	}
+	all, err := s.groups.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range all {
+		if strings.EqualFold(g.Name, ref) {
+			return &g, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, ref)
+}
+
+// groupMembers resolves a group against the current device list. Static
+// members that no longer exist are skipped.
+func (s *ScannerService) groupMembers(g model.DeviceGroup) ([]model.Device, error) {
+	if g.Type == model.GroupDynamic {
+		expr, err := ParseQuery(g.Query, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		page, err := s.repo.List(repository.DeviceQuery{Match: expr})
+		return page.Devices, err
+	}
+	out := []model.Device{}
+	for _, id := range g.DeviceIDs {
+		if d, err := s.repo.FindByID(id); err == nil && d != nil {
+			out = append(out, *d)
+		}
+	}
+	return out, nil
+}
+
+// groupTargets turns the members of a group into a target spec of their
+// primary addresses.
+func (s *ScannerService) groupTargets(ref string) (string, error) {
+	g, err := s.findGroup(ref)
+	if err != nil {
+		return "", err
+	}
+	devices, err := s.groupMembers(*g)
+	if err != nil {
+		return "", err
+	}
+	var ips []string
+	for _, d := range devices {
+		if d.IPAddress != "" && !slices.Contains(ips, d.IPAddress) {
+			ips = append(ips, d.IPAddress)
+		}
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("%w: group %q has no devices with an address", ErrInvalidRange, g.Name)
+	}
+	return strings.Join(ips, ","), nil
+}
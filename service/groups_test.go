@@ -0,0 +1,139 @@
+package service
+
+import (
+	"errors"
+	"network-scanner/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func groupFixture(t *testing.T) *ScannerService {
+	t.Helper()
+	repo := newFakeDeviceRepo()
+	repo.Save(model.Device{ID: "p1", IPAddress: "10.0.0.1", Status: "offline", Tags: []string{"printer"}})
+	repo.Save(model.Device{ID: "p2", IPAddress: "10.0.0.2", Status: "online", Tags: []string{"printer"}})
+	repo.Save(model.Device{ID: "s1", IPAddress: "10.0.0.3", Status: "online", Tags: []string{"server"}})
+	return NewScannerService(repo, &dummyLogger{}).WithConfig(fastPolling())
+}
+
+func memberIDs(devices []model.Device) []string {
+	var out []string
+	for _, d := range devices {
+		out = append(out, d.ID)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestGroups_DynamicAndStatic(t *testing.T) {
+	svc := groupFixture(t)
+
+	dyn, err := svc.SaveGroup(model.DeviceGroup{Name: "offline printers", Type: model.GroupDynamic, Query: "tag:printer AND status:offline"})
+	if err != nil {
+		t.Fatalf("SaveGroup error: %v", err)
+	}
+	got, _ := svc.GroupDevices(dyn.ID)
+	if !slices.Equal(memberIDs(got), []string{"p1"}) {
+		t.Errorf("expected p1 in dynamic group, got %v", memberIDs(got))
+	}
+	// Dynamic groups follow the current state.
+	d, _ := svc.FindByID("p2")
+	d.Status = "offline"
+	svc.repo.Save(*d)
+	got, _ = svc.GroupDevices(dyn.ID)
+	if !slices.Equal(memberIDs(got), []string{"p1", "p2"}) {
+		t.Errorf("expected p1 and p2 after p2 went offline, got %v", memberIDs(got))
+	}
+
+	static, err := svc.SaveGroup(model.DeviceGroup{Name: "core", Type: model.GroupStatic, DeviceIDs: []string{"s1", "p2", "s1"}})
+	if err != nil {
+		t.Fatalf("SaveGroup error: %v", err)
+	}
+	if !slices.Equal(static.DeviceIDs, []string{"s1", "p2"}) {
+		t.Errorf("expected duplicate members to be dropped, got %v", static.DeviceIDs)
+	}
+	svc.repo.Delete("p2")
+	got, _ = svc.GroupDevices(static.ID)
+	if !slices.Equal(memberIDs(got), []string{"s1"}) {
+		t.Errorf("expected deleted members to be skipped, got %v", memberIDs(got))
+	}
+
+	updated, err := svc.SaveGroup(model.DeviceGroup{ID: static.ID, Name: "core", Type: model.GroupStatic, DeviceIDs: []string{"p1"}})
+	if err != nil || !updated.CreatedAt.Equal(static.CreatedAt) {
+		t.Errorf("expected update to keep created_at, got %+v, %v", updated, err)
+	}
+	if err := svc.DeleteGroup(static.ID); err != nil {
+		t.Fatalf("DeleteGroup error: %v", err)
+	}
+	if _, err := svc.Group(static.ID); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("expected ErrGroupNotFound, got %v", err)
+	}
+}
+
+func TestGroups_Validation(t *testing.T) {
+	svc := groupFixture(t)
+	svc.SaveGroup(model.DeviceGroup{Name: "servers", Type: model.GroupDynamic, Query: "tag:server"})
+
+	var syntaxErr *QuerySyntaxError
+	cases := []struct {
+		name string
+		g    model.DeviceGroup
+		ok   func(error) bool
+	}{
+		{"no name", model.DeviceGroup{Type: model.GroupStatic}, func(err error) bool { return errors.Is(err, ErrInvalidGroup) }},
+		{"duplicate name", model.DeviceGroup{Name: "Servers", Type: model.GroupStatic}, func(err error) bool { return errors.Is(err, ErrInvalidGroup) }},
+		{"bad type", model.DeviceGroup{Name: "x", Type: "smart"}, func(err error) bool { return errors.Is(err, ErrInvalidGroup) }},
+		{"bad query", model.DeviceGroup{Name: "x", Type: model.GroupDynamic, Query: "tag:"}, func(err error) bool { return errors.As(err, &syntaxErr) }},
+		{"unknown member", model.DeviceGroup{Name: "x", Type: model.GroupStatic, DeviceIDs: []string{"nope"}}, func(err error) bool { return errors.Is(err, ErrInvalidGroup) }},
+		{"missing group", model.DeviceGroup{ID: "nope", Name: "x", Type: model.GroupStatic}, func(err error) bool { return errors.Is(err, ErrGroupNotFound) }},
+	}
+	for _, c := range cases {
+		if _, err := svc.SaveGroup(c.g); !c.ok(err) {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+}
+
+func TestStartScan_Group(t *testing.T) {
+	svc := groupFixture(t).WithProber(NewFakeNetwork(1, FakeHost{IP: "10.0.0.1"}, FakeHost{IP: "10.0.0.2"}))
+	svc.SaveGroup(model.DeviceGroup{Name: "printers", Type: model.GroupDynamic, Query: "tag:printer"})
+
+	job, err := svc.StartScanWithOptions(ScanOptions{Group: "printers", Ports: "none"})
+	if err != nil {
+		t.Fatalf("StartScan error: %v", err)
+	}
+	if job.HostsTotal != 2 || job.Group != "printers" {
+		t.Errorf("expected the two printers to be targeted, got %+v", job)
+	}
+	svc.wg.Wait()
+
+	if _, err := svc.StartScanWithOptions(ScanOptions{Group: "nope"}); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("expected ErrGroupNotFound, got %v", err)
+	}
+	svc.SaveGroup(model.DeviceGroup{Name: "empty", Type: model.GroupStatic})
+	if _, err := svc.StartScanWithOptions(ScanOptions{Group: "empty"}); !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("expected ErrInvalidRange for an empty group, got %v", err)
+	}
+}
+
+func TestPoller_Groups(t *testing.T) {
+	svc := groupFixture(t)
+	printers, _ := svc.SaveGroup(model.DeviceGroup{Name: "printers", Type: model.GroupDynamic, Query: "tag:printer"})
+	svc.SaveGroup(model.DeviceGroup{Name: "core", Type: model.GroupStatic, DeviceIDs: []string{"p2"}})
+	svc.cfg.Polling.Interval = time.Minute
+	svc.cfg.Polling.Groups = []string{printers.ID}
+	svc.cfg.Polling.GroupIntervals = map[string]time.Duration{"core": 5 * time.Second, "printers": 20 * time.Second}
+
+	p := &poller{s: svc, entries: make(map[string]*pollEntry)}
+	p.reload(time.Now())
+	if _, ok := p.entries["s1"]; ok || len(p.entries) != 2 {
+		t.Fatalf("expected only the printers to be polled, got %d entries", len(p.entries))
+	}
+	for id, want := range map[string]time.Duration{"p1": 20 * time.Second, "p2": 5 * time.Second} {
+		e := p.entries[id]
+		if got := p.interval(e, e.dev, time.Now()); got != want {
+			t.Errorf("%s: expected interval %v, got %v", id, want, got)
+		}
+	}
+}
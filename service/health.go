@@ -0,0 +1,102 @@
+package service
+
+import (
+	"network-scanner/model"
+	"slices"
+	"time"
+)
+
+// healthState is the in-memory hysteresis state of one polled device.
+type healthState struct {
+	// settled is the last status that met its threshold, "online" or
+	// "offline"; while a device is "degraded" it says which way it came from.
+	settled   string
+	failures  int
+	successes int
+	// flaps holds the times of recent online/offline transitions.
+	flaps []time.Time
+}
+
+// observe applies one status poll or scan result to d. A device only goes
+// offline after FailuresToOffline consecutive misses and only comes back after
+// SuccessesToOnline consecutive answers; in between it is "degraded". Each
+// settled transition counts as a flap, and a device with FlapThreshold flaps
+// inside FlapWindow is flagged as flapping.
+func (s *ScannerService) observe(d *model.Device, method string, now time.Time) {
+	cfg := s.cfg.Status
+
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	st, ok := s.health[d.ID]
+	if !ok {
+		// Carry the stored flap count over a restart; its flaps age out of
+		// the window from now.
+		st = &healthState{flaps: slices.Repeat([]time.Time{now}, d.FlapCount)}
+		s.health[d.ID] = st
+	}
+	// A device seen for the first time takes the status of its first probe.
+	if d.Status == "" {
+		d.Status = "offline"
+		if method != "" {
+			d.Status = "online"
+		}
+	}
+	// Resync when the stored status was settled by someone else, e.g. a scan.
+	if stable := settledStatus(d.Status); stable != st.settled && (d.Status != "degraded" || st.settled == "") {
+		st.settled, st.failures, st.successes = stable, 0, 0
+	}
+
+	if method != "" {
+		st.failures = 0
+		st.successes++
+		if d.FirstSeen.IsZero() {
+			d.FirstSeen = now
+		}
+		d.LastSeen = now
+		d.LivenessMethod = method
+		if st.settled == "offline" && st.successes < cfg.SuccessesToOnline {
+			d.Status = "degraded"
+		} else {
+			st.flip("online", now)
+			d.Status = "online"
+		}
+	} else {
+		st.successes = 0
+		st.failures++
+		if st.settled == "online" && st.failures < cfg.FailuresToOffline {
+			d.Status = "degraded"
+		} else {
+			st.flip("offline", now)
+			d.Status = "offline"
+		}
+	}
+
+	cutoff := now.Add(-cfg.FlapWindow)
+	for len(st.flaps) > 0 && st.flaps[0].Before(cutoff) {
+		st.flaps = st.flaps[1:]
+	}
+	d.FlapCount = len(st.flaps)
+	d.Flapping = d.FlapCount >= cfg.FlapThreshold
+}
+
+func (st *healthState) flip(status string, now time.Time) {
+	if st.settled != "" && st.settled != status {
+		st.flaps = append(st.flaps, now)
+	}
+	st.settled = status
+}
+
+// settledStatus maps a stored status onto the hysteresis baseline. A device
+// stored as degraded was online before it started missing polls.
+func settledStatus(status string) string {
+	if status == "offline" {
+		return "offline"
+	}
+	return "online"
+}
+
+func (s *ScannerService) resetHealth() {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.health = make(map[string]*healthState)
+}
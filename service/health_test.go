@@ -0,0 +1,115 @@
+package service
+
+import (
+	"network-scanner/model"
+	"network-scanner/repository"
+	"testing"
+	"time"
+)
+
+func TestObserve_Hysteresis(t *testing.T) {
+	cfg := DefaultScannerConfig()
+	cfg.Status = StatusConfig{FailuresToOffline: 3, SuccessesToOnline: 2, FlapWindow: time.Hour, FlapThreshold: 5}
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithConfig(cfg)
+
+	d := model.Device{ID: "d1", Status: "online"}
+	now := time.Now()
+	steps := []struct {
+		method string
+		want   string
+	}{
+		{"", "degraded"},
+		{LivenessICMP, "online"},
+		{"", "degraded"},
+		{"", "degraded"},
+		{"", "offline"},
+		{"", "offline"},
+		{LivenessICMP, "degraded"},
+		{"", "offline"},
+		{LivenessICMP, "degraded"},
+		{LivenessICMP, "online"},
+	}
+	for i, step := range steps {
+		svc.observe(&d, step.method, now)
+		if d.Status != step.want {
+			t.Fatalf("step %d: expected %s, got %s", i, step.want, d.Status)
+		}
+	}
+	if d.FlapCount != 2 || d.Flapping {
+		t.Errorf("expected 2 flaps and no flag, got %d / %v", d.FlapCount, d.Flapping)
+	}
+}
+
+func TestObserve_FlagsFlappingDevices(t *testing.T) {
+	cfg := DefaultScannerConfig()
+	cfg.Status = StatusConfig{FailuresToOffline: 1, SuccessesToOnline: 1, FlapWindow: time.Minute, FlapThreshold: 3}
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithConfig(cfg)
+
+	d := model.Device{ID: "d1", Status: "online"}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		method := ""
+		if i%2 == 1 {
+			method = LivenessICMP
+		}
+		svc.observe(&d, method, now)
+	}
+	if !d.Flapping || d.FlapCount != 3 {
+		t.Fatalf("expected device to be flagged after 3 flaps, got %d / %v", d.FlapCount, d.Flapping)
+	}
+
+	svc.observe(&d, "", now.Add(2*time.Minute))
+	if d.Flapping || d.FlapCount != 0 {
+		t.Errorf("expected flaps to age out of the window, got %d / %v", d.FlapCount, d.Flapping)
+	}
+}
+
+func TestScan_KeepsFlapState(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.7", MACAddress: "aa:00:00:00:00:07", Status: "online", FlapCount: 5, Flapping: true})
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.7", MAC: "aa:00:00:00:00:07"})
+	svc := NewScannerService(repo, &dummyLogger{}).WithConfig(fastPolling()).WithProber(net)
+
+	job, _ := svc.StartScan("10.0.0.7")
+	waitForScan(t, svc, job.ID)
+	d, _ := repo.FindByID("d1")
+	if d == nil || !d.Flapping || d.FlapCount != 5 {
+		t.Fatalf("expected the scan to keep the flap state, got %+v", d)
+	}
+	history, _ := svc.DeviceHistory("d1", repository.EventFilter{Types: []string{model.EventFlappingChanged}})
+	if len(history) != 0 {
+		t.Errorf("expected no flapping_changed events, got %+v", history)
+	}
+}
+
+func TestScan_GoesThroughHysteresis(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.7", MACAddress: "aa:00:00:00:00:07", Status: "online"})
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.7", MAC: "aa:00:00:00:00:07", Down: true})
+	svc := NewScannerService(repo, &dummyLogger{}).WithProber(net)
+	scan := func() string {
+		job, _ := svc.StartScan("10.0.0.7")
+		waitForScan(t, svc, job.ID)
+		d, _ := repo.FindByID("d1")
+		return d.Status
+	}
+
+	for i := 1; i < svc.cfg.Status.FailuresToOffline; i++ {
+		if got := scan(); got != "degraded" {
+			t.Fatalf("expected missed scan %d to only degrade the device, got %q", i, got)
+		}
+	}
+	if got := scan(); got != "offline" {
+		t.Fatalf("expected the device offline after %d missed scans, got %q", svc.cfg.Status.FailuresToOffline, got)
+	}
+
+	net.AddHost(FakeHost{IP: "10.0.0.7", MAC: "aa:00:00:00:00:07"})
+	for i := 1; i < svc.cfg.Status.SuccessesToOnline; i++ {
+		if got := scan(); got != "degraded" {
+			t.Fatalf("expected answered scan %d to only degrade the device, got %q", i, got)
+		}
+	}
+	if got := scan(); got != "online" {
+		t.Errorf("expected the device online after %d answered scans, got %q", svc.cfg.Status.SuccessesToOnline, got)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"network-scanner/model"
+	"network-scanner/repository"
+	"slices"
+	"strings"
+	"time"
+)
+
+func (s *ScannerService) WithAddressRepository(r repository.AddressRepository) *ScannerService {
+	s.addresses = r
+	return s
+}
+
+// resolveIdentity finds the device record a host answering on ip with the
+// given MAC belongs to. A MAC match wins over an IP match so a device keeps
+// its identity, tags and first-seen date when DHCP moves it to a new address.
+// Hosts without a known MAC fall back to the IP match.
+//
+// If ip is still held by a different record, that record is either merged
+// into the device (it has no MAC, so it was most likely this host before its
+// MAC was known) or loses the address (it belongs to another MAC).
+func (s *ScannerService) resolveIdentity(ip, mac string) *model.Device {
+	byIP := s.repo.FindByIP(ip)
+	if byIP != nil && !holdsAddress(*byIP, ip) {
+		byIP = nil
+	}
+	if mac == "" {
+		return byIP
+	}
+	byMAC := s.repo.FindByMAC(mac)
+
+	switch {
+	case byMAC == nil && byIP == nil:
+		return nil
+	case byMAC == nil:
+		if byIP.MACAddress == "" || strings.EqualFold(byIP.MACAddress, mac) {
+			return byIP
+		}
+		s.releaseAddress(*byIP, ip)
+		return nil
+	case byIP == nil || byIP.ID == byMAC.ID:
+		return byMAC
+	case byIP.MACAddress == "":
+		merged := s.mergeDevices(*byMAC, *byIP)
+		return &merged
+	default:
+		s.releaseAddress(*byIP, ip)
+		return byMAC
+	}
+}
+
+// holdsAddress reports whether ip is the primary or one of the IPv6
+// addresses of d.
+func holdsAddress(d model.Device, ip string) bool {
+	return d.IPAddress == ip || slices.ContainsFunc(d.IPv6Addresses, func(a string) bool {
+		return strings.EqualFold(a, ip)
+	})
+}
+
+// releaseAddress takes ip away from a device that no longer holds it. The
+// device keeps its record and history; if ip was its primary address it is
+// marked offline until it is seen again.
+func (s *ScannerService) releaseAddress(d model.Device, ip string) {
+	old := d
+	d.IPv6Addresses = slices.DeleteFunc(slices.Clone(d.IPv6Addresses), func(a string) bool {
+		return strings.EqualFold(a, ip)
+	})
+	if d.IPAddress == ip {
+		d.IPAddress = ""
+		if len(d.IPv6Addresses) > 0 {
+			d.IPAddress = d.IPv6Addresses[0]
+		}
+		d.Status = "offline"
+	}
+	s.saveDevice(&old, d)
+	s.logger.Info("Address ", ip, " moved away from device ", d.ID)
+}
+
+// mergeDevices folds drop into keep and deletes drop. Tags are combined, the
+// earlier first-seen date wins and a manual type on either side is kept.
+func (s *ScannerService) mergeDevices(keep, drop model.Device) model.Device {
+	if !drop.FirstSeen.IsZero() && (keep.FirstSeen.IsZero() || drop.FirstSeen.Before(keep.FirstSeen)) {
+		keep.FirstSeen = drop.FirstSeen
+	}
+	keep.Tags = normalizeTags(append(append([]string(nil), keep.Tags...), drop.Tags...), s.cfg.MaxTagsPerDevice)
+	if keep.Hostname == "" {
+		keep.Hostname = drop.Hostname
+	}
+	if keep.Manufacturer == "" {
+		keep.Manufacturer = drop.Manufacturer
+	}
+	for _, ip := range drop.IPv6Addresses {
+		keep.IPv6Addresses = mergeIPv6(keep.IPv6Addresses, ip)
+	}
+	if keep.OS == nil {
+		keep.OS = drop.OS
+	}
+	if (drop.TypeManual && !keep.TypeManual) || keep.DeviceType == "" {
+		keep.DeviceType, keep.TypeConfidence, keep.TypeManual = drop.DeviceType, drop.TypeConfidence, drop.TypeManual
+	}
+
+	if err := s.repo.Delete(drop.ID); err != nil {
+		s.logger.Error("Failed to delete merged device ", drop.ID, ": ", err)
+	}
+	if err := s.addresses.Reassign(drop.ID, keep.ID); err != nil {
+		s.logger.Error("Failed to move address history of ", drop.ID, ": ", err)
+	}
+	if err := s.events.Reassign(drop.ID, keep.ID); err != nil {
+		s.logger.Error("Failed to move event history of ", drop.ID, ": ", err)
+	}
+	if err := s.groups.Reassign(drop.ID, keep.ID); err != nil {
+		s.logger.Error("Failed to move group memberships of ", drop.ID, ": ", err)
+	}
+	if err := s.metrics.Reassign(drop.ID, keep.ID); err != nil {
+		s.logger.Error("Failed to move metrics of ", drop.ID, ": ", err)
+	}
+	if err := s.ports.Reassign(drop.ID, keep.ID); err != nil {
+		s.logger.Error("Failed to move ports of ", drop.ID, ": ", err)
+	}
+	s.logger.Info("Merged device ", drop.ID, " into ", keep.ID)
+	return keep
+}
+
+func (s *ScannerService) recordAddress(deviceID, ip string, seen time.Time) {
+	if err := s.addresses.RecordAddress(deviceID, ip, seen); err != nil {
+		s.logger.Error("Failed to record address ", ip, " for ", deviceID, ": ", err)
+	}
+}
+
+func (s *ScannerService) DeviceAddresses(id string) ([]model.DeviceAddress, error) {
+	return s.addresses.FindByDevice(id)
+}
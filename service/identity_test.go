@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"network-scanner/model"
+	"testing"
+	"time"
+)
+
+func TestRecordHost_KeepsIdentityAcrossDHCPChange(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	first := time.Now().Add(-24 * time.Hour)
+	repo.Save(model.Device{ID: "laptop", IPAddress: "10.0.0.5", MACAddress: "aa:bb:cc:dd:ee:05", Tags: []string{"alice"}, FirstSeen: first})
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.9", MAC: "AA:BB:CC:DD:EE:05"})
+	svc := NewScannerService(repo, &dummyLogger{}).WithProber(net)
+
+	d := svc.recordHost(context.Background(), "10.0.0.9", LivenessICMP)
+	if d.ID != "laptop" || d.IPAddress != "10.0.0.9" {
+		t.Fatalf("expected laptop to move to 10.0.0.9, got %+v", d)
+	}
+	if len(d.Tags) != 1 || d.Tags[0] != "alice" || !d.FirstSeen.Equal(first) {
+		t.Errorf("expected tags and first seen to be kept, got %v / %v", d.Tags, d.FirstSeen)
+	}
+	if len(repo.GetAll()) != 1 {
+		t.Errorf("expected no new device, got %d", len(repo.GetAll()))
+	}
+	history, _ := svc.DeviceAddresses("laptop")
+	if len(history) != 1 || history[0].IPAddress != "10.0.0.9" {
+		t.Errorf("expected address history to record 10.0.0.9, got %+v", history)
+	}
+}
+
+func TestRecordHost_AddressHandedToAnotherDevice(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	repo.Save(model.Device{ID: "old", IPAddress: "10.0.0.5", MACAddress: "aa:aa:aa:aa:aa:01", Status: "online"})
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.5", MAC: "aa:aa:aa:aa:aa:02"})
+	svc := NewScannerService(repo, &dummyLogger{}).WithProber(net)
+
+	d := svc.recordHost(context.Background(), "10.0.0.5", LivenessICMP)
+	if d.ID == "old" {
+		t.Fatalf("expected a new device for a different MAC, got %+v", d)
+	}
+	old, _ := repo.FindByID("old")
+	if old == nil || old.IPAddress != "" || old.Status != "offline" {
+		t.Errorf("expected old device to lose the address and go offline, got %+v", old)
+	}
+	if byIP := repo.FindByIP("10.0.0.5"); byIP == nil || byIP.ID != d.ID {
+		t.Errorf("expected 10.0.0.5 to resolve to the new device, got %+v", byIP)
+	}
+}
+
+func TestRecordHost_MergesRecordWithoutMAC(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	early := time.Now().Add(-48 * time.Hour)
+	repo.Save(model.Device{ID: "known", IPAddress: "10.0.0.5", MACAddress: "aa:aa:aa:aa:aa:01", Tags: []string{"prod"}, FirstSeen: time.Now().Add(-time.Hour)})
+	repo.Save(model.Device{ID: "anon", IPAddress: "10.0.0.9", Tags: []string{"printer"}, FirstSeen: early})
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.9", MAC: "aa:aa:aa:aa:aa:01"})
+	svc := NewScannerService(repo, &dummyLogger{}).WithProber(net)
+	svc.ports.SavePorts("known", []model.Port{{Port: 22, Protocol: "tcp", State: model.PortOpen, FirstSeen: time.Now()}})
+	svc.ports.SavePorts("anon", []model.Port{
+		{Port: 22, Protocol: "tcp", State: model.PortClosed, FirstSeen: early},
+		{Port: 631, Protocol: "tcp", State: model.PortOpen, Service: "ipp", FirstSeen: early},
+	})
+
+	d := svc.recordHost(context.Background(), "10.0.0.9", LivenessICMP)
+	if d.ID != "known" {
+		t.Fatalf("expected the MAC record to win, got %+v", d)
+	}
+	if anon, _ := repo.FindByID("anon"); anon != nil {
+		t.Errorf("expected MAC-less record to be merged away, got %+v", anon)
+	}
+	if len(d.Tags) != 2 || !d.FirstSeen.Equal(early) {
+		t.Errorf("expected merged tags and earliest first seen, got %v / %v", d.Tags, d.FirstSeen)
+	}
+
+	ports, _ := svc.ports.FindByDevice("known")
+	if len(ports) != 2 || ports[0].State != model.PortOpen || !ports[0].FirstSeen.Equal(early) || ports[1].Service != "ipp" {
+		t.Errorf("expected the merged record's ports to move over, got %+v", ports)
+	}
+	if left, _ := svc.ports.FindByDevice("anon"); len(left) != 0 {
+		t.Errorf("expected no ports left on the merged record, got %+v", left)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"math"
+	"math/big"
+	"net/netip"
+)
+
+// ipIterator walks an inclusive address range one address at a time so that
+// large ranges never have to be materialized in memory.
+type ipIterator struct {
+	next netip.Addr
+	last netip.Addr
+	done bool
+}
+
+func newIPIterator(first, last netip.Addr) *ipIterator {
+	return &ipIterator{next: first, last: last, done: last.Less(first)}
+}
+
+func (it *ipIterator) Next() (netip.Addr, bool) {
+	if it.done {
+		return netip.Addr{}, false
+	}
+	cur := it.next
+	if cur == it.last {
+		it.done = true
+	} else {
+		it.next = cur.Next()
+	}
+	return cur, true
+}
+
+// cidrBounds returns the first and last scannable address of cidr. Network
+// and broadcast addresses are skipped for IPv4 prefixes shorter than /31.
+func cidrBounds(cidr string) (netip.Addr, netip.Addr, error) {
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, err
+	}
+	p = p.Masked()
+	first, last := p.Addr(), lastAddr(p)
+	if first.Is4() && p.Bits() < 31 {
+		first, last = first.Next(), last.Prev()
+	}
+	return first, last, nil
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// rangeSize counts the addresses between first and last inclusive,
+// saturating at math.MaxUint64 for very large IPv6 ranges.
+func rangeSize(first, last netip.Addr) uint64 {
+	if last.Less(first) {
+		return 0
+	}
+	f := new(big.Int).SetBytes(first.AsSlice())
+	n := new(big.Int).SetBytes(last.AsSlice())
+	n.Sub(n, f).Add(n, big.NewInt(1))
+	if !n.IsUint64() {
+		return math.MaxUint64
+	}
+	return n.Uint64()
+}
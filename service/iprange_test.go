@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"net/netip"
+	"testing"
+)
+
+func TestCIDRBounds(t *testing.T) {
+	cases := []struct {
+		cidr        string
+		first, last string
+		size        uint64
+	}{
+		{"192.168.1.0/24", "192.168.1.1", "192.168.1.254", 254},
+		{"192.168.1.77/24", "192.168.1.1", "192.168.1.254", 254},
+		{"10.0.0.0/31", "10.0.0.0", "10.0.0.1", 2},
+		{"10.0.0.9/32", "10.0.0.9", "10.0.0.9", 1},
+		{"10.0.0.0/8", "10.0.0.1", "10.255.255.254", 1<<24 - 2},
+	}
+	for _, c := range cases {
+		first, last, err := cidrBounds(c.cidr)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", c.cidr, err)
+		}
+		if first.String() != c.first || last.String() != c.last {
+			t.Errorf("%s: expected %s-%s, got %s-%s", c.cidr, c.first, c.last, first, last)
+		}
+		if n := rangeSize(first, last); n != c.size {
+			t.Errorf("%s: expected size %d, got %d", c.cidr, c.size, n)
+		}
+	}
+
+	if _, _, err := cidrBounds("10.0.0.0/33"); err == nil {
+		t.Errorf("expected error for invalid prefix")
+	}
+}
+
+func TestRangeSize_SaturatesForIPv6(t *testing.T) {
+	first, last, err := cidrBounds("2001:db8::/32")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n := rangeSize(first, last); n != math.MaxUint64 {
+		t.Errorf("expected saturated size, got %d", n)
+	}
+}
+
+func TestTargetIterator_Chunks(t *testing.T) {
+	spec, _ := ParseTargets("10.0.0.0/29", nil)
+	it := spec.iterator()
+
+	var got []string
+	for {
+		chunk := it.NextChunk(4)
+		if len(chunk) == 0 {
+			break
+		}
+		if len(chunk) > 4 {
+			t.Fatalf("chunk larger than requested: %v", chunk)
+		}
+		got = append(got, chunk...)
+	}
+	if len(got) != 6 || got[0] != "10.0.0.1" || got[5] != "10.0.0.6" {
+		t.Errorf("unexpected iteration result %v", got)
+	}
+}
+
+func TestIPIterator_EndOfAddressSpace(t *testing.T) {
+	it := newIPIterator(netip.MustParseAddr("255.255.255.254"), netip.MustParseAddr("255.255.255.255"))
+	n := 0
+	for _, ok := it.Next(); ok; _, ok = it.Next() {
+		n++
+		if n > 2 {
+			t.Fatalf("iterator wrapped around the address space")
+		}
+	}
+	if n != 2 {
+		t.Errorf("expected 2 addresses, got %d", n)
+	}
+}
+
+func TestStartScan_TooLarge(t *testing.T) {
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).
+		WithConfig(ScannerConfig{MaxHostsPerScan: 1024}).
+		WithProber(NewFakeNetwork(1))
+
+	if _, err := svc.StartScan("10.0.0.0/8"); !errors.Is(err, ErrScanTooLarge) {
+		t.Fatalf("expected ErrScanTooLarge, got %v", err)
+	}
+	job, err := svc.StartScan("10.0.0.0/22")
+	if err != nil {
+		t.Fatalf("expected /22 to fit the limit, got %v", err)
+	}
+	if job.HostsTotal != 1022 {
+		t.Errorf("expected 1022 hosts, got %d", job.HostsTotal)
+	}
+	waitForScan(t, svc, job.ID)
+}
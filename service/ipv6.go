@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"network-scanner/model"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// splitDiscovery separates IPv6 ranges too large to enumerate. Those are
+// scanned by discovering neighbours instead of probing every address.
+func (t *TargetSpec) splitDiscovery(maxEnumerate uint64) (*TargetSpec, []addrRange) {
+	enumerate := &TargetSpec{}
+	var discover []addrRange
+	for _, r := range t.ranges {
+		if r.first.Is6() && rangeSize(r.first, r.last) > maxEnumerate {
+			discover = append(discover, r)
+		} else {
+			enumerate.ranges = append(enumerate.ranges, r)
+		}
+	}
+	return enumerate, discover
+}
+
+func (it *targetIterator) add(ips []string) {
+	for _, ip := range ips {
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			it.ranges = append(it.ranges, addrRange{addr, addr})
+		}
+	}
+}
+
+func rangesContain(ranges []addrRange, addr netip.Addr) bool {
+	addr = addr.WithZone("")
+	for _, r := range ranges {
+		if !addr.Less(r.first) && !r.last.Less(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverIPv6 finds candidate hosts inside large IPv6 ranges. It pings the
+// all-nodes multicast group on every interface, which also fills the kernel
+// NDP cache, and returns the responders and cached neighbours that fall
+// within ranges.
+func (s *ScannerService) discoverIPv6(ctx context.Context, ranges []addrRange) []string {
+	seen := make(map[string]struct{})
+	for _, ip := range s.pingAllNodes(ctx) {
+		seen[ip] = struct{}{}
+	}
+	if table, err := readNeighbours6(); err == nil {
+		for ip := range table {
+			seen[ip] = struct{}{}
+		}
+	} else {
+		s.logger.Warn("Failed to read IPv6 neighbour table: ", err)
+	}
+
+	var out []string
+	for ip := range seen {
+		if addr, err := netip.ParseAddr(ip); err == nil && rangesContain(ranges, addr) {
+			out = append(out, ip)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// pingAllNodes sends an ICMPv6 echo request to ff02::1 on every multicast
+// capable interface and returns the addresses that replied.
+func (s *ScannerService) pingAllNodes(ctx context.Context) []string {
+	network := "ip6:ipv6-icmp"
+	if s.mode == ModeUnprivileged {
+		network = "udp6"
+	}
+	c, err := icmp.ListenPacket(network, "::")
+	if err != nil {
+		s.logger.Warn("IPv6 multicast discovery unavailable: ", err)
+		return nil
+	}
+	defer c.Close()
+
+	msg := icmp.Message{
+		Type: ipv6.ICMPTypeEchoRequest,
+		Body: &icmp.Echo{ID: os.Getpid() & 0xffff, Seq: 1, Data: []byte("network-scanner")},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return nil
+	}
+
+	ifaces, _ := net.Interfaces()
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 || ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		var dst net.Addr = &net.IPAddr{IP: net.IPv6linklocalallnodes, Zone: ifi.Name}
+		if network == "udp6" {
+			dst = &net.UDPAddr{IP: net.IPv6linklocalallnodes, Zone: ifi.Name}
+		}
+		_, _ = c.WriteTo(b, dst)
+	}
+
+	deadline := time.Now().Add(s.cfg.IPv6.DiscoveryTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = c.SetReadDeadline(deadline)
+
+	var out []string
+	buf := make([]byte, 1500)
+	for ctx.Err() == nil {
+		n, peer, err := c.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		m, err := icmp.ParseMessage(ipv6.ICMPTypeEchoReply.Protocol(), buf[:n])
+		if err != nil || m.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+		ip := peer.String()
+		if udp, ok := peer.(*net.UDPAddr); ok {
+			ip = (&net.IPAddr{IP: udp.IP, Zone: udp.Zone}).String()
+		}
+		out = append(out, ip)
+	}
+	return out
+}
+
+// recordIPv6Host attaches an IPv6 address to the device resolveIdentity
+// picks for it, so dual-stack hosts stay a single record keyed by their
+// primary address. Unknown IPv6 hosts get their own device, but only when
+// online: offline addresses from a discovery sweep are never recorded.
+func (s *ScannerService) recordIPv6Host(ctx context.Context, ip string, livenessMethod string) model.Device {
+	existing := s.repo.FindByIP(ip)
+	if livenessMethod == "" {
+		if existing == nil || existing.IPAddress != ip {
+			return model.Device{}
+		}
+		d := *existing
+		s.observe(&d, "", time.Now())
+		s.saveDevice(existing, d)
+		return d
+	}
+
+	mac := s.prober.MAC(ctx, ip)
+	existing = s.resolveIdentity(ip, mac)
+
+	now := time.Now()
+	d := model.Device{ID: uuid.New().String(), IPAddress: ip, FirstSeen: now}
+	if existing != nil {
+		d = *existing
+	}
+	d.IPv6Addresses = mergeIPv6(d.IPv6Addresses, ip)
+	s.observe(&d, livenessMethod, now)
+	if mac != "" {
+		d.MACAddress = mac
+		if s.resolver != nil {
+			if resolved := s.resolver.Resolve(mac); resolved != "" {
+				d.Manufacturer = resolved
+			}
+		}
+	}
+	if d.Hostname == "" {
+		d.Hostname = s.prober.Hostname(ctx, ip)
+	}
+	s.enrich(ctx, &d)
+
+	s.saveDevice(existing, d)
+	s.recordAddress(d.ID, ip, now)
+	return d
+}
+
+// mergeIPv6 returns addrs with ip appended if it is not already present.
+func mergeIPv6(addrs []string, ip string) []string {
+	for _, a := range addrs {
+		if strings.EqualFold(a, ip) {
+			return addrs
+		}
+	}
+	return append(slices.Clone(addrs), ip)
+}
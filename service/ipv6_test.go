@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"net/netip"
+	"network-scanner/model"
+	"testing"
+	"time"
+)
+
+func TestSplitDiscovery(t *testing.T) {
+	spec, err := ParseTargets("2001:db8::/64, 2001:db8:1::10-2001:db8:1::12, 10.0.0.1", nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	enumerate, discover := spec.splitDiscovery(256)
+	if enumerate.Size() != 4 {
+		t.Errorf("expected 4 enumerated addresses, got %d", enumerate.Size())
+	}
+	if len(discover) != 1 || discover[0].first != netip.MustParseAddr("2001:db8::") {
+		t.Errorf("expected the /64 to be discovered, got %v", discover)
+	}
+}
+
+func TestStartScan_LargeIPv6PrefixIsNotTooLarge(t *testing.T) {
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{})
+	job, err := svc.StartScan("2001:db8::/64")
+	if err != nil {
+		t.Fatalf("expected /64 to be accepted for discovery, got %v", err)
+	}
+	if job.HostsTotal != 0 {
+		t.Errorf("expected no enumerated hosts, got %d", job.HostsTotal)
+	}
+	svc.CancelScan(job.ID)
+}
+
+func TestDiscoverIPv6_FiltersByRange(t *testing.T) {
+	old := readNeighbours6
+	readNeighbours6 = func() (map[string]string, error) {
+		return map[string]string{
+			"2001:db8::5":    "aa:bb:cc:dd:ee:05",
+			"2001:db8:ff::1": "aa:bb:cc:dd:ee:06",
+			"fe80::1%eth0":   "aa:bb:cc:dd:ee:07",
+		}, nil
+	}
+	defer func() { readNeighbours6 = old }()
+
+	cfg := DefaultScannerConfig()
+	cfg.IPv6.DiscoveryTimeout = 10 * time.Millisecond
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithConfig(cfg)
+
+	spec, _ := ParseTargets("2001:db8::/64", nil)
+	_, discover := spec.splitDiscovery(256)
+	got := svc.discoverIPv6(context.Background(), discover)
+	if len(got) != 1 || got[0] != "2001:db8::5" {
+		t.Errorf("expected in-range neighbours only, got %v", got)
+	}
+}
+
+func TestRecordHost_MergesIPv6ByMAC(t *testing.T) {
+	old := readNeighbours6
+	readNeighbours6 = func() (map[string]string, error) {
+		return map[string]string{"2001:db8::5": "aa:bb:cc:dd:ee:05"}, nil
+	}
+	defer func() { readNeighbours6 = old }()
+
+	repo := newFakeDeviceRepo()
+	repo.Save(model.Device{ID: "dev-1", IPAddress: "192.168.1.5", MACAddress: "AA:BB:CC:DD:EE:05", Status: "online"})
+	svc := NewScannerService(repo, &dummyLogger{})
+
+	d := svc.recordHost(context.Background(), "2001:db8::5", LivenessICMP)
+	if d.ID != "dev-1" || d.IPAddress != "192.168.1.5" {
+		t.Fatalf("expected IPv6 address merged into dev-1, got %+v", d)
+	}
+	if len(d.IPv6Addresses) != 1 || d.IPv6Addresses[0] != "2001:db8::5" {
+		t.Errorf("expected IPv6 address recorded, got %v", d.IPv6Addresses)
+	}
+	if found := repo.FindByIP("2001:db8::5"); found == nil || found.ID != "dev-1" {
+		t.Errorf("expected lookup by IPv6 address to find dev-1, got %+v", found)
+	}
+
+	svc.recordHost(context.Background(), "2001:db8::99", "")
+	if len(repo.GetAll()) != 1 {
+		t.Errorf("expected offline IPv6 host not to be recorded, got %d devices", len(repo.GetAll()))
+	}
+}
+
+func TestRecordHost_MergesIPv6RecordWithoutMAC(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	repo.Save(model.Device{ID: "dev-1", IPAddress: "192.168.1.5", MACAddress: "aa:bb:cc:dd:ee:05", Tags: []string{"nas"}})
+	repo.Save(model.Device{ID: "anon", IPAddress: "2001:db8::5", IPv6Addresses: []string{"2001:db8::5"}, Tags: []string{"backup"}})
+	net := NewFakeNetwork(1, FakeHost{IP: "2001:db8::5", MAC: "aa:bb:cc:dd:ee:05"})
+	svc := NewScannerService(repo, &dummyLogger{}).WithProber(net)
+
+	d := svc.recordHost(context.Background(), "2001:db8::5", LivenessICMP)
+	if d.ID != "dev-1" || d.IPAddress != "192.168.1.5" {
+		t.Fatalf("expected the MAC record to win, got %+v", d)
+	}
+	if anon, _ := repo.FindByID("anon"); anon != nil {
+		t.Errorf("expected the MAC-less IPv6 record to be merged away, got %+v", anon)
+	}
+	if len(repo.GetAll()) != 1 || len(d.Tags) != 2 {
+		t.Errorf("expected a single device with merged tags, got %d devices, tags %v", len(repo.GetAll()), d.Tags)
+	}
+}
+
+func TestConcurrentPing_IPv6Loopback(t *testing.T) {
+	got, err := concurrentPingRTT([]string{"::1"}, time.Second)
+	if err != nil {
+		t.Skipf("raw ICMPv6 unavailable: %v", err)
+	}
+	if _, ok := got["::1"]; !ok {
+		t.Skip("::1 did not answer; IPv6 may be disabled")
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/j-keck/arping"
+)
+
+const (
+	LivenessICMP = "icmp"
+	LivenessTCP  = "tcp"
+	LivenessARP  = "arp"
+)
+
+// checkLiveness runs the configured probes in order, each one only against
+// the hosts no earlier probe could reach. The result maps every online host
+// to the method that proved it; offline hosts are absent. A probe that fails
+// outright (e.g. no raw socket permission) or is not known to the prober is
+// skipped, and an error is only returned if every probe failed.
+func (s *ScannerService) checkLiveness(ctx context.Context, ips []string) (map[string]string, error) {
+	result := make(map[string]string, len(ips))
+	pending := ips
+	var errs []error
+	ran := 0
+	for _, method := range s.cfg.Liveness.Methods {
+		if len(pending) == 0 {
+			break
+		}
+		probe := s.prober.Liveness(method)
+		if probe == nil {
+			errs = append(errs, fmt.Errorf("%s: unknown liveness method", method))
+			continue
+		}
+		alive, err := probe(ctx, pending)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			s.logger.Warn("Liveness probe ", method, " failed: ", err)
+			errs = append(errs, fmt.Errorf("%s: %w", method, err))
+			continue
+		}
+		ran++
+		var next []string
+		for _, ip := range pending {
+			if alive[ip] {
+				result[ip] = method
+			} else {
+				next = append(next, ip)
+			}
+		}
+		pending = next
+	}
+	if ran == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// tcpAlive treats a host as up if any liveness port accepts a connection or
+// actively refuses it, since a RST proves something answered.
+func (s *ScannerService) tcpAlive(ctx context.Context, ips []string) (map[string]bool, error) {
+	alive := make(map[string]bool)
+	var mu sync.Mutex
+
+	runParallel(ctx, s.cfg.HostParallelism, ips, func(ip string) {
+		if s.tcpReachable(ctx, ip) {
+			mu.Lock()
+			alive[ip] = true
+			mu.Unlock()
+		}
+	})
+	return alive, ctx.Err()
+}
+
+// tcpReachable dials all liveness ports of ip at once and returns as soon as
+// one of them answers.
+func (s *ScannerService) tcpReachable(ctx context.Context, ip string) bool {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ports := s.cfg.Liveness.TCPPorts
+	dialer := net.Dialer{Timeout: s.cfg.Liveness.Timeout}
+	answered := make(chan bool, len(ports))
+	for _, port := range ports {
+		go func(port int) {
+			if err := s.probes.Acquire(ctx, 1); err != nil {
+				answered <- false
+				return
+			}
+			defer s.probes.Release(1)
+			conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+			if err == nil {
+				s.observeTCP(ip, conn)
+				conn.Close()
+			}
+			answered <- err == nil || errors.Is(err, syscall.ECONNREFUSED)
+		}(port)
+	}
+	for range ports {
+		if <-answered {
+			return true
+		}
+	}
+	return false
+}
+
+// arpAlive sends ARP requests to IPv4 hosts on directly attached segments
+// and defers IPv6 hosts to an NDP lookup; hosts behind a router cannot be
+// reached this way and are reported as down.
+func (s *ScannerService) arpAlive(ctx context.Context, ips []string) (map[string]bool, error) {
+	var v4, v6 []string
+	for _, ip := range ips {
+		if isIPv6(ip) {
+			v6 = append(v6, ip)
+		} else {
+			v4 = append(v4, ip)
+		}
+	}
+
+	alive := make(map[string]bool)
+	if len(v6) > 0 {
+		found, err := s.neighbourAlive(ctx, v6)
+		if err != nil && len(v4) == 0 {
+			return nil, err
+		}
+		for ip := range found {
+			alive[ip] = true
+		}
+	}
+
+	var mu sync.Mutex
+	runParallel(ctx, s.cfg.HostParallelism, v4, func(ip string) {
+		addr := net.ParseIP(ip).To4()
+		if addr == nil || addr.IsLoopback() {
+			return
+		}
+		if err := s.probes.Acquire(ctx, 1); err != nil {
+			return
+		}
+		_, _, err := arping.Ping(addr)
+		s.probes.Release(1)
+		if err == nil {
+			mu.Lock()
+			alive[ip] = true
+			mu.Unlock()
+		}
+	})
+	return alive, ctx.Err()
+}
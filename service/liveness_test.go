@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckLiveness_TCPFallback(t *testing.T) {
+	openPort, closeLn := listenLocal(t, nil)
+	defer closeLn()
+
+	cfg := DefaultScannerConfig()
+	cfg.Liveness.Methods = []string{LivenessARP, LivenessTCP}
+	cfg.Liveness.TCPPorts = []int{openPort}
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithConfig(cfg)
+
+	got, err := svc.checkLiveness(context.Background(), []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("checkLiveness error: %v", err)
+	}
+	if got["127.0.0.1"] != LivenessTCP {
+		t.Errorf("expected 127.0.0.1 to be proven by tcp, got %q", got["127.0.0.1"])
+	}
+}
+
+func TestCheckLiveness_RefusedCountsAsAlive(t *testing.T) {
+	closedPort, closeLn := listenLocal(t, nil)
+	closeLn()
+
+	cfg := DefaultScannerConfig()
+	cfg.Liveness.Methods = []string{LivenessTCP}
+	cfg.Liveness.TCPPorts = []int{closedPort}
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithConfig(cfg)
+
+	got, _ := svc.checkLiveness(context.Background(), []string{"127.0.0.1"})
+	if got["127.0.0.1"] != LivenessTCP {
+		t.Errorf("expected a refused connection to prove liveness, got %q", got["127.0.0.1"])
+	}
+}
+
+func TestCheckLiveness_SkipsUnknownMethods(t *testing.T) {
+	cfg := DefaultScannerConfig()
+	cfg.Liveness.Methods = []string{"smoke-signal", LivenessICMP}
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).
+		WithConfig(cfg).
+		WithProber(NewFakeNetwork(1, FakeHost{IP: "10.0.0.1"}))
+
+	got, err := svc.checkLiveness(context.Background(), []string{"10.0.0.1"})
+	if err != nil {
+		t.Fatalf("checkLiveness error: %v", err)
+	}
+	if got["10.0.0.1"] != LivenessICMP {
+		t.Errorf("expected icmp to prove liveness, got %q", got["10.0.0.1"])
+	}
+}
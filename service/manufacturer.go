@@ -1,56 +1,412 @@
 package service
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// LocallyAdministered is reported as the manufacturer of addresses with the
+// locally administered bit set and no registered CID block, which usually
+// means a randomized MAC.
+const LocallyAdministered = "Locally administered"
+
+// ErrInvalidVendorFile is returned for vendor files in no known format or
+// without a single usable entry.
+var ErrInvalidVendorFile = errors.New("invalid vendor file")
+
 type ManufacturerResolver interface {
 	Resolve(mac string) string
 }
 
+// VendorDatabase is a ManufacturerResolver whose data can be replaced while
+// the server runs.
+type VendorDatabase interface {
+	ManufacturerResolver
+	// Reload re-reads the vendor files and returns the number of prefixes
+	// loaded. On error the previous data stays in use.
+	Reload() (int, error)
+	// Import stores a vendor file and reloads. Nothing is stored if the
+	// files do not load with it.
+	Import(in io.Reader) (int, error)
+	// Changed reports whether the files on disk differ from the last load,
+	// whether or not it succeeded.
+	Changed() bool
+}
+
+// blockNibbles is the prefix length, in hex digits, of each IEEE registry
+// block type.
+var blockNibbles = map[string]int{
+	"MA-L": 6,
+	"CID":  6,
+	"MA-M": 7,
+	"MA-S": 9,
+	"IAB":  9,
+}
+
+// vendorTable maps prefix length in hex digits to upper-case prefixes.
+type vendorTable struct {
+	lookup map[int]map[string]string
+	// lengths lists the prefix lengths present, longest first.
+	lengths []int
+}
+
+func newVendorTable() *vendorTable {
+	return &vendorTable{lookup: make(map[int]map[string]string)}
+}
+
+// add registers the first n hex digits of prefix. Entries with a shorter
+// prefix or no vendor are ignored.
+func (t *vendorTable) add(prefix string, n int, vendor string) {
+	prefix = strings.ToUpper(macHex(prefix))
+	vendor = strings.TrimSpace(vendor)
+	if n <= 0 {
+		n = len(prefix)
+	}
+	if vendor == "" || n < 6 || len(prefix) < n {
+		return
+	}
+	if t.lookup[n] == nil {
+		t.lookup[n] = make(map[string]string)
+		t.lengths = append(t.lengths, n)
+		slices.SortFunc(t.lengths, func(a, b int) int { return b - a })
+	}
+	t.lookup[n][prefix[:n]] = vendor
+}
+
+// merge copies every entry of o into t, replacing existing ones.
+func (t *vendorTable) merge(o *vendorTable) {
+	for n, entries := range o.lookup {
+		for prefix, vendor := range entries {
+			t.add(prefix, n, vendor)
+		}
+	}
+}
+
+func (t *vendorTable) size() int {
+	n := 0
+	for _, entries := range t.lookup {
+		n += len(entries)
+	}
+	return n
+}
+
+func (t *vendorTable) resolve(hex string) string {
+	for _, n := range t.lengths {
+		if len(hex) < n {
+			continue
+		}
+		if vendor, ok := t.lookup[n][hex[:n]]; ok {
+			return vendor
+		}
+	}
+	return ""
+}
+
+// OfflineManufacturerResolver resolves vendors from a copy of the IEEE
+// registries, matching the longest MA-S/IAB, MA-M or MA-L/CID prefix. It
+// reads the vendor CSV at path plus every file imported into importDir, and
+// swaps in new data atomically on reload.
 type OfflineManufacturerResolver struct {
-	lookup map[string]string
+	path      string
+	importDir string
+
+	// mu serializes reloads and imports; lookups only read table.
+	mu          sync.Mutex
+	table       atomic.Pointer[vendorTable]
+	fingerprint string
 }
 
-func NewOfflineManufacturerResolver(path string) *OfflineManufacturerResolver {
-	file, err := os.Open(path)
-	if err != nil {
-		log.Fatalf("Failed to open MAC vendor CSV: %v", err)
+// NewOfflineManufacturerResolver loads the vendor files. The resolver is
+// usable even when an error is returned; it then resolves nothing until a
+// reload succeeds.
+func NewOfflineManufacturerResolver(path, importDir string) (*OfflineManufacturerResolver, error) {
+	r := &OfflineManufacturerResolver{path: path, importDir: importDir}
+	r.table.Store(newVendorTable())
+	_, err := r.Reload()
+	return r, err
+}
+
+func (r *OfflineManufacturerResolver) Resolve(mac string) string {
+	hex := strings.ToUpper(macHex(mac))
+	if len(hex) < 6 {
+		return ""
+	}
+	if vendor := r.table.Load().resolve(hex); vendor != "" {
+		return vendor
 	}
-	defer file.Close()
+	if isLocallyAdministered(hex) {
+		return LocallyAdministered
+	}
+	return ""
+}
+
+func (r *OfflineManufacturerResolver) Reload() (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reload()
+}
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+func (r *OfflineManufacturerResolver) reload() (int, error) {
+	// Remember the files even when they are bad, so a watcher retries only
+	// once they change again.
+	r.fingerprint = r.currentFingerprint()
+	table, err := buildVendorTable(r.files(), nil)
 	if err != nil {
-		log.Fatalf("Failed to read MAC vendor CSV: %v", err)
+		return 0, err
 	}
+	r.table.Store(table)
+	return table.size(), nil
+}
 
-	lookup := make(map[string]string)
-	for i, row := range records {
-		if i == 0 {
-			continue
+// buildVendorTable merges files in order. Files present in parsed are taken
+// from there instead of being read from disk.
+func buildVendorTable(files []string, parsed map[string]*vendorTable) (*vendorTable, error) {
+	table := newVendorTable()
+	for _, file := range files {
+		t, ok := parsed[file]
+		if !ok {
+			var err error
+			if t, err = readVendorFile(file); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
 		}
-		if len(row) < 2 {
-			continue
+		table.merge(t)
+	}
+	return table, nil
+}
+
+// Import validates an uploaded vendor file and stores it in the import
+// directory, replacing an earlier upload of the same registry. The upload
+// is only stored if the vendor files load with it in place.
+func (r *OfflineManufacturerResolver) Import(in io.Reader) (int, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return 0, err
+	}
+	parsed, name, err := parseVendors(data)
+	if err != nil {
+		return 0, err
+	}
+	if r.importDir == "" {
+		return 0, errors.New("no vendor import directory configured")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	target := filepath.Join(r.importDir, name)
+	files := r.files()
+	if !slices.Contains(files, target) {
+		files = append(files, target)
+		slices.Sort(files[1:])
+	}
+	table, err := buildVendorTable(files, map[string]*vendorTable{target: parsed})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(r.importDir, 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(r.importDir, ".import-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return 0, err
+	}
+	r.table.Store(table)
+	r.fingerprint = r.currentFingerprint()
+	return table.size(), nil
+}
+
+func (r *OfflineManufacturerResolver) Changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.currentFingerprint() != r.fingerprint
+}
+
+// files lists the base CSV followed by the imported files in name order.
+func (r *OfflineManufacturerResolver) files() []string {
+	files := []string{r.path}
+	if r.importDir == "" {
+		return files
+	}
+	entries, err := os.ReadDir(r.importDir)
+	if err != nil {
+		return files
+	}
+	for _, e := range entries {
+		if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+			files = append(files, filepath.Join(r.importDir, e.Name()))
 		}
-		macPrefix := strings.ToUpper(strings.TrimSpace(row[0]))
-		vendorName := strings.TrimSpace(row[1])
-		if macPrefix != "" && vendorName != "" {
-			lookup[macPrefix] = vendorName
+	}
+	return files
+}
+
+// currentFingerprint summarises the name, size and modification time of
+// every vendor file.
+func (r *OfflineManufacturerResolver) currentFingerprint() string {
+	var b strings.Builder
+	for _, file := range r.files() {
+		if fi, err := os.Stat(file); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", file, fi.Size(), fi.ModTime().UnixNano())
 		}
 	}
+	return b.String()
+}
 
-	return &OfflineManufacturerResolver{lookup: lookup}
+func readVendorFile(path string) (*vendorTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, _, err := parseVendors(data)
+	return t, err
 }
 
-func (r *OfflineManufacturerResolver) Resolve(mac string) string {
-	parts := strings.Split(strings.ToUpper(mac), ":")
-	if len(parts) < 3 {
+// parseVendors reads any of the supported vendor formats: this project's
+// CSV ("Mac Prefix", "Vendor Name", "Block Type"), the IEEE registry CSVs
+// (oui.csv, mam.csv, oui36.csv, cid.csv) and the IEEE oui.txt listing. It
+// also returns the file name an import of this data is stored under.
+func parseVendors(data []byte) (*vendorTable, string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	first, _, _ := bytes.Cut(bytes.TrimLeft(data, " \t\r\n"), []byte("\n"))
+	header := strings.ToLower(string(first))
+
+	var t *vendorTable
+	var name string
+	var err error
+	switch {
+	case strings.HasPrefix(header, "mac prefix"):
+		t, err = parseVendorCSV(data)
+		name = "vendors.csv"
+	case strings.HasPrefix(header, "registry,"):
+		t, name, err = parseIEEECSV(data)
+	default:
+		t = parseOUIText(data)
+		name = "oui.txt"
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidVendorFile, err)
+	}
+	if t.size() == 0 {
+		return nil, "", fmt.Errorf("%w: no vendor entries found", ErrInvalidVendorFile)
+	}
+	return t, name, nil
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader.ReadAll()
+}
+
+// columns maps lower-case header names to their index.
+func columns(header []string) map[string]int {
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	return cols
+}
+
+func field(row []string, i int, ok bool) string {
+	if !ok || i >= len(row) {
 		return ""
 	}
-	prefix := strings.Join(parts[:3], ":")
-	return r.lookup[prefix]
+	return strings.TrimSpace(row[i])
+}
+
+// parseVendorCSV reads this project's vendor CSV. Rows without a block type
+// are sized by the length of their prefix.
+func parseVendorCSV(data []byte) (*vendorTable, error) {
+	records, err := readCSV(data)
+	if err != nil {
+		return nil, err
+	}
+	t := newVendorTable()
+	if len(records) == 0 {
+		return t, nil
+	}
+	cols := columns(records[0])
+	prefixCol, okPrefix := cols["mac prefix"]
+	vendorCol, okVendor := cols["vendor name"]
+	blockCol, okBlock := cols["block type"]
+	for _, row := range records[1:] {
+		t.add(field(row, prefixCol, okPrefix), blockNibbles[field(row, blockCol, okBlock)], field(row, vendorCol, okVendor))
+	}
+	return t, nil
+}
+
+// parseIEEECSV reads a registry export from standards-oui.ieee.org with
+// the columns Registry, Assignment, Organization Name and Organization
+// Address.
+func parseIEEECSV(data []byte) (*vendorTable, string, error) {
+	records, err := readCSV(data)
+	if err != nil {
+		return nil, "", err
+	}
+	t := newVendorTable()
+	name := "ieee.csv"
+	if len(records) == 0 {
+		return t, name, nil
+	}
+	cols := columns(records[0])
+	registryCol, okRegistry := cols["registry"]
+	assignmentCol, okAssignment := cols["assignment"]
+	orgCol, okOrg := cols["organization name"]
+	for _, row := range records[1:] {
+		registry := field(row, registryCol, okRegistry)
+		n, known := blockNibbles[registry]
+		if !known {
+			continue
+		}
+		t.add(field(row, assignmentCol, okAssignment), n, field(row, orgCol, okOrg))
+		name = strings.ToLower(registry) + ".csv"
+	}
+	return t, name, nil
+}
+
+// parseOUIText reads the IEEE oui.txt listing, taking the vendor from the
+// "(hex)" line of every assignment.
+func parseOUIText(data []byte) *vendorTable {
+	t := newVendorTable()
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		prefix, vendor, ok := strings.Cut(sc.Text(), "(hex)")
+		if !ok {
+			continue
+		}
+		t.add(strings.TrimSpace(prefix), 6, vendor)
+	}
+	return t
+}
+
+// isLocallyAdministered reports whether the U/L bit, the second lowest bit
+// of the first octet, is set. hex is the address without separators.
+func isLocallyAdministered(hex string) bool {
+	switch hex[1] {
+	case '2', '3', '6', '7', 'A', 'B', 'E', 'F':
+		return true
+	}
+	return false
 }
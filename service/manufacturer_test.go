@@ -0,0 +1,178 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const vendorFixture = `Mac Prefix,Vendor Name,Private,Block Type,Last Update
+00:50:C2,IEEE Registration Authority,false,MA-L,2015/11/17
+00:50:C2:00:0,T.L.S. Corp.,false,IAB,2015/08/29
+00:1B:C5:00:0,Converging Systems Inc.,false,MA-S,2016/02/18
+1C:87:76,IEEE Registration Authority,false,MA-L,2015/11/17
+1C:87:76:D,Qivivo,false,MA-M,2016/02/05
+00:00:0C,"Cisco Systems, Inc",false,MA-L,2015/11/17
+8A:34:BC,Fiberworks AS,false,CID,2016/02/25
+00:48:54,,true,,0001/01/01
+`
+
+// fixtureResolver writes csv to a temporary vendor file and loads it.
+func fixtureResolver(t *testing.T, csv string) *OfflineManufacturerResolver {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mac-vendors.csv")
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewOfflineManufacturerResolver(path, filepath.Join(dir, "vendors"))
+	if err != nil {
+		t.Fatalf("NewOfflineManufacturerResolver error: %v", err)
+	}
+	return r
+}
+
+func TestManufacturerResolver_BlockTypes(t *testing.T) {
+	r := fixtureResolver(t, vendorFixture)
+	cases := []struct {
+		name string
+		mac  string
+		want string
+	}{
+		{"MA-L", "00:00:0c:12:34:56", "Cisco Systems, Inc"},
+		{"MA-M", "1C:87:76:D1:23:45", "Qivivo"},
+		{"MA-M falls back to MA-L", "1C:87:76:E1:23:45", "IEEE Registration Authority"},
+		{"MA-S", "00-1B-C5-00-0A-BC", "Converging Systems Inc."},
+		{"IAB", "0050.c200.0fff", "T.L.S. Corp."},
+		{"IAB falls back to MA-L", "00:50:C2:00:10:00", "IEEE Registration Authority"},
+		{"CID", "8a:34:bc:00:00:01", "Fiberworks AS"},
+		{"private block", "00:48:54:00:00:01", ""},
+		{"unknown", "00:11:22:33:44:55", ""},
+		{"locally administered", "da:a1:19:00:00:01", LocallyAdministered},
+		{"too short", "00:00", ""},
+	}
+	for _, c := range cases {
+		if got := r.Resolve(c.mac); got != c.want {
+			t.Errorf("%s: Resolve(%q) = %q, want %q", c.name, c.mac, got, c.want)
+		}
+	}
+}
+
+func TestManufacturerResolver_RegistryFile(t *testing.T) {
+	r, err := NewOfflineManufacturerResolver("../data/mac-vendors.csv", "")
+	if err != nil {
+		t.Fatalf("failed to load vendor CSV: %v", err)
+	}
+	for _, n := range []int{9, 7, 6} {
+		if len(r.table.Load().lookup[n]) == 0 {
+			t.Errorf("expected %d-digit prefixes to be loaded", n)
+		}
+	}
+	if got := r.Resolve("1C:87:76:D0:00:01"); got != "Qivivo" {
+		t.Errorf("expected MA-M block to resolve, got %q", got)
+	}
+	if got := r.Resolve("00:1B:C5:00:00:01"); got != "Converging Systems Inc." {
+		t.Errorf("expected MA-S block to resolve, got %q", got)
+	}
+}
+
+const ouiText = `OUI/MA-L                                                    Organization
+company_id                                                  Organization
+                                                            Address
+
+00-22-72   (hex)		American Micro-Fuel Device Corp.
+002272     (base 16)		American Micro-Fuel Device Corp.
+				2181 Buchanan Loop
+				Ferndale  WA  98248
+				US
+
+00-00-0C   (hex)		Cisco Systems Inc (renamed)
+00000C     (base 16)		Cisco Systems Inc (renamed)
+`
+
+const mamCSV = "Registry,Assignment,Organization Name,Organization Address\n" +
+	"MA-M,1C8776E,\"Acme, Inc.\",1 Road Town US 12345\n"
+
+const oui36CSV = "Registry,Assignment,Organization Name,Organization Address\n" +
+	"MA-S,001BC5001,Widgets GmbH,Strasse 1 Berlin DE\n"
+
+func TestManufacturerResolver_ImportFormats(t *testing.T) {
+	r := fixtureResolver(t, vendorFixture)
+	cases := []struct {
+		name, data, mac, want, file string
+	}{
+		{"oui.txt", ouiText, "00:22:72:00:00:01", "American Micro-Fuel Device Corp.", "oui.txt"},
+		{"mam.csv", mamCSV, "1C:87:76:E0:00:01", "Acme, Inc.", "ma-m.csv"},
+		{"oui36.csv", oui36CSV, "00:1B:C5:00:1F:FF", "Widgets GmbH", "ma-s.csv"},
+	}
+	for _, c := range cases {
+		if _, err := r.Import(strings.NewReader(c.data)); err != nil {
+			t.Fatalf("%s: Import error: %v", c.name, err)
+		}
+		if got := r.Resolve(c.mac); got != c.want {
+			t.Errorf("%s: Resolve(%q) = %q, want %q", c.name, c.mac, got, c.want)
+		}
+		if _, err := os.Stat(filepath.Join(r.importDir, c.file)); err != nil {
+			t.Errorf("%s: expected import to be stored as %s: %v", c.name, c.file, err)
+		}
+	}
+	// Imports override the base file but longer prefixes still win.
+	if got := r.Resolve("00:00:0C:00:00:01"); got != "Cisco Systems Inc (renamed)" {
+		t.Errorf("expected imported entry to override the base file, got %q", got)
+	}
+	if got := r.Resolve("1C:87:76:D0:00:01"); got != "Qivivo" {
+		t.Errorf("expected untouched MA-M block to keep resolving, got %q", got)
+	}
+
+	for _, bad := range []string{"", "hello world\n", "Registry,Assignment\nXX-Y,123,Foo\n"} {
+		if _, err := r.Import(strings.NewReader(bad)); !errors.Is(err, ErrInvalidVendorFile) {
+			t.Errorf("expected ErrInvalidVendorFile for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestManufacturerResolver_ReloadKeepsDataOnError(t *testing.T) {
+	r := fixtureResolver(t, vendorFixture)
+	if r.Changed() {
+		t.Errorf("expected no change right after loading")
+	}
+
+	// Give the rewritten file a distinct modification time.
+	later := time.Now().Add(time.Minute)
+	os.WriteFile(r.path, []byte("garbage\n"), 0o644)
+	os.Chtimes(r.path, later, later)
+	if !r.Changed() {
+		t.Fatalf("expected the rewritten file to be noticed")
+	}
+	if _, err := r.Reload(); !errors.Is(err, ErrInvalidVendorFile) {
+		t.Errorf("expected ErrInvalidVendorFile, got %v", err)
+	}
+	if r.Changed() {
+		t.Errorf("expected a failed reload not to be retried until the files change")
+	}
+	if _, err := r.Import(strings.NewReader(ouiText)); !errors.Is(err, ErrInvalidVendorFile) {
+		t.Errorf("expected the import to fail while the base file is bad, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(r.importDir, "oui.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected a failed import not to be stored, got %v", err)
+	}
+	if got := r.Resolve("00:00:0c:12:34:56"); got != "Cisco Systems, Inc" {
+		t.Errorf("expected previous data to stay in use, got %q", got)
+	}
+
+	os.WriteFile(r.path, []byte("Mac Prefix,Vendor Name\n00:00:0C,Cisco\n"), 0o644)
+	os.Chtimes(r.path, later.Add(time.Minute), later.Add(time.Minute))
+	if n, err := r.Reload(); err != nil || n != 1 {
+		t.Fatalf("expected 1 prefix after reload, got %d, %v", n, err)
+	}
+	if got := r.Resolve("00:00:0c:12:34:56"); got != "Cisco" {
+		t.Errorf("expected reloaded data, got %q", got)
+	}
+
+	if r, err := NewOfflineManufacturerResolver(filepath.Join(t.TempDir(), "missing.csv"), ""); err == nil || r.Resolve("00:00:0c:12:34:56") != "" {
+		t.Errorf("expected a usable empty resolver and an error for a missing file")
+	}
+}
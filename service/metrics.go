@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"math"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"time"
+)
+
+func (s *ScannerService) WithMetricsRepository(r repository.MetricsRepository) *ScannerService {
+	s.metrics = r
+	return s
+}
+
+// sampleLatency sends MetricsConfig.EchoCount echo requests to every device
+// and stores one latency sample per device.
+func (s *ScannerService) sampleLatency(ctx context.Context, devices []model.Device, now time.Time) {
+	if len(devices) == 0 {
+		return
+	}
+	count := s.cfg.Metrics.EchoCount
+	ips := make([]string, len(devices))
+	for i, d := range devices {
+		ips[i] = d.IPAddress
+	}
+	rtts, err := s.prober.Echo(ctx, ips, count)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Warn("Latency sampling failed: ", err)
+		}
+		return
+	}
+
+	samples := make([]model.LatencySample, len(devices))
+	for i, d := range devices {
+		samples[i] = latencySample(d.ID, count, rtts[d.IPAddress], now)
+	}
+	if err := s.metrics.Append(samples...); err != nil {
+		s.logger.Error("Failed to store latency samples: ", err)
+	}
+}
+
+// latencySample computes loss and RTT statistics for sent requests of which
+// rtts came back.
+func latencySample(deviceID string, sent int, rtts []time.Duration, now time.Time) model.LatencySample {
+	received := min(len(rtts), sent)
+	m := model.LatencySample{
+		DeviceID:    deviceID,
+		Timestamp:   now,
+		Sent:        sent,
+		Received:    received,
+		LossPercent: float64(sent-received) / float64(sent) * 100,
+	}
+	if received == 0 {
+		return m
+	}
+
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	var sum, jitter time.Duration
+	lo, hi := rtts[0], rtts[0]
+	for i, rtt := range rtts[:received] {
+		sum += rtt
+		lo, hi = min(lo, rtt), max(hi, rtt)
+		if i > 0 {
+			jitter += (rtt - rtts[i-1]).Abs()
+		}
+	}
+	m.RTTMinMs = ms(lo)
+	m.RTTMaxMs = ms(hi)
+	m.RTTAvgMs = round3(ms(sum) / float64(received))
+	if received > 1 {
+		m.JitterMs = round3(ms(jitter) / float64(received-1))
+	}
+	return m
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// pruneMetrics drops samples older than MetricsConfig.Retention.
+func (s *ScannerService) pruneMetrics(now time.Time) {
+	if err := s.metrics.Prune(now.Add(-s.cfg.Metrics.Retention)); err != nil {
+		s.logger.Error("Failed to prune latency samples: ", err)
+	}
+}
+
+func (s *ScannerService) DeviceMetrics(id string, from, to time.Time) ([]model.LatencySample, error) {
+	return s.metrics.Find(id, from, to)
+}
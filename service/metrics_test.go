@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"network-scanner/model"
+	"testing"
+	"time"
+)
+
+func TestLatencySample(t *testing.T) {
+	now := time.Now()
+	ms := time.Millisecond
+	m := latencySample("d1", 4, []time.Duration{10 * ms, 14 * ms, 12 * ms}, now)
+	if m.Sent != 4 || m.Received != 3 || m.LossPercent != 25 {
+		t.Errorf("unexpected counts: %+v", m)
+	}
+	if m.RTTMinMs != 10 || m.RTTMaxMs != 14 || m.RTTAvgMs != 12 {
+		t.Errorf("unexpected rtt stats: %+v", m)
+	}
+	// |14-10| and |12-14| averaged.
+	if m.JitterMs != 3 {
+		t.Errorf("expected jitter 3ms, got %v", m.JitterMs)
+	}
+
+	lost := latencySample("d1", 3, nil, now)
+	if lost.LossPercent != 100 || lost.RTTAvgMs != 0 {
+		t.Errorf("expected full loss and no rtt, got %+v", lost)
+	}
+}
+
+func TestPoller_SamplesICMPDevices(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	now := time.Now()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1", Status: "online", FirstSeen: now, LastSeen: now})
+	repo.Save(model.Device{ID: "d2", IPAddress: "10.0.0.2", Status: "online", FirstSeen: now, LastSeen: now})
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.1", Latency: 2 * time.Millisecond}, FakeHost{IP: "10.0.0.2", Down: true})
+
+	cfg := fastPolling()
+	cfg.Liveness.Methods = []string{LivenessICMP}
+	cfg.Metrics.EchoCount = 2
+	svc := NewScannerService(repo, &dummyLogger{}).WithConfig(cfg).WithProber(net)
+
+	p := &poller{s: svc, entries: make(map[string]*pollEntry)}
+	// The first tick only schedules the freshly loaded devices.
+	p.tick(context.Background(), time.Now())
+	p.tick(context.Background(), time.Now().Add(time.Second))
+	p.samplers.Wait()
+
+	got, _ := svc.DeviceMetrics("d1", time.Time{}, time.Time{})
+	if len(got) != 1 || got[0].Sent != 2 || got[0].Received != 2 || got[0].RTTAvgMs != 2 {
+		t.Errorf("expected one full sample for d1, got %+v", got)
+	}
+	if got, _ := svc.DeviceMetrics("d2", time.Time{}, time.Time{}); len(got) != 0 {
+		t.Errorf("expected no samples for an unreachable device, got %+v", got)
+	}
+}
+
+func TestPoller_SamplesWithoutBlockingTicks(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	now := time.Now()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1", Status: "online", FirstSeen: now, LastSeen: now})
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.1", Latency: 50 * time.Millisecond})
+
+	cfg := fastPolling()
+	cfg.Liveness.Methods = []string{LivenessICMP}
+	cfg.Metrics.EchoCount = 10
+	svc := NewScannerService(repo, &dummyLogger{}).WithConfig(cfg).WithProber(net)
+
+	p := &poller{s: svc, entries: make(map[string]*pollEntry)}
+	p.tick(context.Background(), time.Now())
+	start := time.Now()
+	p.tick(context.Background(), time.Now().Add(time.Second))
+	// A second round while the first is still echoing is skipped.
+	p.tick(context.Background(), time.Now().Add(2*time.Second))
+	if elapsed := time.Since(start); elapsed >= 400*time.Millisecond {
+		t.Errorf("expected ticks not to wait for 10 echo rounds, took %v", elapsed)
+	}
+
+	p.samplers.Wait()
+	if got, _ := svc.DeviceMetrics("d1", time.Time{}, time.Time{}); len(got) != 1 || got[0].Sent != 10 {
+		t.Errorf("expected one sample of 10 echoes, got %+v", got)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"sync"
+
+	"golang.org/x/net/icmp"
+)
+
+const (
+	ModeAuto         = "auto"
+	ModePrivileged   = "privileged"
+	ModeUnprivileged = "unprivileged"
+)
+
+// detectMode picks privileged mode when raw ICMP sockets can be opened
+// (root or CAP_NET_RAW) and unprivileged mode otherwise. Permissions do not
+// change while the process runs, so the socket is only opened once.
+var detectMode = sync.OnceValue(func() string {
+	if c, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0"); err == nil {
+		c.Close()
+		return ModePrivileged
+	}
+	return ModeUnprivileged
+})
+
+// unprivilegedICMPAvailable reports whether the kernel lets this process open
+// ICMP datagram sockets, which is governed by net.ipv4.ping_group_range.
+func unprivilegedICMPAvailable() bool {
+	c, err := icmp.ListenPacket("udp4", "0.0.0.0")
+	if err != nil {
+		return false
+	}
+	c.Close()
+	return true
+}
+
+func (s *ScannerService) Mode() string {
+	return s.mode
+}
+
+func (s *ScannerService) resolveMode() {
+	mode := s.cfg.Mode
+	if mode != ModePrivileged && mode != ModeUnprivileged {
+		if mode != ModeAuto {
+			s.logger.Warn("Unknown scanner mode ", mode, ", detecting automatically")
+		}
+		mode = detectMode()
+	}
+	s.mode = mode
+}
+
+// LogMode reports the active scanner mode and what it implies for probing.
+func (s *ScannerService) LogMode() {
+	switch s.mode {
+	case ModePrivileged:
+		s.logger.Info("Scanner running in privileged mode: raw ICMP sockets and ARP requests")
+	case ModeUnprivileged:
+		if unprivilegedICMPAvailable() {
+			s.logger.Info("Scanner running in unprivileged mode: ICMP datagram sockets and kernel neighbour table")
+		} else {
+			s.logger.Warn("Scanner running in unprivileged mode but ICMP datagram sockets are not permitted " +
+				"(check net.ipv4.ping_group_range); liveness relies on TCP and neighbour table only")
+		}
+	}
+}
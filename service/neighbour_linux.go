@@ -0,0 +1,75 @@
+//go:build linux
+
+package service
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+)
+
+const (
+	ndaDst    = 1
+	ndaLLAddr = 2
+
+	nudIncomplete = 0x01
+	nudFailed     = 0x20
+
+	sizeofNdMsg = 12
+)
+
+// dumpNeighbours6 dumps the kernel IPv6 neighbour (NDP) cache over netlink and
+// returns resolved entries keyed by address. Link-local addresses carry their
+// interface zone, e.g. "fe80::1%eth0".
+func dumpNeighbours6() (map[string]string, error) {
+	tab, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_INET6)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(tab)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string)
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < sizeofNdMsg {
+			continue
+		}
+		ifindex := int(int32(binary.NativeEndian.Uint32(m.Data[4:8])))
+		state := binary.NativeEndian.Uint16(m.Data[8:10])
+		if state == 0 || state&(nudIncomplete|nudFailed) != 0 {
+			continue
+		}
+
+		var ip net.IP
+		var mac net.HardwareAddr
+		for b := m.Data[sizeofNdMsg:]; len(b) >= syscall.SizeofRtAttr; {
+			l := int(binary.NativeEndian.Uint16(b[0:2]))
+			typ := binary.NativeEndian.Uint16(b[2:4])
+			if l < syscall.SizeofRtAttr || l > len(b) {
+				break
+			}
+			val := b[syscall.SizeofRtAttr:l]
+			switch typ {
+			case ndaDst:
+				ip = net.IP(val)
+			case ndaLLAddr:
+				mac = net.HardwareAddr(val)
+			}
+			b = b[min((l+syscall.RTA_ALIGNTO-1)&^(syscall.RTA_ALIGNTO-1), len(b)):]
+		}
+		if len(ip) != net.IPv6len || len(mac) == 0 {
+			continue
+		}
+
+		key := ip.String()
+		if ip.IsLinkLocalUnicast() {
+			if ifi, err := net.InterfaceByIndex(ifindex); err == nil {
+				key += "%" + ifi.Name
+			}
+		}
+		out[key] = mac.String()
+	}
+	return out, nil
+}
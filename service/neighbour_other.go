@@ -0,0 +1,9 @@
+//go:build !linux
+
+package service
+
+import "errors"
+
+func dumpNeighbours6() (map[string]string, error) {
+	return nil, errors.New("IPv6 neighbour table is only supported on Linux")
+}
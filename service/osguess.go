@@ -0,0 +1,220 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"network-scanner/model"
+	"reflect"
+	"strings"
+)
+
+// StackTraits is what a host's network stack revealed while it was probed.
+type StackTraits struct {
+	// TTL is the TTL or hop limit of an ICMP echo reply, 0 if none was seen.
+	TTL int
+	// TCP is nil until a TCP connection to the host succeeded.
+	TCP *TCPTraits
+}
+
+// TCPTraits describes the SYN/ACK a host answered a connection with.
+type TCPTraits struct {
+	// Window is the advertised window, 0 if unknown.
+	Window        int
+	WindowScaling bool
+	SACK          bool
+	Timestamps    bool
+}
+
+// StackProber is implemented by Probers that remember how each host's
+// network stack answered their probes.
+type StackProber interface {
+	Stack(ip string) (StackTraits, bool)
+}
+
+// maxStacks caps the hosts whose stack traits are held until the next
+// fingerprinting pass. Status polls keep observing devices between scans.
+const maxStacks = 4096
+
+func (s *ScannerService) observeTTL(ip string, ttl int) {
+	if ttl <= 0 {
+		return
+	}
+	s.stackMu.Lock()
+	defer s.stackMu.Unlock()
+	t := s.stackEntry(ip)
+	t.TTL = ttl
+	s.stacks[ip] = t
+}
+
+// observeTCP records the handshake of an established connection to ip.
+func (s *ScannerService) observeTCP(ip string, conn net.Conn) {
+	tcp, ok := tcpTraits(conn)
+	if !ok {
+		return
+	}
+	s.stackMu.Lock()
+	defer s.stackMu.Unlock()
+	t := s.stackEntry(ip)
+	t.TCP = &tcp
+	s.stacks[ip] = t
+}
+
+// stackEntry returns the traits recorded for ip, making room for them if
+// the map is full. Callers must hold stackMu.
+func (s *ScannerService) stackEntry(ip string) StackTraits {
+	t, ok := s.stacks[ip]
+	if !ok && len(s.stacks) >= maxStacks {
+		for evict := range s.stacks {
+			delete(s.stacks, evict)
+			break
+		}
+	}
+	return t
+}
+
+func (s *ScannerService) stack(ip string) (StackTraits, bool) {
+	s.stackMu.Lock()
+	defer s.stackMu.Unlock()
+	t, ok := s.stacks[ip]
+	return t, ok
+}
+
+// forgetStacks drops the traits of ips once a guess has been made from them.
+func (s *ScannerService) forgetStacks(ips []string) {
+	s.stackMu.Lock()
+	defer s.stackMu.Unlock()
+	for _, ip := range ips {
+		delete(s.stacks, ip)
+	}
+}
+
+func (s *ScannerService) resetStacks() {
+	s.stackMu.Lock()
+	defer s.stackMu.Unlock()
+	s.stacks = make(map[string]StackTraits)
+}
+
+// initialTTL rounds an observed TTL up to the initial value the sender most
+// likely used, or returns 0 for no TTL.
+func initialTTL(ttl int) int {
+	for _, initial := range []int{32, 64, 128, 255} {
+		if ttl > 0 && ttl <= initial {
+			return initial
+		}
+	}
+	return 0
+}
+
+// ttlOSHints are keyed by initial TTL. 64 is also used by macOS and the
+// BSDs, which are reported as linux.
+var ttlOSHints = map[int]hints{
+	32:  {model.OSEmbedded: 0.5},
+	64:  {model.OSLinux: 0.4, model.OSEmbedded: 0.2},
+	128: {model.OSWindows: 0.7},
+	255: {model.OSNetwork: 0.6, model.OSEmbedded: 0.1},
+}
+
+// windowOSHints are SYN/ACK windows typical of one stack.
+var windowOSHints = map[int]hints{
+	4128:  {model.OSNetwork: 0.6},
+	5792:  {model.OSLinux: 0.3},
+	5840:  {model.OSLinux: 0.3},
+	8192:  {model.OSWindows: 0.4},
+	14480: {model.OSLinux: 0.3},
+	14600: {model.OSLinux: 0.3},
+	28960: {model.OSLinux: 0.3},
+	29200: {model.OSLinux: 0.3},
+	43440: {model.OSLinux: 0.3},
+	65160: {model.OSLinux: 0.3},
+}
+
+// windowHints weighs the window of a SYN/ACK. Windows below Cisco's 4128
+// are typical of small embedded stacks.
+func windowHints(window int) hints {
+	if h, ok := windowOSHints[window]; ok {
+		return h
+	}
+	if window > 0 && window < 4128 {
+		return hints{model.OSEmbedded: 0.3}
+	}
+	return nil
+}
+
+// tcpOptionHints weighs the options of a SYN/ACK.
+func tcpOptionHints(t TCPTraits) hints {
+	switch {
+	case t.Timestamps && t.SACK && t.WindowScaling:
+		return hints{model.OSLinux: 0.4}
+	case t.SACK && t.WindowScaling:
+		// Windows leaves timestamps off by default.
+		return hints{model.OSWindows: 0.4}
+	case !t.SACK && !t.WindowScaling && !t.Timestamps:
+		return hints{model.OSEmbedded: 0.4, model.OSNetwork: 0.3}
+	}
+	return nil
+}
+
+func (t TCPTraits) options() string {
+	var opts []string
+	if t.WindowScaling {
+		opts = append(opts, "wscale")
+	}
+	if t.SACK {
+		opts = append(opts, "sack")
+	}
+	if t.Timestamps {
+		opts = append(opts, "timestamps")
+	}
+	if len(opts) == 0 {
+		return "none"
+	}
+	return strings.Join(opts, ",")
+}
+
+// guessOS infers the OS family from a host's stack traits. It returns nil if
+// the evidence is too weak.
+func guessOS(t StackTraits) *model.OSGuess {
+	sc := scores{}
+	var evidence []string
+	if initial := initialTTL(t.TTL); initial > 0 {
+		sc.add(ttlOSHints[initial])
+		evidence = append(evidence, fmt.Sprintf("ttl %d (initial %d)", t.TTL, initial))
+	}
+	if t.TCP != nil {
+		sc.add(tcpOptionHints(*t.TCP))
+		sc.add(windowHints(t.TCP.Window))
+		if t.TCP.Window > 0 {
+			evidence = append(evidence, fmt.Sprintf("tcp window %d", t.TCP.Window))
+		}
+		evidence = append(evidence, "tcp options "+t.TCP.options())
+	}
+	family, confidence := sc.best(model.OSFamilies)
+	if family == "" {
+		return nil
+	}
+	return &model.OSGuess{Family: family, Confidence: confidence, Evidence: evidence, TTL: t.TTL}
+}
+
+// fingerprintDevices updates the OS guess of the given devices from what
+// the liveness and port probes observed. Devices without new observations
+// keep their previous guess.
+func (s *ScannerService) fingerprintDevices(ids []string) {
+	var seen []string
+	_, err := s.updateDevices(ids, func(d *model.Device) bool {
+		t, ok := s.prober.Stack(d.IPAddress)
+		if !ok {
+			return false
+		}
+		seen = append(seen, d.IPAddress)
+		guess := guessOS(t)
+		if guess == nil || reflect.DeepEqual(guess, d.OS) {
+			return false
+		}
+		d.OS = guess
+		return true
+	})
+	if err != nil {
+		s.logger.Error("Failed to save OS guesses: ", err)
+	}
+	s.forgetStacks(seen)
+}
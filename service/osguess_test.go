@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestGuessOS(t *testing.T) {
+	cases := []struct {
+		name   string
+		traits StackTraits
+		want   string
+	}{
+		{"linux", StackTraits{TTL: 61, TCP: &TCPTraits{Window: 65160, WindowScaling: true, SACK: true, Timestamps: true}}, model.OSLinux},
+		{"linux from ttl alone", StackTraits{TTL: 63}, model.OSLinux},
+		{"windows", StackTraits{TTL: 121, TCP: &TCPTraits{Window: 8192, WindowScaling: true, SACK: true}}, model.OSWindows},
+		{"cisco", StackTraits{TTL: 254, TCP: &TCPTraits{Window: 4128}}, model.OSNetwork},
+		{"embedded", StackTraits{TTL: 64, TCP: &TCPTraits{Window: 2920}}, model.OSEmbedded},
+		{"old embedded ttl", StackTraits{TTL: 30}, model.OSEmbedded},
+		{"nothing observed", StackTraits{}, ""},
+	}
+	for _, c := range cases {
+		got := guessOS(c.traits)
+		if c.want == "" {
+			if got != nil {
+				t.Errorf("%s: expected no guess, got %+v", c.name, got)
+			}
+			continue
+		}
+		if got == nil || got.Family != c.want {
+			t.Errorf("%s: expected %s, got %+v", c.name, c.want, got)
+			continue
+		}
+		if got.Confidence < minConfidence || got.Confidence > 1 || len(got.Evidence) == 0 {
+			t.Errorf("%s: unexpected confidence or evidence %+v", c.name, got)
+		}
+	}
+
+	g := guessOS(StackTraits{TTL: 121, TCP: &TCPTraits{Window: 8192, WindowScaling: true, SACK: true}})
+	want := []string{"ttl 121 (initial 128)", "tcp window 8192", "tcp options wscale,sack"}
+	if !slices.Equal(g.Evidence, want) || g.TTL != 121 {
+		t.Errorf("expected evidence %v, got %+v", want, g)
+	}
+}
+
+func TestInitialTTL(t *testing.T) {
+	for ttl, want := range map[int]int{0: 0, 1: 32, 32: 32, 33: 64, 64: 64, 100: 128, 128: 128, 200: 255, 255: 255} {
+		if got := initialTTL(ttl); got != want {
+			t.Errorf("initialTTL(%d): expected %d, got %d", ttl, want, got)
+		}
+	}
+}
+
+func TestObserveTCP_Loopback(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("TCP_INFO is only read on Linux")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{})
+	svc.observeTTL("127.0.0.1", 64)
+	svc.observeTCP("127.0.0.1", conn)
+	st, ok := svc.stack("127.0.0.1")
+	if !ok || st.TTL != 64 || st.TCP == nil {
+		t.Fatalf("expected TTL and TCP traits to be recorded, got %+v", st)
+	}
+	if !st.TCP.SACK || !st.TCP.WindowScaling {
+		t.Errorf("expected the local stack to offer SACK and window scaling, got %+v", *st.TCP)
+	}
+	if g := guessOS(st); g == nil || g.Family != model.OSLinux {
+		t.Errorf("expected the local stack to look like linux, got %+v", g)
+	}
+}
+
+func TestFingerprintDevices_AfterScan(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	net := NewFakeNetwork(1,
+		FakeHost{IP: "10.0.0.1", MAC: "aa:00:00:00:00:01", Stack: &StackTraits{TTL: 255, TCP: &TCPTraits{Window: 4128}}},
+		FakeHost{IP: "10.0.0.2", MAC: "aa:00:00:00:00:02"},
+	)
+	svc := NewScannerService(repo, &dummyLogger{}).WithConfig(fastPolling()).WithProber(net)
+
+	job, _ := svc.StartScan("10.0.0.0/30")
+	waitForScan(t, svc, job.ID)
+	d := repo.FindByIP("10.0.0.1")
+	if d == nil || d.OS == nil || d.OS.Family != model.OSNetwork || d.OS.TTL != 255 {
+		t.Fatalf("expected a network OS guess, got %+v", d)
+	}
+	// The TTL feeds device classification.
+	if d.DeviceType != model.TypeRouter && d.DeviceType != model.TypeSwitch {
+		t.Errorf("expected a network device, got %q", d.DeviceType)
+	}
+	history, _ := svc.DeviceHistory(d.ID, repository.EventFilter{Types: []string{model.EventOSChanged}})
+	if len(history) != 1 || history[0].NewValue != model.OSNetwork {
+		t.Errorf("expected an os_changed event, got %+v", history)
+	}
+	if other := repo.FindByIP("10.0.0.2"); other == nil || other.OS != nil {
+		t.Errorf("expected no guess without observations, got %+v", other)
+	}
+
+	// Without new observations the previous guess is kept.
+	net.AddHost(FakeHost{IP: "10.0.0.1", MAC: "aa:00:00:00:00:01"})
+	job, _ = svc.StartScan("10.0.0.1")
+	waitForScan(t, svc, job.ID)
+	if d := repo.FindByIP("10.0.0.1"); d.OS == nil || d.OS.Family != model.OSNetwork {
+		t.Errorf("expected the guess to survive a rescan, got %+v", d.OS)
+	}
+}
+
+func TestStacks_Bounded(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	svc := NewScannerService(repo, &dummyLogger{})
+	for i := range maxStacks + 10 {
+		svc.observeTTL(fmt.Sprintf("10.%d.%d.1", i/256, i%256), 64)
+	}
+	if n := len(svc.stacks); n != maxStacks {
+		t.Errorf("expected the traits of at most %d hosts, got %d", maxStacks, n)
+	}
+
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1"})
+	svc.observeTTL("10.0.0.1", 64)
+	svc.fingerprintDevices([]string{"d1"})
+	if d, _ := repo.FindByID("d1"); d.OS == nil || d.OS.Family != model.OSLinux {
+		t.Fatalf("expected a linux guess, got %+v", d.OS)
+	}
+	if _, ok := svc.stack("10.0.0.1"); ok {
+		t.Error("expected the traits to be dropped after the guess")
+	}
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"math/rand/v2"
+	"network-scanner/model"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pollEntry is the scheduling state of one device. dev is the last known
+// stored version, used to detect what a poll actually changed.
+type pollEntry struct {
+	dev     model.Device
+	next    time.Time
+	backoff time.Duration
+	// groupInterval is the shortest GroupIntervals entry of the groups the
+	// device belongs to, or zero.
+	groupInterval time.Duration
+	// written is the last-seen time last persisted for the device.
+	written time.Time
+}
+
+// poller polls each device on its own schedule. The device list is reloaded
+// once per base interval; between reloads only due devices are probed, and
+// only devices whose state changed are written back, in one batch per tick.
+// Devices that answered ICMP also get a latency sample, taken in the
+// background.
+type poller struct {
+	s          *ScannerService
+	entries    map[string]*pollEntry
+	nextReload time.Time
+	// sampling is set while a latency round runs in the background, so slow
+	// echo rounds neither hold up ticks nor pile up.
+	sampling atomic.Bool
+	samplers sync.WaitGroup
+}
+
+// StartStatusPolling starts the status scheduler configured by
+// ScannerConfig.Polling, replacing any scheduler already running.
+func (s *ScannerService) StartStatusPolling() {
+	s.StopStatusPolling()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.pollCancel = cancel
+	s.pollWG.Add(1)
+	go func() {
+		defer s.pollWG.Done()
+		p := &poller{s: s, entries: make(map[string]*pollEntry)}
+		defer p.samplers.Wait()
+		t := time.NewTicker(s.cfg.Polling.Tick)
+		defer t.Stop()
+		for {
+			p.tick(ctx, time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+func (s *ScannerService) StopStatusPolling() {
+	if s.pollCancel != nil {
+		s.pollCancel()
+		s.pollWG.Wait()
+		s.pollCancel = nil
+	}
+}
+
+func (p *poller) tick(ctx context.Context, now time.Time) {
+	if !now.Before(p.nextReload) {
+		p.reload(now)
+	}
+
+	var due []*pollEntry
+	var ips []string
+	for _, e := range p.entries {
+		if e.dev.IPAddress != "" && !now.Before(e.next) {
+			due = append(due, e)
+			ips = append(ips, e.dev.IPAddress)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	reach, err := p.s.checkLiveness(ctx, ips)
+	if err != nil {
+		if ctx.Err() == nil {
+			p.s.logger.Error("Status polling liveness probes failed: ", err)
+		}
+		return
+	}
+
+	now = time.Now()
+	var changed []*pollEntry
+	var updated, sampled []model.Device
+	for _, e := range due {
+		d := e.dev
+		p.s.observe(&d, reach[d.IPAddress], now)
+		if reach[d.IPAddress] == LivenessICMP {
+			sampled = append(sampled, d)
+		}
+		e.next = now.Add(p.jitter(p.interval(e, d, now)))
+		if p.needsWrite(e, d) {
+			changed = append(changed, e)
+			updated = append(updated, d)
+		}
+	}
+	p.write(changed, updated, now)
+	p.sample(ctx, sampled, now)
+}
+
+// sample takes latency samples of devices in the background. Devices due
+// while the previous round is still running are skipped until their next
+// poll.
+func (p *poller) sample(ctx context.Context, devices []model.Device, now time.Time) {
+	if len(devices) == 0 || !p.sampling.CompareAndSwap(false, true) {
+		return
+	}
+	p.samplers.Add(1)
+	go func() {
+		defer p.samplers.Done()
+		defer p.sampling.Store(false)
+		p.s.sampleLatency(ctx, devices, now)
+	}()
+}
+
+// reload syncs the schedule with the repository. New devices get a random
+// first poll within one interval so a freshly scanned range is spread out.
+func (p *poller) reload(now time.Time) {
+	cfg := p.s.cfg.Polling
+	p.nextReload = now.Add(cfg.Interval)
+	p.s.pruneMetrics(now)
+
+	included, intervals := p.groupMembership()
+	seen := make(map[string]struct{})
+	for _, d := range p.s.repo.GetAll() {
+		if included != nil {
+			if _, ok := included[d.ID]; !ok {
+				continue
+			}
+		}
+		seen[d.ID] = struct{}{}
+		if e, ok := p.entries[d.ID]; ok {
+			e.dev = d
+			e.groupInterval = intervals[d.ID]
+			continue
+		}
+		p.entries[d.ID] = &pollEntry{
+			dev:           d,
+			next:          now.Add(time.Duration(rand.Int64N(int64(cfg.Interval)))),
+			written:       d.LastSeen,
+			groupInterval: intervals[d.ID],
+		}
+	}
+	for id := range p.entries {
+		if _, ok := seen[id]; !ok {
+			delete(p.entries, id)
+		}
+	}
+}
+
+// groupMembership resolves the groups named in the polling config. included
+// is nil when polling is not limited to groups; intervals holds the shortest
+// group interval per device ID.
+func (p *poller) groupMembership() (included map[string]struct{}, intervals map[string]time.Duration) {
+	cfg := p.s.cfg.Polling
+	members := func(ref string) []model.Device {
+		g, err := p.s.findGroup(ref)
+		if err != nil {
+			p.s.logger.Warn("Polling group ", ref, ": ", err)
+			return nil
+		}
+		devices, err := p.s.groupMembers(*g)
+		if err != nil {
+			p.s.logger.Warn("Polling group ", ref, ": ", err)
+		}
+		return devices
+	}
+
+	if len(cfg.Groups) > 0 {
+		included = make(map[string]struct{})
+		for _, ref := range cfg.Groups {
+			for _, d := range members(ref) {
+				included[d.ID] = struct{}{}
+			}
+		}
+	}
+	intervals = make(map[string]time.Duration)
+	for ref, v := range cfg.GroupIntervals {
+		for _, d := range members(ref) {
+			if cur, ok := intervals[d.ID]; !ok || v < cur {
+				intervals[d.ID] = v
+			}
+		}
+	}
+	return included, intervals
+}
+
+// interval picks the next poll interval for a device: a device override,
+// else the shortest matching group interval, else the shortest matching tag
+// interval, else the default, doubled for every poll a long-offline device
+// stays unreachable.
+func (p *poller) interval(e *pollEntry, d model.Device, now time.Time) time.Duration {
+	cfg := p.s.cfg.Polling
+	base := cfg.Interval
+	if v, ok := cfg.DeviceIntervals[d.ID]; ok {
+		base = v
+	} else if v, ok := cfg.DeviceIntervals[d.IPAddress]; ok {
+		base = v
+	} else if e.groupInterval > 0 {
+		base = e.groupInterval
+	} else {
+		tagged := false
+		for _, tag := range d.Tags {
+			if v, ok := cfg.TagIntervals[tag]; ok && (!tagged || v < base) {
+				base, tagged = v, true
+			}
+		}
+	}
+
+	if d.Status != "offline" || (!d.LastSeen.IsZero() && now.Sub(d.LastSeen) < cfg.BackoffAfter) {
+		e.backoff = 0
+		return base
+	}
+	if e.backoff == 0 {
+		e.backoff = base
+	} else {
+		e.backoff = min(e.backoff*2, cfg.MaxInterval)
+	}
+	return max(e.backoff, base)
+}
+
+func (p *poller) jitter(d time.Duration) time.Duration {
+	j := p.s.cfg.Polling.Jitter
+	if j <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + j*(2*rand.Float64()-1)))
+}
+
+// needsWrite reports whether a poll changed anything worth persisting. A
+// device that simply stays online only has its last-seen time written once
+// it has moved by LastSeenPrecision.
+func (p *poller) needsWrite(e *pollEntry, d model.Device) bool {
+	old := e.dev
+	if old.Status != d.Status || old.LivenessMethod != d.LivenessMethod ||
+		old.FlapCount != d.FlapCount || old.Flapping != d.Flapping || !old.FirstSeen.Equal(d.FirstSeen) {
+		return true
+	}
+	return d.LastSeen.Sub(e.written) >= p.s.cfg.Polling.LastSeenPrecision
+}
+
+// write persists the polled fields of changed devices in one batch. Each
+// device is re-read first so tags or names edited since the last reload
+// are not overwritten by the cached copy.
+func (p *poller) write(entries []*pollEntry, updated []model.Device, now time.Time) {
+	if len(entries) == 0 {
+		return
+	}
+	var batch []model.Device
+	var events []model.DeviceEvent
+	for i, e := range entries {
+		d := updated[i]
+		cur, err := p.s.repo.FindByID(d.ID)
+		if err != nil || cur == nil {
+			delete(p.entries, d.ID)
+			continue
+		}
+		old := *cur
+		cur.Status = d.Status
+		cur.LastSeen = d.LastSeen
+		cur.FirstSeen = d.FirstSeen
+		cur.LivenessMethod = d.LivenessMethod
+		cur.FlapCount = d.FlapCount
+		cur.Flapping = d.Flapping
+
+		batch = append(batch, *cur)
+		events = append(events, diffDevice(&old, *cur, now)...)
+		e.dev = *cur
+		e.written = cur.LastSeen
+	}
+	if err := p.s.repo.SaveBatch(batch); err != nil {
+		p.s.logger.Error("Failed to save polled devices: ", err)
+		return
+	}
+	p.s.appendEvents(events)
+
+	ids := make([]string, len(batch))
+	for i, d := range batch {
+		ids[i] = d.ID
+	}
+	p.s.applyTagRules(ids)
+}
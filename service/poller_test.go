@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"network-scanner/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+func fastPolling() ScannerConfig {
+	cfg := DefaultScannerConfig()
+	cfg.Polling.Interval = 10 * time.Millisecond
+	cfg.Polling.Tick = 2 * time.Millisecond
+	return cfg
+}
+
+// countingRepo records how many devices were written in batches.
+type countingRepo struct {
+	*fakeDeviceRepo
+	mu      sync.Mutex
+	batched int
+}
+
+func (r *countingRepo) SaveBatch(devices []model.Device) error {
+	r.mu.Lock()
+	r.batched += len(devices)
+	r.mu.Unlock()
+	return r.fakeDeviceRepo.SaveBatch(devices)
+}
+
+func TestPoller_OnlyWritesChanges(t *testing.T) {
+	repo := &countingRepo{fakeDeviceRepo: newFakeDeviceRepo()}
+	now := time.Now()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1", Status: "online", LivenessMethod: LivenessICMP, FirstSeen: now, LastSeen: now})
+	repo.Save(model.Device{ID: "d2", IPAddress: "10.0.0.2", Status: "online", LivenessMethod: LivenessICMP, FirstSeen: now, LastSeen: now})
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.1"}, FakeHost{IP: "10.0.0.2", Down: true})
+	svc := NewScannerService(repo, &dummyLogger{}).WithConfig(fastPolling()).WithProber(net)
+
+	p := &poller{s: svc, entries: make(map[string]*pollEntry)}
+	for i := 0; i < 5; i++ {
+		p.tick(context.Background(), time.Now().Add(time.Duration(i)*time.Second))
+	}
+
+	repo.mu.Lock()
+	batched := repo.batched
+	repo.mu.Unlock()
+	// d1 stays online and is never rewritten; d2 goes degraded, degraded
+	// (unchanged) and then offline.
+	if batched != 2 {
+		t.Errorf("expected 2 device writes, got %d", batched)
+	}
+	if d, _ := repo.FindByID("d2"); d.Status != "offline" {
+		t.Errorf("expected d2 offline, got %s", d.Status)
+	}
+}
+
+func TestPoller_Intervals(t *testing.T) {
+	cfg := fastPolling()
+	cfg.Polling.Interval = time.Minute
+	cfg.Polling.TagIntervals = map[string]time.Duration{"prod": 10 * time.Second, "lab": 5 * time.Minute}
+	cfg.Polling.DeviceIntervals = map[string]time.Duration{"10.0.0.9": time.Second}
+	cfg.Polling.BackoffAfter = time.Hour
+	cfg.Polling.MaxInterval = 4 * time.Minute
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithConfig(cfg)
+	p := &poller{s: svc}
+	now := time.Now()
+
+	cases := []struct {
+		name string
+		dev  model.Device
+		want time.Duration
+	}{
+		{"default", model.Device{Status: "online"}, time.Minute},
+		{"shortest tag", model.Device{Status: "online", Tags: []string{"lab", "prod"}}, 10 * time.Second},
+		{"longer tag", model.Device{Status: "online", Tags: []string{"lab"}}, 5 * time.Minute},
+		{"device override", model.Device{IPAddress: "10.0.0.9", Status: "online", Tags: []string{"prod"}}, time.Second},
+		{"recently offline", model.Device{Status: "offline", LastSeen: now.Add(-time.Minute)}, time.Minute},
+	}
+	for _, c := range cases {
+		if got := p.interval(&pollEntry{}, c.dev, now); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+
+	e := &pollEntry{}
+	never := model.Device{Status: "offline"}
+	var got []time.Duration
+	for i := 0; i < 4; i++ {
+		got = append(got, p.interval(e, never, now))
+	}
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected backoff %v, got %v", want, got)
+		}
+	}
+	if p.interval(e, model.Device{Status: "online"}, now) != time.Minute || e.backoff != 0 {
+		t.Errorf("expected backoff to reset once the device answers")
+	}
+}
+
+func TestPoller_Jitter(t *testing.T) {
+	cfg := fastPolling()
+	cfg.Polling.Jitter = 0.2
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithConfig(cfg)
+	p := &poller{s: svc}
+	for i := 0; i < 100; i++ {
+		if d := p.jitter(time.Minute); d < 48*time.Second || d > 72*time.Second {
+			t.Fatalf("jittered interval %v outside ±20%%", d)
+		}
+	}
+}
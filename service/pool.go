@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// runParallel calls fn for every item using at most workers goroutines. It
+// stops handing out items once ctx is done and returns when all started calls
+// have finished.
+func runParallel[T any](ctx context.Context, workers int, items []T, fn func(T)) {
+	work := make(chan T)
+	var wg sync.WaitGroup
+	for i := 0; i < min(workers, len(items)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range work {
+				fn(item)
+			}
+		}()
+	}
+
+feed:
+	for _, item := range items {
+		select {
+		case work <- item:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+}
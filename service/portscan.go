@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"network-scanner/model"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidPorts = errors.New("invalid port list")
+
+var portProfiles = map[string][]int{
+	"top20": {21, 22, 23, 25, 53, 80, 110, 111, 135, 139, 143, 443, 445, 993, 995, 1723, 3306, 3389, 5900, 8080},
+	"top100": {
+		7, 9, 13, 21, 22, 23, 25, 26, 37, 53, 79, 80, 81, 88, 106, 110, 111, 113, 119, 135,
+		139, 143, 144, 179, 199, 389, 427, 443, 444, 445, 465, 513, 514, 515, 543, 544, 548, 554, 587, 631,
+		646, 873, 990, 993, 995, 1025, 1026, 1027, 1028, 1029, 1110, 1433, 1720, 1723, 1755, 1900, 2000, 2001, 2049, 2121,
+		2717, 3000, 3128, 3306, 3389, 3986, 4899, 5000, 5009, 5051, 5060, 5101, 5190, 5357, 5432, 5631, 5666, 5800, 5900, 6000,
+		6001, 6646, 7070, 8000, 8008, 8009, 8080, 8081, 8443, 8888, 9100, 9999, 10000, 32768, 49152, 49153, 49154, 49155, 49156, 49157,
+	},
+}
+
+// ParsePorts expands a port spec into a sorted, de-duplicated port list. A
+// spec is either a profile name ("top20", "top100") or a comma separated list
+// of ports and ranges ("22,80,8000-8010").
+func ParsePorts(spec string) ([]int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	if ports, ok := portProfiles[strings.ToLower(spec)]; ok {
+		return slices.Clone(ports), nil
+	}
+
+	var out []int
+	for _, tok := range strings.Split(spec, ",") {
+		tok = strings.TrimSpace(tok)
+		from, to, isRange := strings.Cut(tok, "-")
+		first, err := strconv.ParseUint(strings.TrimSpace(from), 10, 16)
+		if err != nil || first == 0 {
+			return nil, fmt.Errorf("%w: bad port %q", ErrInvalidPorts, tok)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.ParseUint(strings.TrimSpace(to), 10, 16)
+			if err != nil || last < first {
+				return nil, fmt.Errorf("%w: bad port range %q", ErrInvalidPorts, tok)
+			}
+		}
+		for p := first; p <= last; p++ {
+			out = append(out, int(p))
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+// scanPorts tries a TCP connect to every port of ip and returns the open ones.
+// Each attempt holds one slot of the global probe semaphore.
+func (s *ScannerService) scanPorts(ctx context.Context, ip string, ports []int) []int {
+	cfg := s.cfg.PortScan
+	dialer := net.Dialer{Timeout: cfg.Timeout}
+
+	var (
+		mu   sync.Mutex
+		open []int
+	)
+	runParallel(ctx, cfg.Concurrency, ports, func(port int) {
+		if err := s.probes.Acquire(ctx, 1); err != nil {
+			return
+		}
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+		s.probes.Release(1)
+		if err != nil {
+			return
+		}
+		s.observeTCP(ip, conn)
+		conn.Close()
+		mu.Lock()
+		open = append(open, port)
+		mu.Unlock()
+	})
+
+	slices.Sort(open)
+	return open
+}
+
+// mergePorts folds the result of a port scan into a device's known ports.
+// Scanned ports that are no longer open are kept as closed so the inventory
+// remembers when they were last seen.
+func mergePorts(known []model.Port, scanned, open []int, now time.Time) []model.Port {
+	byPort := make(map[int]model.Port, len(known))
+	for _, p := range known {
+		if p.Protocol == "tcp" {
+			byPort[p.Port] = p
+		}
+	}
+
+	var out []model.Port
+	for _, port := range scanned {
+		p, seen := byPort[port]
+		if slices.Contains(open, port) {
+			if !seen {
+				p = model.Port{Port: port, Protocol: "tcp", FirstSeen: now}
+			}
+			p.State = model.PortOpen
+			p.LastSeen = now
+			out = append(out, p)
+		} else if seen && p.State != model.PortClosed {
+			p.State = model.PortClosed
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (s *ScannerService) scanDevicePorts(ctx context.Context, d model.Device, ports []int) {
+	open := s.scanPorts(ctx, d.IPAddress, ports)
+	if ctx.Err() != nil {
+		return
+	}
+	known, err := s.ports.FindByDevice(d.ID)
+	if err != nil {
+		s.logger.Error("Failed to load ports for ", d.IPAddress, ": ", err)
+		return
+	}
+	changed := mergePorts(known, ports, open, time.Now())
+	if len(changed) == 0 {
+		return
+	}
+	if s.cfg.PortScan.Banners.Enabled {
+		s.detectServices(ctx, d.IPAddress, changed)
+	}
+	if err := s.ports.SavePorts(d.ID, changed); err != nil {
+		s.logger.Error("Failed to save ports for ", d.IPAddress, ": ", err)
+	}
+}
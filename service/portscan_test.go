@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"network-scanner/model"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listenLocal runs handle for every connection to a listener on 127.0.0.1
+// and returns its port and a function that closes it early. A nil handle
+// closes connections as soon as they are accepted.
+func listenLocal(t *testing.T, handle func(net.Conn)) (int, func()) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if handle == nil {
+				conn.Close()
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, func() { ln.Close() }
+}
+
+func TestParsePorts(t *testing.T) {
+	got, err := ParsePorts("443, 22,80,8000-8002,22")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := []int{22, 80, 443, 8000, 8001, 8002}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	top, err := ParsePorts("TOP20")
+	if err != nil || len(top) != 20 {
+		t.Errorf("expected 20 ports for top20 profile, got %d (err %v)", len(top), err)
+	}
+	if top, _ := ParsePorts("top100"); len(top) != 100 {
+		t.Errorf("expected 100 ports for top100 profile, got %d", len(top))
+	}
+
+	for _, bad := range []string{"0", "70000", "80-20", "ssh", "22,"} {
+		if _, err := ParsePorts(bad); !errors.Is(err, ErrInvalidPorts) {
+			t.Errorf("%q: expected ErrInvalidPorts, got %v", bad, err)
+		}
+	}
+}
+
+func TestScanPorts_Localhost(t *testing.T) {
+	openPort, closeLn := listenLocal(t, nil)
+	defer closeLn()
+	closedPort, closeOther := listenLocal(t, nil)
+	closeOther()
+
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{})
+	open := svc.scanPorts(context.Background(), "127.0.0.1", []int{openPort, closedPort})
+	if !reflect.DeepEqual(open, []int{openPort}) {
+		t.Errorf("expected only %d open, got %v", openPort, open)
+	}
+}
+
+func TestMergePorts(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+	known := []model.Port{
+		{Port: 22, Protocol: "tcp", State: model.PortOpen, FirstSeen: t0, LastSeen: t0},
+		{Port: 80, Protocol: "tcp", State: model.PortOpen, FirstSeen: t0, LastSeen: t0},
+	}
+
+	got := mergePorts(known, []int{22, 80, 443}, []int{22, 443}, t1)
+	want := []model.Port{
+		{Port: 22, Protocol: "tcp", State: model.PortOpen, FirstSeen: t0, LastSeen: t1},
+		{Port: 80, Protocol: "tcp", State: model.PortClosed, FirstSeen: t0, LastSeen: t0},
+		{Port: 443, Protocol: "tcp", State: model.PortOpen, FirstSeen: t1, LastSeen: t1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestScanJob_WithPorts(t *testing.T) {
+	port, closeLn := listenLocal(t, nil)
+	defer closeLn()
+
+	repo := newFakeDeviceRepo()
+	svc := NewScannerService(repo, &dummyLogger{})
+	job, err := svc.StartScanWithOptions(ScanOptions{Range: "127.0.0.1", Ports: strconv.Itoa(port)})
+	if err != nil {
+		t.Fatalf("StartScanWithOptions error: %v", err)
+	}
+	if final := waitForScan(t, svc, job.ID); final.Status != model.ScanCompleted {
+		t.Skipf("scan did not complete (%s: %s); ICMP may be unavailable", final.Status, final.Error)
+	}
+
+	devices := repo.GetAll()
+	if len(devices) != 1 {
+		t.Fatalf("expected 1 device, got %d", len(devices))
+	}
+	if devices[0].Status != "online" {
+		t.Skip("127.0.0.1 did not answer ping; port scan not attempted")
+	}
+	dev, _ := svc.FindByID(devices[0].ID)
+	if len(dev.Ports) != 1 || dev.Ports[0].Port != port || dev.Ports[0].State != model.PortOpen {
+		t.Errorf("expected open port %d on device, got %+v", port, dev.Ports)
+	}
+}
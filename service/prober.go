@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"network-scanner/model"
+	"sync"
+	"time"
+)
+
+// LivenessFunc reports which of ips answered a probe. Hosts that did not
+// answer may be absent from the result.
+type LivenessFunc func(ctx context.Context, ips []string) (map[string]bool, error)
+
+// Prober is how ScannerService reaches the network: liveness checks, MAC and
+// hostname lookups. The default implementation uses ICMP, TCP, ARP/NDP and
+// reverse DNS; tests can swap in a FakeNetwork.
+type Prober interface {
+	// Liveness returns the probe for a liveness method such as "icmp", or
+	// nil if the method is not supported.
+	Liveness(method string) LivenessFunc
+	MAC(ctx context.Context, ip string) string
+	Hostname(ctx context.Context, ip string) string
+	// Echo sends count ICMP echo requests to every address and returns the
+	// round-trip times of the replies received per address.
+	Echo(ctx context.Context, ips []string, count int) (map[string][]time.Duration, error)
+}
+
+// Enricher adds extra attributes to an online device once its MAC and
+// hostname are known, before it is saved.
+type Enricher interface {
+	Name() string
+	Enrich(ctx context.Context, d *model.Device) error
+}
+
+// ProbeRegistry layers named liveness probes and enrichers on top of a base
+// Prober. Registered liveness methods take precedence over the base ones, so
+// a method can be overridden as well as added.
+type ProbeRegistry struct {
+	base Prober
+
+	mu        sync.RWMutex
+	liveness  map[string]LivenessFunc
+	enrichers []Enricher
+}
+
+func NewProbeRegistry(base Prober) *ProbeRegistry {
+	return &ProbeRegistry{base: base, liveness: make(map[string]LivenessFunc)}
+}
+
+func (r *ProbeRegistry) RegisterLiveness(method string, fn LivenessFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.liveness[method] = fn
+}
+
+func (r *ProbeRegistry) RegisterEnricher(e Enricher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enrichers = append(r.enrichers, e)
+}
+
+func (r *ProbeRegistry) Liveness(method string) LivenessFunc {
+	r.mu.RLock()
+	fn, ok := r.liveness[method]
+	r.mu.RUnlock()
+	if ok {
+		return fn
+	}
+	return r.base.Liveness(method)
+}
+
+func (r *ProbeRegistry) MAC(ctx context.Context, ip string) string {
+	return r.base.MAC(ctx, ip)
+}
+
+func (r *ProbeRegistry) Hostname(ctx context.Context, ip string) string {
+	return r.base.Hostname(ctx, ip)
+}
+
+func (r *ProbeRegistry) Echo(ctx context.Context, ips []string, count int) (map[string][]time.Duration, error) {
+	return r.base.Echo(ctx, ips, count)
+}
+
+// Stack forwards to the base Prober if it is a StackProber.
+func (r *ProbeRegistry) Stack(ip string) (StackTraits, bool) {
+	if sp, ok := r.base.(StackProber); ok {
+		return sp.Stack(ip)
+	}
+	return StackTraits{}, false
+}
+
+func (r *ProbeRegistry) Enrichers() []Enricher {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Enricher(nil), r.enrichers...)
+}
+
+// WithProber replaces the network backend. Liveness probes and enrichers
+// registered on the previous registry are dropped.
+func (s *ScannerService) WithProber(p Prober) *ScannerService {
+	s.prober = NewProbeRegistry(p)
+	s.checkLivenessMethods()
+	return s
+}
+
+// Probes returns the registry used by the service so callers can register
+// additional liveness methods and enrichers.
+func (s *ScannerService) Probes() *ProbeRegistry {
+	return s.prober
+}
+
+func (s *ScannerService) checkLivenessMethods() {
+	for _, m := range s.cfg.Liveness.Methods {
+		if s.prober.Liveness(m) == nil {
+			s.logger.Warn("Unknown liveness method: ", m)
+		}
+	}
+}
+
+// enrich runs every registered enricher on an online device. A failing
+// enricher is logged and does not stop the others.
+func (s *ScannerService) enrich(ctx context.Context, d *model.Device) {
+	for _, e := range s.prober.Enrichers() {
+		if err := e.Enrich(ctx, d); err != nil {
+			s.logger.Warn("Enricher ", e.Name(), " failed for ", d.IPAddress, ": ", err)
+		}
+	}
+}
+
+// netProber is the default Prober backed by the real network. Which
+// mechanism it uses depends on the service's privilege mode.
+type netProber struct {
+	s *ScannerService
+}
+
+func (p netProber) Liveness(method string) LivenessFunc {
+	s := p.s
+	switch method {
+	case LivenessICMP:
+		return s.pingBatched
+	case LivenessTCP:
+		return s.tcpAlive
+	case LivenessARP:
+		if s.mode == ModeUnprivileged {
+			return s.neighbourAlive
+		}
+		return s.arpAlive
+	}
+	return nil
+}
+
+func (p netProber) MAC(ctx context.Context, ip string) string {
+	return p.s.lookupMAC(ip)
+}
+
+func (p netProber) Hostname(ctx context.Context, ip string) string {
+	return resolveHostname(ctx, ip)
+}
+
+func (p netProber) Stack(ip string) (StackTraits, bool) {
+	return p.s.stack(ip)
+}
+
+// Echo pings every address count times, one round after the other, so the
+// replies of a round are not skewed by the next round's requests.
+func (p netProber) Echo(ctx context.Context, ips []string, count int) (map[string][]time.Duration, error) {
+	out := make(map[string][]time.Duration)
+	for range count {
+		rtts, err := p.s.pingBatchedRTT(ctx, ips)
+		if err != nil {
+			return nil, err
+		}
+		for ip, rtt := range rtts {
+			out[ip] = append(out[ip], rtt)
+		}
+	}
+	return out, nil
+}
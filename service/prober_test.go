@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"network-scanner/model"
+	"testing"
+	"time"
+)
+
+type vendorEnricher struct{}
+
+func (vendorEnricher) Name() string { return "vendor" }
+
+func (vendorEnricher) Enrich(ctx context.Context, d *model.Device) error {
+	d.Manufacturer = "Acme"
+	return nil
+}
+
+type failingEnricher struct{}
+
+func (failingEnricher) Name() string { return "failing" }
+
+func (failingEnricher) Enrich(ctx context.Context, d *model.Device) error {
+	return errors.New("boom")
+}
+
+func TestFakeNetwork_LatencyAndLoss(t *testing.T) {
+	net := NewFakeNetwork(1,
+		FakeHost{IP: "10.0.0.1", Latency: 20 * time.Millisecond},
+		FakeHost{IP: "10.0.0.2", Latency: 2 * time.Second},
+		FakeHost{IP: "10.0.0.3", Loss: 1},
+		FakeHost{IP: "10.0.0.4", Down: true},
+	).WithTimeout(100 * time.Millisecond)
+
+	start := time.Now()
+	got, err := net.Liveness(LivenessICMP)(context.Background(), []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"})
+	if err != nil {
+		t.Fatalf("probe error: %v", err)
+	}
+	if len(got) != 1 || !got["10.0.0.1"] {
+		t.Errorf("expected only 10.0.0.1 to answer, got %v", got)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected probe to wait for host latency, took %v", elapsed)
+	}
+}
+
+func TestFakeNetwork_PartialLossIsSeeded(t *testing.T) {
+	run := func() map[string]bool {
+		net := NewFakeNetwork(42)
+		var ips []string
+		for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"} {
+			net.AddHost(FakeHost{IP: ip, Loss: 0.5})
+			ips = append(ips, ip)
+		}
+		got, _ := net.Liveness(LivenessICMP)(context.Background(), ips)
+		return got
+	}
+	a, b := run(), run()
+	if len(a) == 0 || len(a) == 6 {
+		t.Errorf("expected some but not all hosts to answer at 50%% loss, got %v", a)
+	}
+	for ip := range a {
+		if !b[ip] {
+			t.Errorf("expected identical results for the same seed, got %v and %v", a, b)
+			break
+		}
+	}
+}
+
+func TestProbeRegistry_CustomLivenessAndEnrichers(t *testing.T) {
+	cfg := DefaultScannerConfig()
+	cfg.Liveness.Methods = []string{LivenessICMP, "snmp"}
+	repo := newFakeDeviceRepo()
+	svc := NewScannerService(repo, &dummyLogger{}).
+		WithConfig(cfg).
+		WithProber(NewFakeNetwork(1, FakeHost{IP: "10.0.0.1", Down: true}))
+
+	svc.Probes().RegisterLiveness("snmp", func(ctx context.Context, ips []string) (map[string]bool, error) {
+		return map[string]bool{"10.0.0.1": true}, nil
+	})
+	svc.Probes().RegisterEnricher(failingEnricher{})
+	svc.Probes().RegisterEnricher(vendorEnricher{})
+
+	job, err := svc.StartScan("10.0.0.1")
+	if err != nil {
+		t.Fatalf("StartScan error: %v", err)
+	}
+	waitForScan(t, svc, job.ID)
+
+	d := repo.FindByIP("10.0.0.1")
+	if d == nil || d.Status != "online" || d.LivenessMethod != "snmp" {
+		t.Fatalf("expected device proven online by snmp, got %+v", d)
+	}
+	if d.Manufacturer != "Acme" {
+		t.Errorf("expected enricher to set manufacturer, got %q", d.Manufacturer)
+	}
+}
+
+func TestStatusPolling_FakeNetwork(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.1"})
+	svc := NewScannerService(repo, &dummyLogger{}).WithConfig(fastPolling()).WithProber(net)
+	repo.Save(model.Device{ID: "dev-1", IPAddress: "10.0.0.1", Status: "online"})
+
+	net.SetDown("10.0.0.1", true)
+	svc.StartStatusPolling()
+	defer svc.StopStatusPolling()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if d := repo.FindByIP("10.0.0.1"); d != nil && d.Status == "offline" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("expected polling to mark the device offline")
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"fmt"
+	"net/netip"
+	"network-scanner/repository"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// QuerySyntaxError is a malformed search query. Pos is the 1-based character
+// position of the offending token.
+type QuerySyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// queryTextFields maps the field names accepted in a query, including short
+// aliases, onto repository text fields.
+var queryTextFields = map[string]string{
+	"id":           repository.FieldID,
+	"host":         repository.FieldHostname,
+	"hostname":     repository.FieldHostname,
+	"mac":          repository.FieldMAC,
+	"mac_address":  repository.FieldMAC,
+	"manufacturer": repository.FieldManufacturer,
+	"vendor":       repository.FieldManufacturer,
+	"status":       repository.FieldStatus,
+	"type":         repository.FieldType,
+	"tag":          repository.FieldTag,
+	"tags":         repository.FieldTag,
+}
+
+// ParseQuery compiles a device search expression such as
+//
+//	tag:prod AND status:offline AND NOT manufacturer:"Cisco*" AND ip:10.0.0.0/16 AND last_seen<7d
+//
+// Terms are field:value pairs or bare words, which match the address,
+// hostname, MAC, manufacturer or any tag. Text values are case-insensitive
+// and may use "*" as a wildcard; bare words match anywhere in a value. ip
+// takes an address, a CIDR prefix or a wildcard pattern. first_seen and
+// last_seen are compared with <, <=, > or >= against an age such as 7d, 12h
+// or 2w, or against an RFC3339 time or a date. Terms are combined with AND,
+// OR, NOT and parentheses; adjacent terms are ANDed. An empty query returns
+// nil, which matches every device.
+func ParseQuery(q string, now time.Time) (repository.Expr, error) {
+	toks, err := lexQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{toks: toks, now: now}
+	if p.peek().kind == tokEOF {
+		return nil, nil
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &QuerySyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return e, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	// end is the position just after the token.
+	end int
+}
+
+func lexQuery(q string) ([]token, error) {
+	rs := []rune(q)
+	var toks []token
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			kind := tokLParen
+			if r == ')' {
+				kind = tokRParen
+			}
+			toks = append(toks, token{kind: kind, text: string(r), pos: i + 1, end: i + 2})
+			i++
+		case r == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			for ; i < len(rs) && rs[i] != '"'; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				sb.WriteRune(rs[i])
+			}
+			if i == len(rs) {
+				return nil, &QuerySyntaxError{Pos: start + 1, Msg: "unterminated quoted string"}
+			}
+			i++
+			toks = append(toks, token{kind: tokString, text: sb.String(), pos: start + 1, end: i + 1})
+		default:
+			start := i
+			for i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '(' && rs[i] != ')' && rs[i] != '"' {
+				i++
+			}
+			toks = append(toks, token{kind: tokWord, text: string(rs[start:i]), pos: start + 1, end: i + 1})
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(rs) + 1, end: len(rs) + 1}), nil
+}
+
+type queryParser struct {
+	toks []token
+	i    int
+	now  time.Time
+}
+
+func (p *queryParser) peek() token { return p.toks[p.i] }
+
+func (p *queryParser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (t token) keyword(kw string) bool { return t.kind == tokWord && t.text == kw }
+
+func (p *queryParser) parseOr() (repository.Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = repository.OrExpr{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (repository.Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.keyword("AND"):
+			p.next()
+		case t.kind == tokEOF || t.kind == tokRParen || t.keyword("OR"):
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = repository.AndExpr{Left: left, Right: right}
+	}
+}
+
+func (p *queryParser) parseUnary() (repository.Expr, error) {
+	t := p.next()
+	switch {
+	case t.keyword("NOT"):
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return repository.NotExpr{X: x}, nil
+	case t.kind == tokLParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tokRParen {
+			return nil, &QuerySyntaxError{Pos: c.pos, Msg: fmt.Sprintf("missing ) for ( at position %d", t.pos)}
+		}
+		return e, nil
+	case t.kind == tokString:
+		return repository.TextExpr{Pattern: "*" + t.text + "*"}, nil
+	case t.kind == tokWord && !t.keyword("AND") && !t.keyword("OR"):
+		return p.parseTerm(t)
+	case t.kind == tokEOF:
+		return nil, &QuerySyntaxError{Pos: t.pos, Msg: "expected a search term"}
+	}
+	return nil, &QuerySyntaxError{Pos: t.pos, Msg: fmt.Sprintf("expected a search term, found %q", t.text)}
+}
+
+// parseTerm splits a word into field, operator and value. A value written as
+// a quoted string directly after the operator belongs to the term.
+func (p *queryParser) parseTerm(t token) (repository.Expr, error) {
+	i := strings.IndexAny(t.text, ":<>=")
+	if i < 0 || looksLikeAddress(t.text[:i]) {
+		return repository.TextExpr{Pattern: "*" + t.text + "*"}, nil
+	}
+	field, rest := strings.ToLower(t.text[:i]), t.text[i:]
+	op := rest[:1]
+	if len(rest) > 1 && rest[1] == '=' && (op == "<" || op == ">") {
+		op = rest[:2]
+	}
+	value := rest[len(op):]
+	valuePos := t.pos + len([]rune(t.text[:i])) + len(op)
+	if value == "" {
+		if s := p.peek(); s.kind == tokString && s.pos == t.end {
+			p.next()
+			value = s.text
+		}
+	}
+	if field == "" {
+		return nil, &QuerySyntaxError{Pos: t.pos, Msg: "missing field name before " + op}
+	}
+	if value == "" {
+		return nil, &QuerySyntaxError{Pos: valuePos, Msg: "missing value for " + field}
+	}
+
+	if f, ok := queryTextFields[field]; ok {
+		if op != ":" && op != "=" {
+			return nil, &QuerySyntaxError{Pos: valuePos - len(op), Msg: fmt.Sprintf("%s only supports : and =", field)}
+		}
+		return repository.TextExpr{Field: f, Pattern: value}, nil
+	}
+	switch field {
+	case "ip", "ip_address":
+		if op != ":" && op != "=" {
+			return nil, &QuerySyntaxError{Pos: valuePos - len(op), Msg: "ip only supports : and ="}
+		}
+		return parseIPTerm(value, valuePos)
+	case "first_seen", "last_seen":
+		return p.parseTimeTerm(field, op, value, valuePos)
+	}
+	return nil, &QuerySyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unknown field %q", field)}
+}
+
+// looksLikeAddress reports whether the text before a colon is a fragment of a
+// MAC or IPv6 address rather than a field name, so fe80::1 or aa:bb:cc can be
+// searched without quotes.
+func looksLikeAddress(field string) bool {
+	if field == "" || len(field) > 4 {
+		return false
+	}
+	for _, r := range field {
+		if !unicode.Is(unicode.ASCII_Hex_Digit, r) {
+			return false
+		}
+	}
+	_, known := queryTextFields[strings.ToLower(field)]
+	return !known
+}
+
+func parseIPTerm(value string, pos int) (repository.Expr, error) {
+	if strings.Contains(value, "*") {
+		return repository.TextExpr{Field: repository.FieldIP, Pattern: value}, nil
+	}
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, &QuerySyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid CIDR %q", value)}
+		}
+		return repository.PrefixExpr{Prefix: prefix.Masked()}, nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return nil, &QuerySyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid IP address %q", value)}
+	}
+	return repository.PrefixExpr{Prefix: netip.PrefixFrom(addr, addr.BitLen())}, nil
+}
+
+// parseTimeTerm compares a timestamp with an absolute time or an age. For an
+// age the comparison is on how long ago the device was seen, so last_seen<7d
+// means seen within the last seven days.
+func (p *queryParser) parseTimeTerm(field, op, value string, pos int) (repository.Expr, error) {
+	if op == ":" || op == "=" {
+		return nil, &QuerySyntaxError{Pos: pos - len(op), Msg: fmt.Sprintf("%s needs <, <=, > or >=", field)}
+	}
+	if age, ok := parseAge(value); ok {
+		flipped := map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}
+		return repository.TimeExpr{Field: field, Op: flipped[op], T: p.now.Add(-age)}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return repository.TimeExpr{Field: field, Op: op, T: t}, nil
+		}
+	}
+	return nil, &QuerySyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid time %q, expected an age like 7d or an RFC3339 time", value)}
+}
+
+// parseAge accepts Go durations plus whole days ("d") and weeks ("w").
+func parseAge(s string) (time.Duration, bool) {
+	if n := len(s); n > 1 && (s[n-1] == 'd' || s[n-1] == 'w') {
+		v, err := strconv.Atoi(s[:n-1])
+		if err != nil || v < 0 {
+			return 0, false
+		}
+		unit := 24 * time.Hour
+		if s[n-1] == 'w' {
+			unit *= 7
+		}
+		return time.Duration(v) * unit, true
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil && d >= 0
+}
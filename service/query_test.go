@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseQuery_Matches(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	repo := repository.NewInMemoryRepository()
+	repo.Save(model.Device{ID: "core", IPAddress: "10.0.1.1", MACAddress: "00:1A:2B:3C:4D:5E", Hostname: "core-sw", Manufacturer: "Cisco Systems", Status: "offline", Tags: []string{"prod", "network"}, LastSeen: now.Add(-2 * 24 * time.Hour)})
+	repo.Save(model.Device{ID: "web", IPAddress: "10.0.2.7", Hostname: "web-1", Manufacturer: "Dell", Status: "offline", Tags: []string{"prod"}, LastSeen: now.Add(-time.Hour)})
+	repo.Save(model.Device{ID: "old", IPAddress: "10.0.3.9", Manufacturer: "Dell", Status: "offline", Tags: []string{"prod"}, LastSeen: now.Add(-30 * 24 * time.Hour)})
+	repo.Save(model.Device{ID: "lab", IPAddress: "192.168.5.5", Hostname: "alpha", Status: "online", Tags: []string{"lab"}, LastSeen: now})
+	repo.Save(model.Device{ID: "v6", IPAddress: "fe80::1", Status: "online", LastSeen: now})
+
+	cases := map[string][]string{
+		`tag:prod AND status:offline AND NOT manufacturer:"Cisco*" AND ip:10.0.0.0/16 AND last_seen<7d`: {"web"},
+		`tag:prod status:offline`:           {"core", "old", "web"},
+		`tag:lab OR hostname:core*`:         {"core", "lab"},
+		`NOT (tag:prod OR tag:lab)`:         {"v6"},
+		`last_seen>7d`:                      {"old"},
+		`last_seen>=2024-05-31T00:00:00Z`:   {"lab", "v6", "web"},
+		`ip:192.168.5.5`:                    {"lab"},
+		`ip:10.0.*.9`:                       {"old"},
+		`vendor:dell AND NOT hostname:web*`: {"old"},
+		`a`:                                 {"core", "lab"},
+		`fe80::1`:                           {"v6"},
+		`00:1a:2b`:                          {"core"},
+		`"systems"`:                         {"core"},
+		`mac:"00:1A:2B:3C:4D:5E"`:           {"core"},
+		`(tag:prod AND ip:10.0.1.0/24) OR status:on*`: {"core", "lab", "v6"},
+	}
+	for q, want := range cases {
+		expr, err := ParseQuery(q, now)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", q, err)
+			continue
+		}
+		page, _ := repo.List(repository.DeviceQuery{Match: expr})
+		var got []string
+		for _, d := range page.Devices {
+			got = append(got, d.ID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: expected %v, got %v", q, want, got)
+		}
+	}
+}
+
+func TestParseQuery_SyntaxErrors(t *testing.T) {
+	cases := map[string]int{
+		`tag:prod AND`:          13,
+		`(tag:prod OR status:x`: 22,
+		`colour:red`:            1,
+		`tag:prod )`:            10,
+		`manufacturer:"Cisco`:   14,
+		`ip:10.0.0.0/33`:        4,
+		`last_seen:7d`:          10,
+		`last_seen<yesterday`:   11,
+		`status<online`:         7,
+		`tag:`:                  5,
+		`OR tag:prod`:           1,
+		`NOT`:                   4,
+	}
+	for q, pos := range cases {
+		_, err := ParseQuery(q, time.Now())
+		var se *QuerySyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("%s: expected a syntax error, got %v", q, err)
+			continue
+		}
+		if se.Pos != pos {
+			t.Errorf("%s: expected error at position %d, got %d (%s)", q, pos, se.Pos, se.Msg)
+		}
+	}
+
+	if expr, err := ParseQuery("   ", time.Now()); expr != nil || err != nil {
+		t.Errorf("expected an empty query to match everything, got %v, %v", expr, err)
+	}
+}
@@ -1,7 +1,6 @@
 package service
 
 import (
-	"net"
 	"network-scanner/model"
 	"network-scanner/repository"
 )
@@ -15,7 +14,7 @@ func NewRangeService(repo repository.IPRangeRepository) *RangeService {
 }
 
 func (s *RangeService) Save(r model.IPRange) error {
-	if _, _, err := net.ParseCIDR(r.Range); err != nil {
+	if _, err := ParseTargets(r.Range, r.Exclude); err != nil {
 		return err
 	}
 	return s.repo.Save(r)
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidWindow = errors.New("invalid report window")
+
+// ReportService computes availability reports from the status history that
+// status polling and scans record as device events.
+type ReportService struct {
+	devices repository.DeviceRepository
+	events  repository.EventRepository
+}
+
+func NewReportService(devices repository.DeviceRepository, events repository.EventRepository) *ReportService {
+	return &ReportService{devices: devices, events: events}
+}
+
+// Availability reports per-device and per-tag uptime between from and to. If
+// tag is set only devices carrying it are included. Devices whose status was
+// never known inside the window are left out.
+func (s *ReportService) Availability(from, to time.Time, tag string) (model.AvailabilityReport, error) {
+	report := model.AvailabilityReport{
+		From:    from,
+		To:      to,
+		Devices: []model.DeviceAvailability{},
+		Tags:    []model.TagAvailability{},
+	}
+	if !from.Before(to) {
+		return report, ErrInvalidWindow
+	}
+
+	events, err := s.events.Find(repository.EventFilter{
+		Types: []string{model.EventDiscovered, model.EventStatusChanged},
+		To:    to,
+	})
+	if err != nil {
+		return report, err
+	}
+	byDevice := make(map[string][]model.DeviceEvent)
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		byDevice[e.DeviceID] = append(byDevice[e.DeviceID], e)
+	}
+
+	tags := make(map[string]*tally)
+	for _, d := range s.devices.GetAll() {
+		if tag != "" && !slices.Contains(d.Tags, tag) {
+			continue
+		}
+		t := deviceTally(d, byDevice[d.ID], from, to)
+		if t.monitored == 0 {
+			continue
+		}
+		report.Devices = append(report.Devices, model.DeviceAvailability{
+			DeviceID:          d.ID,
+			IPAddress:         d.IPAddress,
+			Hostname:          d.Hostname,
+			Tags:              d.Tags,
+			AvailabilityStats: t.stats(),
+		})
+		for _, name := range d.Tags {
+			if tag != "" && name != tag {
+				continue
+			}
+			agg, ok := tags[name]
+			if !ok {
+				agg = &tally{}
+				tags[name] = agg
+			}
+			agg.add(t)
+		}
+	}
+
+	slices.SortFunc(report.Devices, func(a, b model.DeviceAvailability) int {
+		return strings.Compare(a.IPAddress, b.IPAddress)
+	})
+	for name, t := range tags {
+		report.Tags = append(report.Tags, model.TagAvailability{Tag: name, Devices: t.devices, AvailabilityStats: t.stats()})
+	}
+	slices.SortFunc(report.Tags, func(a, b model.TagAvailability) int { return strings.Compare(a.Tag, b.Tag) })
+	return report, nil
+}
+
+// tally accumulates availability over one or more devices.
+type tally struct {
+	devices          int
+	monitored        time.Duration
+	up, down         time.Duration
+	outages          int
+	longest          time.Duration
+	resolved         int
+	resolvedDowntime time.Duration
+}
+
+func (t *tally) add(o tally) {
+	t.devices += o.devices
+	t.monitored += o.monitored
+	t.up += o.up
+	t.down += o.down
+	t.outages += o.outages
+	t.longest = max(t.longest, o.longest)
+	t.resolved += o.resolved
+	t.resolvedDowntime += o.resolvedDowntime
+}
+
+func (t tally) stats() model.AvailabilityStats {
+	st := model.AvailabilityStats{
+		MonitoredSeconds:     t.monitored.Seconds(),
+		UptimeSeconds:        t.up.Seconds(),
+		DowntimeSeconds:      t.down.Seconds(),
+		Outages:              t.outages,
+		LongestOutageSeconds: t.longest.Seconds(),
+	}
+	if t.monitored > 0 {
+		st.AvailabilityPercent = math.Round(float64(t.up)/float64(t.monitored)*100000) / 1000
+	}
+	if t.resolved > 0 {
+		st.MTTRSeconds = (t.resolvedDowntime / time.Duration(t.resolved)).Seconds()
+	}
+	return st
+}
+
+// deviceTally replays the status events of one device, oldest first, over
+// the window. The status at the start of the window is the one set by the
+// last event before it; failing that it is the old value of the first status
+// change inside the window, or the current status for a device seen before
+// the window that never changed since.
+func deviceTally(d model.Device, events []model.DeviceEvent, from, to time.Time) tally {
+	t := tally{devices: 1}
+	status := ""
+	start := from
+	i := 0
+	for ; i < len(events) && events[i].Timestamp.Before(from); i++ {
+		status = reportStatus(status, statusAfter(events[i]))
+	}
+	if status == "" && !d.FirstSeen.IsZero() {
+		if d.FirstSeen.After(from) {
+			start = d.FirstSeen
+		}
+		switch {
+		case i < len(events) && events[i].Type == model.EventStatusChanged:
+			status = reportStatus("", events[i].OldValue)
+		case i == len(events) && d.FirstSeen.Before(to):
+			status = reportStatus("", d.Status)
+		}
+	}
+
+	var outageStart time.Time
+	transition := func(next string, at time.Time) {
+		if status != "" {
+			span := at.Sub(start)
+			t.monitored += span
+			if status == "offline" {
+				t.down += span
+			} else {
+				t.up += span
+			}
+		}
+		wasDown, isDown := status == "offline", next == "offline"
+		switch {
+		case isDown && !wasDown:
+			outageStart = at
+		case wasDown && !isDown:
+			outage := at.Sub(outageStart)
+			t.outages++
+			t.resolved++
+			t.resolvedDowntime += outage
+			t.longest = max(t.longest, outage)
+		}
+		status, start = next, at
+	}
+
+	if status == "offline" {
+		outageStart = start
+	}
+	for ; i < len(events); i++ {
+		if events[i].Timestamp.After(to) {
+			break
+		}
+		transition(reportStatus(status, statusAfter(events[i])), events[i].Timestamp)
+	}
+	if status != "" {
+		span := to.Sub(start)
+		t.monitored += span
+		if status == "offline" {
+			t.down += span
+			t.outages++
+			t.longest = max(t.longest, to.Sub(outageStart))
+		} else {
+			t.up += span
+		}
+	}
+	return t
+}
+
+// reportStatus maps next onto the status it counts as, given the status
+// that preceded it. A degraded device has not settled yet, so it is still up
+// while it misses polls after being online and still down while it recovers
+// from an outage; an outage ends when the device is confirmed online.
+func reportStatus(prev, next string) string {
+	if next != "degraded" {
+		return next
+	}
+	return settledStatus(prev)
+}
+
+func statusAfter(e model.DeviceEvent) string {
+	if e.Type == model.EventDiscovered {
+		return "online"
+	}
+	return e.NewValue
+}
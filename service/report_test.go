@@ -0,0 +1,108 @@
+package service
+
+import (
+	"network-scanner/model"
+	"network-scanner/repository"
+	"testing"
+	"time"
+)
+
+func TestAvailability(t *testing.T) {
+	devices := newFakeDeviceRepo()
+	events := repository.NewInMemoryEventRepository()
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return t0.Add(time.Duration(h) * time.Hour) }
+
+	devices.Save(model.Device{ID: "web", IPAddress: "10.0.0.1", Status: "online", Tags: []string{"prod"}, FirstSeen: at(-48)})
+	devices.Save(model.Device{ID: "db", IPAddress: "10.0.0.2", Status: "offline", Tags: []string{"prod"}, FirstSeen: at(-48)})
+	devices.Save(model.Device{ID: "lab", IPAddress: "10.0.0.3", Status: "online", Tags: []string{"lab"}, FirstSeen: at(5)})
+	devices.Save(model.Device{ID: "new", IPAddress: "10.0.0.4", Status: "online", FirstSeen: at(30)})
+	status := func(id, from, to string, h int) model.DeviceEvent {
+		return model.DeviceEvent{DeviceID: id, Type: model.EventStatusChanged, OldValue: from, NewValue: to, Timestamp: at(h)}
+	}
+	events.Append(
+		model.DeviceEvent{DeviceID: "web", Type: model.EventDiscovered, Timestamp: at(-48)},
+		// web: two outages inside the window, 1h and 3h.
+		status("web", "online", "degraded", 2),
+		status("web", "degraded", "offline", 3),
+		status("web", "offline", "online", 4),
+		status("web", "online", "offline", 10),
+		status("web", "offline", "online", 13),
+		// db: known only from its first change; still down at the end.
+		status("db", "online", "offline", 6),
+		model.DeviceEvent{DeviceID: "lab", Type: model.EventDiscovered, Timestamp: at(5)},
+		model.DeviceEvent{DeviceID: "new", Type: model.EventDiscovered, Timestamp: at(30)},
+	)
+
+	svc := NewReportService(devices, events)
+	report, err := svc.Availability(t0, at(24), "")
+	if err != nil {
+		t.Fatalf("Availability error: %v", err)
+	}
+	if len(report.Devices) != 3 {
+		t.Fatalf("expected 3 monitored devices, got %+v", report.Devices)
+	}
+
+	web := report.Devices[0]
+	if web.DeviceID != "web" || web.Outages != 2 || web.DowntimeSeconds != 4*3600 ||
+		web.LongestOutageSeconds != 3*3600 || web.MTTRSeconds != 2*3600 {
+		t.Errorf("unexpected web stats: %+v", web)
+	}
+	if web.AvailabilityPercent != 83.333 {
+		t.Errorf("expected 83.333%% for web, got %v", web.AvailabilityPercent)
+	}
+
+	db := report.Devices[1]
+	if db.Outages != 1 || db.DowntimeSeconds != 18*3600 || db.MTTRSeconds != 0 || db.AvailabilityPercent != 25 {
+		t.Errorf("unexpected db stats: %+v", db)
+	}
+
+	lab := report.Devices[2]
+	if lab.MonitoredSeconds != 19*3600 || lab.AvailabilityPercent != 100 {
+		t.Errorf("expected lab monitored from discovery, got %+v", lab)
+	}
+
+	if len(report.Tags) != 2 || report.Tags[1].Tag != "prod" {
+		t.Fatalf("expected lab and prod tags, got %+v", report.Tags)
+	}
+	prod := report.Tags[1]
+	if prod.Devices != 2 || prod.Outages != 3 || prod.DowntimeSeconds != 22*3600 || prod.MTTRSeconds != 2*3600 {
+		t.Errorf("unexpected prod stats: %+v", prod)
+	}
+
+	onlyProd, _ := svc.Availability(t0, at(24), "prod")
+	if len(onlyProd.Devices) != 2 || len(onlyProd.Tags) != 1 {
+		t.Errorf("expected tag filter to keep prod only, got %+v", onlyProd)
+	}
+
+	if _, err := svc.Availability(at(24), t0, ""); err != ErrInvalidWindow {
+		t.Errorf("expected ErrInvalidWindow, got %v", err)
+	}
+}
+
+func TestAvailability_DegradedKeepsSettledState(t *testing.T) {
+	devices := newFakeDeviceRepo()
+	events := repository.NewInMemoryEventRepository()
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return t0.Add(time.Duration(h) * time.Hour) }
+	status := func(from, to string, h int) model.DeviceEvent {
+		return model.DeviceEvent{DeviceID: "nas", Type: model.EventStatusChanged, OldValue: from, NewValue: to, Timestamp: at(h)}
+	}
+
+	devices.Save(model.Device{ID: "nas", IPAddress: "10.0.0.1", Status: "online", FirstSeen: at(-48)})
+	events.Append(
+		// Missed polls before the outage count as up, the recovery as down.
+		status("online", "degraded", 2),
+		status("degraded", "offline", 4),
+		status("offline", "degraded", 6),
+		status("degraded", "online", 9),
+		status("online", "degraded", 20),
+		status("degraded", "online", 21),
+	)
+
+	report, _ := NewReportService(devices, events).Availability(t0, at(24), "")
+	nas := report.Devices[0]
+	if nas.Outages != 1 || nas.DowntimeSeconds != 5*3600 || nas.MTTRSeconds != 5*3600 {
+		t.Errorf("expected one 5h outage ending at confirmed online, got %+v", nas)
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidRange = errors.New("invalid IP range")
+	ErrScanNotFound = errors.New("scan not found")
+	ErrScanFinished = errors.New("scan already finished")
+	ErrScanTooLarge = errors.New("scan range too large")
+)
+
+// scanJob is the live, in-process state of a queued or running scan. The
+// persisted copy in the scan repository is only refreshed on state changes
+// and after each chunk, so progress is read from here while the job is active.
+type scanJob struct {
+	mu      sync.Mutex
+	job     model.ScanJob
+	targets *targetIterator
+	// discover holds IPv6 ranges too large to enumerate; their hosts are
+	// found by neighbour discovery once the job starts.
+	discover []addrRange
+	ports    []int
+	// online lists the devices found online so far, for the tag rules run
+	// once the scan ends.
+	online []string
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (j *scanJob) recordOnline(id string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.online = append(j.online, id)
+}
+
+// onlineIDs lists the devices the scan found online. The result is never
+// nil, since post-scan passes treat nil as all devices.
+func (j *scanJob) onlineIDs() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]string{}, j.online...)
+}
+
+func (j *scanJob) snapshot() model.ScanJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.job
+}
+
+func (j *scanJob) update(fn func(job *model.ScanJob)) model.ScanJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.job)
+	return j.job
+}
+
+func (s *ScannerService) WithScanRepository(r repository.ScanRepository) *ScannerService {
+	s.scans = r
+	return s
+}
+
+func (s *ScannerService) WithPortRepository(r repository.PortRepository) *ScannerService {
+	s.ports = r
+	return s
+}
+
+func (s *ScannerService) persist(j *scanJob) {
+	if err := s.scans.Save(j.snapshot()); err != nil {
+		s.logger.Error("Failed to persist scan job:", err)
+	}
+}
+
+func (s *ScannerService) finishScan(j *scanJob) {
+	job := j.update(func(job *model.ScanJob) {
+		switch {
+		case job.Status == model.ScanFailed:
+		case j.ctx.Err() != nil:
+			job.Status = model.ScanCancelled
+		default:
+			job.Status = model.ScanCompleted
+		}
+		job.FinishedAt = time.Now()
+	})
+	j.cancel()
+	s.persist(j)
+
+	s.mu.Lock()
+	delete(s.jobs, job.ID)
+	s.mu.Unlock()
+	close(j.done)
+}
+
+func (s *ScannerService) activeJob(id string) *scanJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id]
+}
+
+func (s *ScannerService) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		j.cancel()
+	}
+}
+
+func (s *ScannerService) ListScans() ([]model.ScanJob, error) {
+	jobs, err := s.scans.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		if j := s.activeJob(jobs[i].ID); j != nil {
+			jobs[i] = j.snapshot()
+		}
+	}
+	return jobs, nil
+}
+
+func (s *ScannerService) GetScan(id string) (*model.ScanJob, error) {
+	if j := s.activeJob(id); j != nil {
+		job := j.snapshot()
+		return &job, nil
+	}
+	job, err := s.scans.FindByID(id)
+	if err != nil || job == nil {
+		return nil, ErrScanNotFound
+	}
+	return job, nil
+}
+
+// CancelScan stops a queued or running scan and waits for it to wind down so
+// the returned job reflects its final state.
+func (s *ScannerService) CancelScan(id string) (*model.ScanJob, error) {
+	j := s.activeJob(id)
+	if j == nil {
+		job, err := s.GetScan(id)
+		if err != nil {
+			return nil, err
+		}
+		return job, ErrScanFinished
+	}
+	j.cancel()
+	<-j.done
+	job := j.snapshot()
+	return &job, nil
+}
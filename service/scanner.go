@@ -2,253 +2,431 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"network-scanner/logger"
 	"network-scanner/model"
 	"network-scanner/repository"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/j-keck/arping"
-	"github.com/tatsushid/go-fastping"
+	"golang.org/x/sync/semaphore"
 )
 
 type ScannerService struct {
 	repo       repository.DeviceRepository
-	cancel     context.CancelFunc
+	scans      repository.ScanRepository
+	ports      repository.PortRepository
+	addresses  repository.AddressRepository
+	events     repository.EventRepository
+	metrics    repository.MetricsRepository
+	groups     repository.GroupRepository
+	tags       repository.TagRepository
+	rules      repository.TagRuleRepository
 	logger     logger.Logger
 	wg         sync.WaitGroup
 	resolver   ManufacturerResolver
+	prober     *ProbeRegistry
 	pollCancel context.CancelFunc
 	pollWG     sync.WaitGroup
+
+	cfg    ScannerConfig
+	mode   string
+	mu     sync.Mutex
+	jobs   map[string]*scanJob
+	slots  chan struct{}
+	probes *semaphore.Weighted
+
+	healthMu sync.Mutex
+	health   map[string]*healthState
+
+	stackMu sync.Mutex
+	stacks  map[string]StackTraits
 }
 
 func NewScannerService(repo repository.DeviceRepository, logger logger.Logger) *ScannerService {
-	return &ScannerService{repo: repo, logger: logger, resolver: nil}
+	return newScannerService(repo, logger, nil)
 }
 
 func NewScannerServiceWithResolver(repo repository.DeviceRepository, logger logger.Logger, r ManufacturerResolver) *ScannerService {
-	return &ScannerService{repo: repo, logger: logger, resolver: r}
+	return newScannerService(repo, logger, r)
 }
 
-func concurrentPing(ips []string, timeout time.Duration) map[string]bool {
-	p := fastping.NewPinger()
-	p.MaxRTT = timeout
-	results := make(map[string]bool)
-	var mu sync.Mutex
-	for _, ip := range ips {
-		if addr, err := net.ResolveIPAddr("ip4:icmp", ip); err == nil {
-			p.AddIPAddr(addr)
-		}
+func newScannerService(repo repository.DeviceRepository, logger logger.Logger, r ManufacturerResolver) *ScannerService {
+	s := &ScannerService{
+		repo:      repo,
+		scans:     repository.NewInMemoryScanRepository(),
+		ports:     repository.NewInMemoryPortRepository(),
+		addresses: repository.NewInMemoryAddressRepository(),
+		events:    repository.NewInMemoryEventRepository(),
+		metrics:   repository.NewInMemoryMetricsRepository(),
+		groups:    repository.NewInMemoryGroupRepository(),
+		tags:      repository.NewInMemoryTagRepository(repo),
+		rules:     repository.NewInMemoryTagRuleRepository(),
+		logger:    logger,
+		resolver:  r,
+		jobs:      make(map[string]*scanJob),
+		health:    make(map[string]*healthState),
+		stacks:    make(map[string]StackTraits),
 	}
-	p.OnRecv = func(addr *net.IPAddr, rtt time.Duration) {
-		mu.Lock()
-		results[addr.String()] = true
-		mu.Unlock()
+	s.prober = NewProbeRegistry(netProber{s})
+	return s.WithConfig(DefaultScannerConfig())
+}
+
+// WithConfig applies scheduler limits. It must be called before any scan is
+// started, since running jobs keep using the previous slots and semaphore.
+func (s *ScannerService) WithConfig(cfg ScannerConfig) *ScannerService {
+	s.cfg = cfg.withDefaults()
+	s.resolveMode()
+	s.checkLivenessMethods()
+	arping.SetTimeout(s.cfg.Liveness.Timeout)
+	s.slots = make(chan struct{}, s.cfg.MaxConcurrentScans)
+	s.probes = semaphore.NewWeighted(int64(s.cfg.MaxConcurrentProbes))
+	return s
+}
+
+// concurrentPingRTT sends one raw ICMP echo to every address and returns the
+// round-trip time and TTL of each address that answered within timeout.
+func concurrentPingRTT(ips []string, timeout time.Duration) (map[string]echoReply, error) {
+	return echoAll(ips, timeout, true)
+}
+
+// reachable turns a round-trip time map into an answered/unanswered map.
+func reachable(rtts map[string]time.Duration, err error) (map[string]bool, error) {
+	if err != nil {
+		return nil, err
 	}
-	p.OnIdle = func() {}
-	_ = p.Run()
-	for _, ip := range ips {
-		if _, ok := results[ip]; !ok {
-			results[ip] = false
-		}
+	out := make(map[string]bool, len(rtts))
+	for ip := range rtts {
+		out[ip] = true
 	}
-	return results
+	return out, nil
+}
+
+type ScanOptions struct {
+	// Range is any target spec accepted by ParseTargets.
+	Range   string
+	Exclude []string
+	// Ports is a port spec accepted by ParsePorts. Empty falls back to the
+	// configured default; "none" disables port scanning for this job.
+	Ports string
+	// Group adds the addresses of a device group, by ID or name, to Range.
+	Group string
 }
 
-func (s *ScannerService) StartScan(ipRange string) {
-	ips, err := getIPList(ipRange)
+// StartScan queues a scan of ipRange, which may be any target spec accepted
+// by ParseTargets, minus the addresses matched by exclude.
+func (s *ScannerService) StartScan(ipRange string, exclude ...string) (model.ScanJob, error) {
+	return s.StartScanWithOptions(ScanOptions{Range: ipRange, Exclude: exclude})
+}
+
+func (s *ScannerService) StartScanWithOptions(opts ScanOptions) (model.ScanJob, error) {
+	if opts.Group != "" {
+		targets, err := s.groupTargets(opts.Group)
+		if err != nil {
+			return model.ScanJob{}, err
+		}
+		if strings.TrimSpace(opts.Range) != "" {
+			targets = opts.Range + "," + targets
+		}
+		opts.Range = targets
+	}
+	spec, err := ParseTargets(opts.Range, opts.Exclude)
 	if err != nil {
-		s.logger.Error("Invalid CIDR: ", ipRange)
-		return
+		s.logger.Error("Invalid scan target: ", err)
+		return model.ScanJob{}, err
+	}
+	spec, discover := spec.splitDiscovery(uint64(s.cfg.IPv6.MaxEnumerate))
+	size := spec.Size()
+	if size > uint64(s.cfg.MaxHostsPerScan) {
+		return model.ScanJob{}, fmt.Errorf("%w: %s covers %d addresses, limit is %d", ErrScanTooLarge, opts.Range, size, s.cfg.MaxHostsPerScan)
 	}
-	if s.cancel != nil {
-		s.cancel()
-		s.wg.Wait()
+
+	portSpec := opts.Ports
+	if portSpec == "" {
+		portSpec = s.cfg.PortScan.DefaultPorts
+	}
+	if strings.EqualFold(portSpec, "none") {
+		portSpec = ""
 	}
+	ports, err := ParsePorts(portSpec)
+	if err != nil {
+		return model.ScanJob{}, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
-	s.cancel = cancel
+	j := &scanJob{
+		job: model.ScanJob{
+			ID:         uuid.New().String(),
+			Range:      opts.Range,
+			Exclude:    opts.Exclude,
+			Ports:      portSpec,
+			Group:      opts.Group,
+			Status:     model.ScanQueued,
+			HostsTotal: int(size),
+			CreatedAt:  time.Now(),
+		},
+		targets:  spec.iterator(),
+		discover: discover,
+		ports:    ports,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.jobs[j.job.ID] = j
+	s.mu.Unlock()
+	s.persist(j)
+
 	s.wg.Add(1)
+	go s.runScan(j)
+	return j.snapshot(), nil
+}
 
-	go func() {
-		defer s.wg.Done()
-		s.logger.Info("Scan started for range: ", ipRange)
-		reachability := concurrentPing(ips, 1*time.Second)
+func (s *ScannerService) runScan(j *scanJob) {
+	defer s.wg.Done()
+	defer s.finishScan(j)
 
-		for _, ip := range ips {
-			select {
-			case <-ctx.Done():
-				s.logger.Warn("Scan cancelled")
-				return
-			default:
-				reachable := reachability[ip]
-				existing := s.repo.FindByIP(ip)
-
-				var (
-					id           string
-					hostname     string
-					mac          string
-					lastSeen     time.Time
-					firstSeen    time.Time
-					manufacturer string
-					tags         []string
-				)
-
-				if existing != nil {
-					id = existing.ID
-					firstSeen = existing.FirstSeen
-					manufacturer = existing.Manufacturer
-					tags = existing.Tags
-				} else {
-					id = uuid.New().String()
-				}
-
-				status := "offline"
-				if reachable {
-					status = "online"
-					hostname = resolveHostname(ip)
-					mac = resolveMAC(ip)
-					lastSeen = time.Now()
-
-					if firstSeen.IsZero() {
-						firstSeen = lastSeen
-					}
-
-					if s.resolver != nil && mac != "" {
-						if resolved := s.resolver.Resolve(mac); resolved != "" {
-							manufacturer = resolved
-						}
-					}
-				} else if existing != nil {
-					lastSeen = existing.LastSeen
-					hostname = existing.Hostname
-					mac = existing.MACAddress
-				}
-
-				device := model.Device{
-					ID:           id,
-					IPAddress:    ip,
-					MACAddress:   mac,
-					Hostname:     hostname,
-					Status:       status,
-					Manufacturer: manufacturer,
-					Tags:         tags,
-					LastSeen:     lastSeen,
-					FirstSeen:    firstSeen,
-				}
-
-				s.repo.Save(device)
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-j.ctx.Done():
+		return
+	}
+
+	job := j.update(func(job *model.ScanJob) {
+		job.Status = model.ScanRunning
+		job.StartedAt = time.Now()
+	})
+	s.persist(j)
+	s.logger.Info("Scan started for range: ", job.Range)
+
+	if len(j.discover) > 0 {
+		found := s.discoverIPv6(j.ctx, j.discover)
+		j.targets.add(found)
+		j.update(func(job *model.ScanJob) { job.HostsTotal += len(found) })
+		s.logger.Info("IPv6 discovery found ", len(found), " candidate hosts for range: ", job.Range)
+	}
+
+	for {
+		chunk := j.targets.NextChunk(s.cfg.ChunkSize)
+		if len(chunk) == 0 {
+			break
+		}
+		liveness, err := s.checkLiveness(j.ctx, chunk)
+		if err != nil {
+			if j.ctx.Err() != nil {
+				break
 			}
+			s.logger.Error("Liveness probes failed for range ", job.Range, ": ", err)
+			j.update(func(job *model.ScanJob) {
+				job.Status = model.ScanFailed
+				job.Error = err.Error()
+			})
+			return
 		}
-		s.logger.Info("Scan completed for range: ", ipRange)
-	}()
-}
-
-func (s *ScannerService) StartStatusPolling(interval time.Duration) {
-	if interval <= 0 {
-		interval = 5 * time.Second
+		s.processChunk(j, chunk, liveness)
+		if j.ctx.Err() != nil {
+			break
+		}
+		s.persist(j)
 	}
-	if s.pollCancel != nil {
-		s.pollCancel()
-		s.pollWG.Wait()
+	if j.ctx.Err() != nil {
+		s.logger.Warn("Scan cancelled for range: ", job.Range)
+		return
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	s.pollCancel = cancel
-	s.pollWG.Add(1)
-	go func() {
-		defer s.pollWG.Done()
-		t := time.NewTicker(interval)
-		defer t.Stop()
-		for {
-			select {
-			case <-ctx.Done():
+	ids := j.onlineIDs()
+	s.fingerprintDevices(ids)
+	s.classifyDevices(ids)
+	s.applyTagRules(ids)
+	s.logger.Info("Scan completed for range: ", job.Range)
+}
+
+// processChunk records every host of a probed chunk, enriching online ones
+// with up to HostParallelism concurrent lookups.
+func (s *ScannerService) processChunk(j *scanJob, chunk []string, liveness map[string]string) {
+	runParallel(j.ctx, s.cfg.HostParallelism, chunk, func(ip string) {
+		method := liveness[ip]
+		if method != "" {
+			if err := s.probes.Acquire(j.ctx, 1); err != nil {
 				return
-			case <-t.C:
-				devs := s.repo.GetAll()
-				if len(devs) == 0 {
-					continue
-				}
-				ips := make([]string, 0, len(devs))
-				for _, d := range devs {
-					if d.IPAddress != "" {
-						ips = append(ips, d.IPAddress)
-					}
-				}
-				reach := concurrentPing(ips, 1*time.Second)
-				now := time.Now()
-				for _, d := range devs {
-					if reach[d.IPAddress] {
-						if d.FirstSeen.IsZero() {
-							d.FirstSeen = now
-						}
-						d.LastSeen = now
-						d.Status = "online"
-					} else {
-						d.Status = "offline"
-					}
-					s.repo.Save(d)
-				}
 			}
+			dev := s.recordHost(j.ctx, ip, method)
+			s.probes.Release(1)
+			if len(j.ports) > 0 {
+				s.scanDevicePorts(j.ctx, dev, j.ports)
+			}
+			j.recordOnline(dev.ID)
+		} else {
+			s.recordHost(j.ctx, ip, "")
 		}
-	}()
+		j.update(func(job *model.ScanJob) {
+			job.HostsProbed++
+			if method != "" {
+				job.HostsOnline++
+			}
+		})
+	})
 }
 
-func (s *ScannerService) UpdateTags(id string, tags []string) error {
-	norm := normalizeTags(tags, 10)
-	return s.repo.UpdateTags(id, norm)
+func (s *ScannerService) pingBatched(ctx context.Context, ips []string) (map[string]bool, error) {
+	return reachable(s.pingBatchedRTT(ctx, ips))
 }
 
-func (s *ScannerService) FindByID(id string) (*model.Device, error) {
-	return s.repo.FindByID(id)
+// pingBatchedRTT pings ips in batches no larger than the global probe limit,
+// holding one probe slot per address for the duration of each batch. The TTL
+// of every reply is kept for OS fingerprinting.
+func (s *ScannerService) pingBatchedRTT(ctx context.Context, ips []string) (map[string]time.Duration, error) {
+	batch := s.cfg.MaxConcurrentProbes
+	results := make(map[string]time.Duration, len(ips))
+	for start := 0; start < len(ips); start += batch {
+		end := min(start+batch, len(ips))
+		if err := s.probes.Acquire(ctx, int64(end-start)); err != nil {
+			return nil, err
+		}
+		echo := concurrentPingRTT
+		if s.mode == ModeUnprivileged {
+			echo = datagramPingRTT
+		}
+		reach, err := echo(ips[start:end], 1*time.Second)
+		s.probes.Release(int64(end - start))
+		if err != nil {
+			return nil, err
+		}
+		for ip, r := range reach {
+			results[ip] = r.RTT
+			s.observeTTL(ip, r.TTL)
+		}
+	}
+	return results, nil
 }
 
-func (s *ScannerService) SearchDevices(q string) ([]model.Device, error) {
-	return s.repo.Search(q)
+// recordHost saves the outcome of probing ip. livenessMethod is the probe
+// that reached the host, or empty if it is offline.
+func (s *ScannerService) recordHost(ctx context.Context, ip string, livenessMethod string) model.Device {
+	if isIPv6(ip) {
+		return s.recordIPv6Host(ctx, ip, livenessMethod)
+	}
+	var hostname, mac string
+	if livenessMethod != "" {
+		hostname = s.prober.Hostname(ctx, ip)
+		mac = s.prober.MAC(ctx, ip)
+	}
+	existing := s.resolveIdentity(ip, mac)
+	if livenessMethod == "" && existing == nil {
+		// Nothing answered at an address we have never seen.
+		return model.Device{}
+	}
+
+	// Start from the stored record so everything a scan does not observe
+	// (tags, type, OS guess, flap state) carries over.
+	device := model.Device{ID: uuid.New().String()}
+	if existing != nil {
+		device = *existing
+	}
+	device.IPAddress = ip
+	// Scans go through the same hysteresis as status polls, so a single
+	// missed scan only degrades a device.
+	s.observe(&device, livenessMethod, time.Now())
+	if livenessMethod != "" {
+		device.Hostname = hostname
+		if mac != "" {
+			device.MACAddress = mac
+		}
+		if s.resolver != nil && device.MACAddress != "" {
+			if resolved := s.resolver.Resolve(device.MACAddress); resolved != "" {
+				device.Manufacturer = resolved
+			}
+		}
+		s.enrich(ctx, &device)
+	}
+
+	s.saveDevice(existing, device)
+	if livenessMethod != "" {
+		s.recordAddress(device.ID, ip, device.LastSeen)
+	}
+	return device
 }
 
-func (s *ScannerService) GetDevices() []model.Device {
-	return s.repo.GetAll()
+func (s *ScannerService) UpdateTags(id string, tags []string) error {
+	norm := normalizeTags(tags, s.cfg.MaxTagsPerDevice)
+	old, _ := s.repo.FindByID(id)
+	if err := s.repo.UpdateTags(id, norm); err != nil {
+		return err
+	}
+	if err := s.tags.Ensure(norm); err != nil {
+		return err
+	}
+	if old != nil && !slices.Equal(old.Tags, norm) {
+		s.appendEvents([]model.DeviceEvent{{
+			DeviceID:  id,
+			Type:      model.EventTagsChanged,
+			OldValue:  strings.Join(old.Tags, ","),
+			NewValue:  strings.Join(norm, ","),
+			Timestamp: time.Now(),
+		}})
+	}
+	return nil
 }
 
-func ping(ip string) bool {
-	p := fastping.NewPinger()
-	addr, err := net.ResolveIPAddr("ip4:icmp", ip)
+func (s *ScannerService) FindByID(id string) (*model.Device, error) {
+	d, err := s.repo.FindByID(id)
+	if err != nil || d == nil {
+		return d, err
+	}
+	d.Ports, err = s.ports.FindByDevice(d.ID)
 	if err != nil {
-		return false
+		s.logger.Error("Failed to load ports for device ", d.ID, ": ", err)
 	}
-	p.AddIPAddr(addr)
-	reachable := false
-	p.OnRecv = func(addr *net.IPAddr, rtt time.Duration) { reachable = true }
-	if err = p.Run(); err != nil {
-		return false
+	d.AddressHistory, err = s.addresses.FindByDevice(d.ID)
+	if err != nil {
+		s.logger.Error("Failed to load address history for device ", d.ID, ": ", err)
 	}
-	return reachable
+	return d, nil
 }
 
-func getIPList(cidr string) ([]string, error) {
-	ip, ipnet, err := net.ParseCIDR(cidr)
+// SearchDevices returns the devices matching a query in the syntax accepted
+// by ParseQuery. Malformed queries return a *QuerySyntaxError.
+func (s *ScannerService) SearchDevices(q string) ([]model.Device, error) {
+	expr, err := ParseQuery(q, time.Now())
 	if err != nil {
 		return nil, err
 	}
-	var out []string
-	for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); inc(ip) {
-		out = append(out, ip.String())
-	}
-	if len(out) > 2 {
-		out = out[1 : len(out)-1]
-	}
-	return out, nil
+	page, err := s.repo.List(repository.DeviceQuery{Match: expr})
+	return page.Devices, err
 }
 
-func resolveHostname(ip string) string {
-	names, err := net.LookupAddr(ip)
+func (s *ScannerService) GetDevices() []model.Device {
+	return s.repo.GetAll()
+}
+
+func (s *ScannerService) ListDevices(q repository.DeviceQuery) (model.DevicePage, error) {
+	return s.repo.List(q)
+}
+
+func resolveHostname(ctx context.Context, ip string) string {
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
 	if err != nil || len(names) == 0 {
 		return ""
 	}
 	return names[0]
 }
 
+// lookupMAC resolves the hardware address of ip. IPv6 addresses and
+// unprivileged mode always go through the kernel neighbour tables; the
+// preceding liveness probe will have triggered ARP or NDP resolution.
+func (s *ScannerService) lookupMAC(ip string) string {
+	if s.mode == ModeUnprivileged || isIPv6(ip) {
+		return neighbourMAC(ip)
+	}
+	return resolveMAC(ip)
+}
+
 func resolveMAC(ip string) string {
 	ipAddr := net.ParseIP(ip)
 	if ipAddr.IsLoopback() {
@@ -262,19 +440,22 @@ func resolveMAC(ip string) string {
 }
 
 func (s *ScannerService) Clear() {
-	if s.cancel != nil {
-		s.cancel()
-		s.wg.Wait()
-	}
+	s.cancelAll()
+	s.wg.Wait()
 	s.repo.Clear()
-	s.logger.Info("All device records cleared.")
-}
-
-func inc(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
+	if err := s.ports.Clear(); err != nil {
+		s.logger.Error("Failed to clear ports:", err)
+	}
+	if err := s.addresses.Clear(); err != nil {
+		s.logger.Error("Failed to clear address history:", err)
 	}
+	if err := s.events.Clear(); err != nil {
+		s.logger.Error("Failed to clear device events:", err)
+	}
+	if err := s.metrics.Clear(); err != nil {
+		s.logger.Error("Failed to clear device metrics:", err)
+	}
+	s.resetHealth()
+	s.resetStacks()
+	s.logger.Info("All device records cleared.")
 }
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"strings"
 	"sync"
 	"testing"
@@ -42,6 +43,16 @@ func (r *fakeDeviceRepo) Save(d model.Device) {
 	}
 	r.byID[d.ID] = d
 	r.ipIndex[d.IPAddress] = d.ID
+	for _, ip := range d.IPv6Addresses {
+		r.ipIndex[ip] = d.ID
+	}
+}
+
+func (r *fakeDeviceRepo) SaveBatch(devices []model.Device) error {
+	for _, d := range devices {
+		r.Save(d)
+	}
+	return nil
 }
 
 func (r *fakeDeviceRepo) GetAll() []model.Device {
@@ -73,6 +84,30 @@ func (r *fakeDeviceRepo) FindByIP(ip string) *model.Device {
 	return nil
 }
 
+func (r *fakeDeviceRepo) FindByMAC(mac string) *model.Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, d := range r.byID {
+		if mac != "" && strings.EqualFold(d.MACAddress, mac) {
+			copy := d
+			return &copy
+		}
+	}
+	return nil
+}
+
+func (r *fakeDeviceRepo) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for ip, owner := range r.ipIndex {
+		if owner == id {
+			delete(r.ipIndex, ip)
+		}
+	}
+	delete(r.byID, id)
+	return nil
+}
+
 func (r *fakeDeviceRepo) FindByID(id string) (*model.Device, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -109,6 +144,34 @@ func (r *fakeDeviceRepo) UpdateTags(id string, tags []string) error {
 	return nil
 }
 
+func (r *fakeDeviceRepo) SetTags(tags map[string][]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, t := range tags {
+		if d, ok := r.byID[id]; ok {
+			d.Tags = append([]string(nil), t...)
+			r.byID[id] = d
+		}
+	}
+	return nil
+}
+
+func (r *fakeDeviceRepo) TagCounts() (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, d := range r.GetAll() {
+		for _, t := range d.Tags {
+			counts[t]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *fakeDeviceRepo) List(q repository.DeviceQuery) (model.DevicePage, error) {
+	mem := repository.NewInMemoryRepository()
+	mem.SaveBatch(r.GetAll())
+	return mem.List(q)
+}
+
 func (r *fakeDeviceRepo) Search(q string) ([]model.Device, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -140,17 +203,14 @@ var _ repository.DeviceRepository = (*fakeDeviceRepo)(nil)
 func TestStartScan_SingleIP(t *testing.T) {
 	repo := newFakeDeviceRepo()
 	log := &dummyLogger{}
-	svc := NewScannerService(repo, log)
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.5", MAC: "aa:bb:cc:dd:ee:05", Hostname: "printer.lan"})
+	svc := NewScannerService(repo, log).WithProber(net)
 
-	svc.StartScan("127.0.0.1/32")
-
-	deadline := time.Now().Add(2 * time.Second)
-	for {
-		if len(svc.GetDevices()) == 1 || time.Now().After(deadline) {
-			break
-		}
-		time.Sleep(50 * time.Millisecond)
+	job, err := svc.StartScan("10.0.0.5/32")
+	if err != nil {
+		t.Fatalf("StartScan error: %v", err)
 	}
+	waitForScan(t, svc, job.ID)
 
 	devices := svc.GetDevices()
 	if len(devices) != 1 {
@@ -158,24 +218,196 @@ func TestStartScan_SingleIP(t *testing.T) {
 	}
 
 	d := devices[0]
-	if d.IPAddress != "127.0.0.1" {
-		t.Errorf("expected IP 127.0.0.1, got %s", d.IPAddress)
+	if d.IPAddress != "10.0.0.5" {
+		t.Errorf("expected IP 10.0.0.5, got %s", d.IPAddress)
 	}
 	if d.ID == "" {
 		t.Errorf("device ID should not be empty")
 	}
-	if d.Status == "online" {
-		if d.FirstSeen.IsZero() {
-			t.Errorf("expected FirstSeen to be set for online device")
+	if d.Status != "online" {
+		t.Errorf("expected device to be online, got %s", d.Status)
+	}
+	if d.MACAddress != "aa:bb:cc:dd:ee:05" || d.Hostname != "printer.lan" {
+		t.Errorf("expected MAC and hostname from the prober, got %q / %q", d.MACAddress, d.Hostname)
+	}
+	if d.FirstSeen.IsZero() || d.LastSeen.IsZero() {
+		t.Errorf("expected seen timestamps to be set, got %v / %v", d.FirstSeen, d.LastSeen)
+	}
+}
+
+func TestStartScan_OfflineHost(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.5", Down: true})
+	svc := NewScannerService(repo, &dummyLogger{}).WithProber(net)
+
+	job, _ := svc.StartScan("10.0.0.5")
+	waitForScan(t, svc, job.ID)
+
+	if d := repo.FindByIP("10.0.0.5"); d != nil {
+		t.Fatalf("expected no device for a host that never answered, got %+v", d)
+	}
+
+	seen := time.Now().Add(-time.Hour)
+	repo.Save(model.Device{ID: "dev-1", IPAddress: "10.0.0.5", Status: "online", LastSeen: seen})
+	job, _ = svc.StartScan("10.0.0.5")
+	waitForScan(t, svc, job.ID)
+
+	d := repo.FindByIP("10.0.0.5")
+	if d == nil || d.ID != "dev-1" || d.Status != "degraded" {
+		t.Fatalf("expected the known device to be degraded, got %+v", d)
+	}
+	if !d.LastSeen.Equal(seen) {
+		t.Errorf("expected LastSeen to be kept, got %v", d.LastSeen)
+	}
+}
+
+func waitForScan(t *testing.T, svc *ScannerService, id string) model.ScanJob {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		job, err := svc.GetScan(id)
+		if err != nil {
+			t.Fatalf("GetScan error: %v", err)
 		}
-		if d.LastSeen.IsZero() {
-			t.Errorf("expected LastSeen to be set for online device")
+		if job.Finished() {
+			return *job
 		}
-	} else if d.Status == "offline" {
-		if !d.LastSeen.IsZero() || !d.FirstSeen.IsZero() {
-			t.Logf("offline device still had seen timestamps: %v / %v", d.FirstSeen, d.LastSeen)
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for scan %s, last status %s", id, job.Status)
 		}
-	} else {
-		t.Errorf("unexpected device status: %s", d.Status)
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestStartScan_InvalidRange(t *testing.T) {
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{})
+
+	if _, err := svc.StartScan("not-a-cidr"); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("expected ErrInvalidRange, got %v", err)
+	}
+	jobs, _ := svc.ListScans()
+	if len(jobs) != 0 {
+		t.Errorf("expected no scan jobs after invalid request, got %d", len(jobs))
+	}
+}
+
+func TestScanJob_Lifecycle(t *testing.T) {
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.1", MAC: "aa:00:00:00:00:01"})
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithProber(net)
+
+	job, err := svc.StartScan("10.0.0.1/32")
+	if err != nil {
+		t.Fatalf("StartScan error: %v", err)
+	}
+	if job.ID == "" || job.HostsTotal != 1 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	final := waitForScan(t, svc, job.ID)
+	if final.Status != model.ScanCompleted {
+		t.Errorf("expected the scan to complete, got %q (%s)", final.Status, final.Error)
+	}
+	if final.HostsProbed != 1 || final.HostsOnline != 1 {
+		t.Errorf("expected 1 host probed and online, got %d / %d", final.HostsProbed, final.HostsOnline)
+	}
+	if final.FinishedAt.IsZero() {
+		t.Errorf("expected FinishedAt to be set")
+	}
+
+	if _, err := svc.CancelScan(job.ID); !errors.Is(err, ErrScanFinished) {
+		t.Errorf("expected ErrScanFinished when cancelling finished scan, got %v", err)
+	}
+	if _, err := svc.CancelScan("missing"); !errors.Is(err, ErrScanNotFound) {
+		t.Errorf("expected ErrScanNotFound, got %v", err)
+	}
+}
+
+func TestScanJob_CancelSkipsPostScan(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	net := NewFakeNetwork(1,
+		FakeHost{IP: "10.0.0.1", MAC: "aa:00:00:00:00:01"},
+		FakeHost{IP: "10.0.0.2", MAC: "aa:00:00:00:00:02", Latency: 2 * time.Second},
+	).WithTimeout(5 * time.Second)
+	cfg := DefaultScannerConfig()
+	cfg.ChunkSize = 1
+	svc := NewScannerService(repo, &dummyLogger{}).WithConfig(cfg).WithProber(net)
+	if _, err := svc.SaveTagRule(model.TagRule{Name: "lab", Enabled: true, Match: model.RuleMatch{CIDR: "10.0.0.0/30"}, Tags: []string{"lab"}}); err != nil {
+		t.Fatalf("SaveTagRule error: %v", err)
+	}
+
+	job, err := svc.StartScan("10.0.0.0/30")
+	if err != nil {
+		t.Fatalf("StartScan error: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for repo.FindByIP("10.0.0.1") == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for the first chunk")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := svc.CancelScan(job.ID); err != nil {
+		t.Fatalf("CancelScan error: %v", err)
+	}
+	if final := waitForScan(t, svc, job.ID); final.Status != model.ScanCancelled {
+		t.Fatalf("expected the scan to be cancelled, got %q", final.Status)
+	}
+
+	d := repo.FindByIP("10.0.0.1")
+	if len(d.Tags) != 0 || d.OS != nil {
+		t.Errorf("expected no post-scan passes after cancel, got tags %v and OS %+v", d.Tags, d.OS)
+	}
+}
+
+func TestScanJob_CancelQueued(t *testing.T) {
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).
+		WithConfig(ScannerConfig{MaxConcurrentScans: 1})
+
+	// Occupy the only scan slot so the job stays queued.
+	svc.slots <- struct{}{}
+	job, err := svc.StartScan("127.0.0.1/32")
+	if err != nil {
+		t.Fatalf("StartScan error: %v", err)
+	}
+	if job.Status != model.ScanQueued {
+		t.Fatalf("expected queued job, got %q", job.Status)
+	}
+
+	cancelled, err := svc.CancelScan(job.ID)
+	if err != nil {
+		t.Fatalf("CancelScan error: %v", err)
+	}
+	if cancelled.Status != model.ScanCancelled {
+		t.Errorf("expected cancelled status, got %q", cancelled.Status)
+	}
+	<-svc.slots
+}
+
+func TestStartScan_ConcurrentRanges(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	net := NewFakeNetwork(1,
+		FakeHost{IP: "10.0.0.1", MAC: "aa:00:00:00:00:01", Latency: 50 * time.Millisecond},
+		FakeHost{IP: "10.0.1.1", MAC: "aa:00:00:00:01:01", Latency: 50 * time.Millisecond},
+	)
+	svc := NewScannerService(repo, &dummyLogger{}).
+		WithConfig(ScannerConfig{MaxConcurrentScans: 2, MaxConcurrentProbes: 4, HostParallelism: 2}).
+		WithProber(net)
+
+	first, err := svc.StartScan("10.0.0.1/32")
+	if err != nil {
+		t.Fatalf("StartScan error: %v", err)
+	}
+	second, err := svc.StartScan("10.0.1.1/32")
+	if err != nil {
+		t.Fatalf("StartScan error: %v", err)
+	}
+
+	for _, id := range []string{first.ID, second.ID} {
+		if job := waitForScan(t, svc, id); job.Status == model.ScanCancelled {
+			t.Errorf("scan %s was cancelled by a concurrent scan", id)
+		}
+	}
+	if n := len(repo.GetAll()); n != 2 {
+		t.Errorf("expected devices from both scans, got %d", n)
 	}
 }
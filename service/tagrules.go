@@ -0,0 +1,369 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRuleNotFound   = errors.New("tag rule not found")
+	ErrInvalidRule    = errors.New("invalid tag rule")
+	ErrRuleNotApplied = errors.New("tag rule saved but not applied")
+)
+
+func (s *ScannerService) WithTagRuleRepository(r repository.TagRuleRepository) *ScannerService {
+	s.rules = r
+	return s
+}
+
+// compiledRule is a validated tag rule ready to be matched against devices.
+type compiledRule struct {
+	model.TagRule
+	prefix   netip.Prefix
+	hostname *regexp.Regexp
+	mac      string
+}
+
+func compileRule(r model.TagRule) (compiledRule, error) {
+	c := compiledRule{TagRule: r}
+	m := r.Match
+	if m.Manufacturer == "" && m.CIDR == "" && m.Hostname == "" && len(m.Ports) == 0 && m.MACPrefix == "" {
+		return c, fmt.Errorf("%w: at least one condition is required", ErrInvalidRule)
+	}
+	if m.CIDR != "" {
+		p, err := netip.ParsePrefix(m.CIDR)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(m.CIDR)
+			if aerr != nil {
+				return c, fmt.Errorf("%w: invalid cidr %q", ErrInvalidRule, m.CIDR)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		c.prefix = p.Masked()
+	}
+	if m.Hostname != "" {
+		re, err := regexp.Compile(m.Hostname)
+		if err != nil {
+			return c, fmt.Errorf("%w: hostname: %v", ErrInvalidRule, err)
+		}
+		c.hostname = re
+	}
+	for _, p := range m.Ports {
+		if p < 1 || p > 65535 {
+			return c, fmt.Errorf("%w: invalid port %d", ErrInvalidRule, p)
+		}
+	}
+	if m.MACPrefix != "" {
+		c.mac = macHex(m.MACPrefix)
+		if c.mac == "" || len(c.mac) > 12 || strings.Trim(c.mac, "0123456789abcdef") != "" {
+			return c, fmt.Errorf("%w: invalid mac_prefix %q", ErrInvalidRule, m.MACPrefix)
+		}
+	}
+	return c, nil
+}
+
+// macHex strips separators from a MAC address or prefix.
+func macHex(mac string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.TrimSpace(mac)))
+}
+
+// match reports whether d satisfies every condition of the rule. ports
+// lists the open ports of d and is only consulted by rules naming ports.
+func (c compiledRule) match(d model.Device, ports []int) bool {
+	m := c.Match
+	if m.Manufacturer != "" && !strings.Contains(strings.ToLower(d.Manufacturer), strings.ToLower(m.Manufacturer)) {
+		return false
+	}
+	if c.prefix.IsValid() && !slices.ContainsFunc(append([]string{d.IPAddress}, d.IPv6Addresses...), func(ip string) bool {
+		addr, err := netip.ParseAddr(ip)
+		return err == nil && c.prefix.Contains(addr.Unmap())
+	}) {
+		return false
+	}
+	if c.hostname != nil && (d.Hostname == "" || !c.hostname.MatchString(d.Hostname)) {
+		return false
+	}
+	if c.mac != "" && (d.MACAddress == "" || !strings.HasPrefix(macHex(d.MACAddress), c.mac)) {
+		return false
+	}
+	for _, p := range m.Ports {
+		if !slices.Contains(ports, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveTagRule creates a rule when r has no ID and replaces it otherwise.
+// New rules are appended after the existing ones unless they carry a
+// position.
+func (s *ScannerService) SaveTagRule(r model.TagRule) (model.TagRule, error) {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return r, fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	tags, err := tagNames(r.Tags)
+	if err != nil {
+		return r, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	if len(tags) == 0 {
+		return r, fmt.Errorf("%w: at least one tag is required", ErrInvalidRule)
+	}
+	r.Tags = tags
+	if r.Match.MACPrefix != "" {
+		r.Match.MACPrefix = strings.ToLower(strings.TrimSpace(r.Match.MACPrefix))
+	}
+	if _, err := compileRule(r); err != nil {
+		return r, err
+	}
+
+	// unshielded is set when r used to stop evaluation, so the rules after it
+	// may now reach devices it matched.
+	unshielded := false
+	if r.ID == "" {
+		if r.Position == 0 {
+			all, err := s.rules.GetAll()
+			if err != nil {
+				return r, err
+			}
+			for _, other := range all {
+				r.Position = max(r.Position, other.Position+1)
+			}
+		}
+		r.ID = uuid.New().String()
+		r.CreatedAt = time.Now()
+	} else {
+		old, err := s.TagRule(r.ID)
+		if err != nil {
+			return r, err
+		}
+		r.CreatedAt = old.CreatedAt
+		unshielded = old.Enabled && old.Stop
+	}
+	if err := s.rules.Save(r); err != nil {
+		return r, err
+	}
+	// Scans and polls only evaluate the devices they touch, so a new or
+	// edited rule has to reach the devices that are already known.
+	if r.Enabled || unshielded {
+		if err := s.applyTagRule(r, unshielded); err != nil {
+			return r, fmt.Errorf("%w: %v", ErrRuleNotApplied, err)
+		}
+	}
+	return r, nil
+}
+
+func (s *ScannerService) TagRules() ([]model.TagRule, error) {
+	return s.rules.GetAll()
+}
+
+func (s *ScannerService) TagRule(id string) (*model.TagRule, error) {
+	r, err := s.rules.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, ErrRuleNotFound
+	}
+	return r, nil
+}
+
+func (s *ScannerService) DeleteTagRule(id string) error {
+	if _, err := s.TagRule(id); err != nil {
+		return err
+	}
+	return s.rules.Delete(id)
+}
+
+// DryRunTagRules evaluates the enabled rules, or only candidate when it is
+// given, against every device and lists the devices that would change
+// without writing anything.
+func (s *ScannerService) DryRunTagRules(candidate *model.TagRule) ([]model.TagRuleChange, error) {
+	var rules []compiledRule
+	if candidate != nil {
+		c := *candidate
+		tags, err := tagNames(c.Tags)
+		if err != nil || len(tags) == 0 {
+			return nil, fmt.Errorf("%w: at least one valid tag is required", ErrInvalidRule)
+		}
+		c.Tags = tags
+		compiled, err := compileRule(c)
+		if err != nil {
+			return nil, err
+		}
+		rules = []compiledRule{compiled}
+	} else {
+		var err error
+		if rules, err = s.enabledRules(); err != nil {
+			return nil, err
+		}
+	}
+	page, err := s.repo.List(repository.DeviceQuery{Sort: "ip_address"})
+	if err != nil {
+		return nil, err
+	}
+	return s.evaluateRules(rules, page.Devices), nil
+}
+
+// applyTagRules runs the enabled rules against the given devices, or all of
+// them when ids is nil, and saves the tags they add. Rules only ever add
+// tags.
+func (s *ScannerService) applyTagRules(ids []string) {
+	rules, err := s.enabledRules()
+	if err != nil {
+		s.logger.Error("Failed to load tag rules: ", err)
+		return
+	}
+	if err := s.runTagRules(rules, ids); err != nil {
+		s.logger.Error("Failed to apply tag rules: ", err)
+	}
+}
+
+// applyTagRule runs one saved rule against every known device, along with
+// the rules after it when withLater is set. Earlier rules that stop
+// evaluation still shield the devices they match, but add nothing; their
+// tags were applied when they were saved.
+func (s *ScannerService) applyTagRule(r model.TagRule, withLater bool) error {
+	all, err := s.rules.GetAll()
+	if err != nil {
+		return err
+	}
+	var rules []compiledRule
+	later := false
+	for _, other := range all {
+		switch {
+		case other.ID == r.ID:
+			later = true
+			if !other.Enabled {
+				continue
+			}
+		case !other.Enabled, later && !withLater:
+			continue
+		case !later && !other.Stop:
+			continue
+		case !later:
+			other.Tags = nil
+		}
+		c, err := compileRule(other)
+		if err != nil {
+			s.logger.Warn("Skipping tag rule ", other.Name, ": ", err)
+			continue
+		}
+		rules = append(rules, c)
+	}
+	return s.runTagRules(rules, nil)
+}
+
+// runTagRules evaluates rules against the given devices, or all of them when
+// ids is nil, and saves the tags they add.
+func (s *ScannerService) runTagRules(rules []compiledRule, ids []string) error {
+	if len(rules) == 0 || (ids != nil && len(ids) == 0) {
+		return nil
+	}
+	devices := s.loadDevices(ids)
+	changes := s.evaluateRules(rules, devices)
+	if len(changes) == 0 {
+		return nil
+	}
+	next := make(map[string][]string, len(changes))
+	var added []string
+	for _, c := range changes {
+		next[c.DeviceID] = append(slices.Clone(c.Tags), c.Add...)
+		for _, t := range c.Add {
+			if !slices.Contains(added, t) {
+				added = append(added, t)
+			}
+		}
+	}
+	if err := s.tags.Ensure(added); err != nil {
+		return err
+	}
+	_, err := s.applyTags(devices, next)
+	return err
+}
+
+func (s *ScannerService) enabledRules() ([]compiledRule, error) {
+	all, err := s.rules.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var out []compiledRule
+	for _, r := range all {
+		if !r.Enabled {
+			continue
+		}
+		c, err := compileRule(r)
+		if err != nil {
+			s.logger.Warn("Skipping tag rule ", r.Name, ": ", err)
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// evaluateRules lists the tags each device would gain. A device never
+// exceeds MaxTagsPerDevice; tags past the cap are dropped.
+func (s *ScannerService) evaluateRules(rules []compiledRule, devices []model.Device) []model.TagRuleChange {
+	needPorts := slices.ContainsFunc(rules, func(r compiledRule) bool { return len(r.Match.Ports) > 0 })
+	changes := []model.TagRuleChange{}
+	for _, d := range devices {
+		var ports []int
+		if needPorts {
+			ports = s.openPorts(d.ID)
+		}
+		change := model.TagRuleChange{DeviceID: d.ID, IPAddress: d.IPAddress, Hostname: d.Hostname, Tags: d.Tags}
+		have := slices.Clone(d.Tags)
+		for _, r := range rules {
+			if !r.match(d, ports) {
+				continue
+			}
+			applied := false
+			for _, t := range r.Tags {
+				if slices.Contains(have, t) || len(have) >= s.cfg.MaxTagsPerDevice {
+					continue
+				}
+				have = append(have, t)
+				change.Add = append(change.Add, t)
+				applied = true
+			}
+			if applied {
+				change.Rules = append(change.Rules, r.Name)
+			}
+			if r.Stop {
+				break
+			}
+		}
+		if len(change.Add) > 0 {
+			if change.Tags == nil {
+				change.Tags = []string{}
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+func (s *ScannerService) openPorts(deviceID string) []int {
+	ports, err := s.ports.FindByDevice(deviceID)
+	if err != nil {
+		s.logger.Error("Failed to load ports of ", deviceID, ": ", err)
+		return nil
+	}
+	var out []int
+	for _, p := range ports {
+		if p.State == model.PortOpen {
+			out = append(out, p.Port)
+		}
+	}
+	return out
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"errors"
+	"network-scanner/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTagRule_Match(t *testing.T) {
+	pi := model.Device{
+		IPAddress:     "10.0.20.7",
+		IPv6Addresses: []string{"fd00::7"},
+		MACAddress:    "B8:27:EB:12:34:56",
+		Hostname:      "printer-2f",
+		Manufacturer:  "Raspberry Pi Foundation",
+	}
+	cases := []struct {
+		name  string
+		match model.RuleMatch
+		want  bool
+	}{
+		{"manufacturer substring", model.RuleMatch{Manufacturer: "raspberry"}, true},
+		{"manufacturer mismatch", model.RuleMatch{Manufacturer: "Cisco"}, false},
+		{"cidr", model.RuleMatch{CIDR: "10.0.20.0/24"}, true},
+		{"cidr on ipv6 address", model.RuleMatch{CIDR: "fd00::/64"}, true},
+		{"single address", model.RuleMatch{CIDR: "10.0.20.8"}, false},
+		{"hostname regex", model.RuleMatch{Hostname: `^printer-\d`}, true},
+		{"hostname mismatch", model.RuleMatch{Hostname: `^switch`}, false},
+		{"mac prefix", model.RuleMatch{MACPrefix: "b8-27-eb"}, true},
+		{"mac mismatch", model.RuleMatch{MACPrefix: "dc:a6:32"}, false},
+		{"ports", model.RuleMatch{Ports: []int{22, 9100}}, true},
+		{"missing port", model.RuleMatch{Ports: []int{22, 80}}, false},
+		{"all conditions", model.RuleMatch{Manufacturer: "Pi", CIDR: "10.0.0.0/8", Ports: []int{22}}, true},
+	}
+	for _, c := range cases {
+		rule, err := compileRule(model.TagRule{Match: c.match})
+		if err != nil {
+			t.Fatalf("%s: compile error: %v", c.name, err)
+		}
+		if got := rule.match(pi, []int{22, 9100}); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+
+	for _, bad := range []model.RuleMatch{{}, {CIDR: "10.0.0.0/33"}, {Hostname: "("}, {Ports: []int{0}}, {MACPrefix: "zz"}} {
+		if _, err := compileRule(model.TagRule{Match: bad}); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("expected ErrInvalidRule for %+v, got %v", bad, err)
+		}
+	}
+}
+
+func TestTagRules_OrderAndDryRun(t *testing.T) {
+	svc := groupFixture(t)
+	svc.ports.SavePorts("s1", []model.Port{{Port: 22, Protocol: "tcp", State: model.PortOpen}})
+
+	changes, err := svc.DryRunTagRules(&model.TagRule{Name: "candidate", Match: model.RuleMatch{Ports: []int{22}}, Tags: []string{"ssh"}})
+	if err != nil {
+		t.Fatalf("DryRunTagRules error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].DeviceID != "s1" || !slices.Equal(changes[0].Rules, []string{"candidate"}) {
+		t.Errorf("expected only s1 for the candidate rule, got %+v", changes)
+	}
+	if tags := tagsOf(t, svc, "s1"); !slices.Equal(tags, []string{"server"}) {
+		t.Errorf("expected dry run not to write, got %v", tags)
+	}
+
+	first, err := svc.SaveTagRule(model.TagRule{Name: "lab net", Enabled: true, Match: model.RuleMatch{CIDR: "10.0.0.0/30"}, Tags: []string{"Lab"}, Stop: true})
+	if err != nil {
+		t.Fatalf("SaveTagRule error: %v", err)
+	}
+	second, _ := svc.SaveTagRule(model.TagRule{Name: "ssh", Enabled: true, Match: model.RuleMatch{Ports: []int{22}}, Tags: []string{"ssh"}})
+	svc.SaveTagRule(model.TagRule{Name: "disabled", Match: model.RuleMatch{CIDR: "10.0.0.0/8"}, Tags: []string{"never"}})
+	if first.Position != 0 || second.Position != 1 {
+		t.Errorf("expected rules to be appended in order, got %d and %d", first.Position, second.Position)
+	}
+	if _, err := svc.SaveTagRule(model.TagRule{Name: "no tags", Match: model.RuleMatch{CIDR: "10.0.0.0/8"}}); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("expected ErrInvalidRule, got %v", err)
+	}
+
+	// Saved rules reach the devices already known. s1 (10.0.0.3) matches
+	// both rules, but the stop on "lab net" wins.
+	want := map[string][]string{"p1": {"printer", "lab"}, "p2": {"printer", "lab"}, "s1": {"server", "lab"}}
+	for id, tags := range want {
+		if got := tagsOf(t, svc, id); !slices.Equal(got, tags) {
+			t.Errorf("expected %s to have %v, got %v", id, tags, got)
+		}
+	}
+	if changes, _ := svc.DryRunTagRules(nil); len(changes) != 0 {
+		t.Errorf("expected nothing left to apply, got %+v", changes)
+	}
+	if _, err := svc.Tag("lab"); err != nil {
+		t.Errorf("expected rule tags to be registered, got %v", err)
+	}
+
+	first.Stop = false
+	svc.SaveTagRule(first)
+	if tags := tagsOf(t, svc, "s1"); !slices.Equal(tags, []string{"server", "lab", "ssh"}) {
+		t.Errorf("expected the edited rule to let s1 gain ssh, got %v", tags)
+	}
+
+	// Scans and polls only evaluate the devices they touched.
+	svc.ports.SavePorts("p2", []model.Port{{Port: 22, Protocol: "tcp", State: model.PortOpen}})
+	svc.applyTagRules([]string{"p1"})
+	if tags := tagsOf(t, svc, "p2"); !slices.Equal(tags, []string{"printer", "lab"}) {
+		t.Errorf("expected devices outside the batch to be left alone, got %v", tags)
+	}
+	svc.applyTagRules([]string{})
+	if tags := tagsOf(t, svc, "p2"); !slices.Equal(tags, []string{"printer", "lab"}) {
+		t.Errorf("expected an empty batch to change nothing, got %v", tags)
+	}
+}
+
+func TestTagRules_AppliedAfterScan(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	net := NewFakeNetwork(1, FakeHost{IP: "10.0.0.5", MAC: "b8:27:eb:00:00:05", Hostname: "pi-1"})
+	cfg := fastPolling()
+	cfg.Polling.LastSeenPrecision = time.Nanosecond
+	svc := NewScannerService(repo, &dummyLogger{}).WithConfig(cfg).WithProber(net)
+	svc.SaveTagRule(model.TagRule{Name: "pis", Enabled: true, Match: model.RuleMatch{MACPrefix: "B8:27:EB"}, Tags: []string{"iot"}})
+
+	job, _ := svc.StartScan("10.0.0.5")
+	waitForScan(t, svc, job.ID)
+	svc.wg.Wait()
+	d := repo.FindByIP("10.0.0.5")
+	if d == nil || !slices.Equal(d.Tags, []string{"iot"}) {
+		t.Fatalf("expected the scanned device to be tagged iot, got %+v", d)
+	}
+
+	// Rules added later apply to known devices right away.
+	svc.SaveTagRule(model.TagRule{Name: "pi hosts", Enabled: true, Match: model.RuleMatch{Hostname: "^pi-"}, Tags: []string{"pi"}})
+	if d := repo.FindByIP("10.0.0.5"); !slices.Equal(d.Tags, []string{"iot", "pi"}) {
+		t.Errorf("expected saving the rule to apply it, got %v", d.Tags)
+	}
+
+	// Rules that reach the store some other way are picked up by polling.
+	svc.rules.Save(model.TagRule{ID: "r3", Name: "all", Enabled: true, Position: 9, Match: model.RuleMatch{CIDR: "10.0.0.0/24"}, Tags: []string{"lan"}})
+	p := &poller{s: svc, entries: make(map[string]*pollEntry)}
+	now := time.Now()
+	p.tick(t.Context(), now)
+	p.tick(t.Context(), now.Add(time.Hour))
+	if d := repo.FindByIP("10.0.0.5"); !slices.Equal(d.Tags, []string{"iot", "pi", "lan"}) {
+		t.Errorf("expected polling to apply the new rule, got %v", d.Tags)
+	}
+}
+
+func TestTagRules_KeepTheirTags(t *testing.T) {
+	svc := groupFixture(t)
+	rule, err := svc.SaveTagRule(model.TagRule{Name: "printers", Enabled: true, Match: model.RuleMatch{CIDR: "10.0.0.0/30"}, Tags: []string{"printer"}})
+	if err != nil {
+		t.Fatalf("SaveTagRule error: %v", err)
+	}
+
+	if _, err := svc.RenameTag("printer", "printers", false); !errors.Is(err, ErrTagInUse) {
+		t.Errorf("expected ErrTagInUse on rename, got %v", err)
+	}
+	if _, err := svc.RenameTag("printer", "server", true); !errors.Is(err, ErrTagInUse) {
+		t.Errorf("expected ErrTagInUse on merge, got %v", err)
+	}
+	if err := svc.DeleteTag("printer"); !errors.Is(err, ErrTagInUse) {
+		t.Errorf("expected ErrTagInUse on delete, got %v", err)
+	}
+	if got := tagsOf(t, svc, "p1"); !slices.Equal(got, []string{"printer"}) {
+		t.Errorf("expected the tag to stay on p1, got %v", got)
+	}
+
+	// A disabled rule could be enabled again, so it still holds the tag.
+	rule.Enabled = false
+	svc.SaveTagRule(rule)
+	if err := svc.DeleteTag("printer"); !errors.Is(err, ErrTagInUse) {
+		t.Errorf("expected ErrTagInUse for a disabled rule, got %v", err)
+	}
+	svc.DeleteTagRule(rule.ID)
+	if err := svc.DeleteTag("printer"); err != nil {
+		t.Errorf("expected the delete to succeed once the rule is gone, got %v", err)
+	}
+}
+
+func TestTagRules_SaveAppliesOnlyThatRule(t *testing.T) {
+	svc := groupFixture(t)
+	// Stored without going through SaveTagRule, so it has not run yet.
+	svc.rules.Save(model.TagRule{ID: "other", Name: "other", Enabled: true, Position: 0, Match: model.RuleMatch{CIDR: "10.0.0.0/8"}, Tags: []string{"other"}})
+
+	if _, err := svc.SaveTagRule(model.TagRule{Name: "servers", Enabled: true, Match: model.RuleMatch{CIDR: "10.0.0.3"}, Tags: []string{"rack"}}); err != nil {
+		t.Fatalf("SaveTagRule error: %v", err)
+	}
+	if got := tagsOf(t, svc, "s1"); !slices.Equal(got, []string{"server", "rack"}) {
+		t.Errorf("expected only the saved rule to run, got %v", got)
+	}
+	if got := tagsOf(t, svc, "p1"); !slices.Equal(got, []string{"printer"}) {
+		t.Errorf("expected p1 to be left alone, got %v", got)
+	}
+}
+
+func TestTagRules_SaveReportsApplyFailure(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	repo.Save(model.Device{ID: "p1", IPAddress: "10.0.0.1"})
+	svc := NewScannerService(failingTagsRepo{repo}, &dummyLogger{})
+
+	rule, err := svc.SaveTagRule(model.TagRule{Name: "all", Enabled: true, Match: model.RuleMatch{CIDR: "10.0.0.0/8"}, Tags: []string{"lab"}})
+	if !errors.Is(err, ErrRuleNotApplied) {
+		t.Fatalf("expected ErrRuleNotApplied, got %v", err)
+	}
+	if _, err := svc.TagRule(rule.ID); err != nil {
+		t.Errorf("expected the rule to be saved anyway, got %v", err)
+	}
+}
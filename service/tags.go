@@ -1,6 +1,31 @@
 package service
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTagNotFound = errors.New("tag not found")
+	ErrTagExists   = errors.New("tag already exists")
+	ErrInvalidTag  = errors.New("invalid tag")
+	ErrTagInUse    = errors.New("tag is added by a tag rule")
+)
+
+const maxTagLength = 64
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (s *ScannerService) WithTagRepository(r repository.TagRepository) *ScannerService {
+	s.tags = r
+	return s
+}
 
 func normalizeTags(in []string, limit int) []string {
 	seen := map[string]struct{}{}
@@ -21,3 +46,324 @@ func normalizeTags(in []string, limit int) []string {
 	}
 	return out
 }
+
+// tagName normalises a tag the way device tags are stored and rejects names
+// that could not be told apart in event values.
+func tagName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case name == "":
+		return "", fmt.Errorf("%w: name is required", ErrInvalidTag)
+	case len(name) > maxTagLength:
+		return "", fmt.Errorf("%w: name is longer than %d characters", ErrInvalidTag, maxTagLength)
+	case strings.Contains(name, ","):
+		return "", fmt.Errorf("%w: name cannot contain a comma", ErrInvalidTag)
+	}
+	return name, nil
+}
+
+func tagNames(in []string) ([]string, error) {
+	out := make([]string, 0, len(in))
+	for _, t := range in {
+		name, err := tagName(t)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+// Tags lists every registered tag as well as tags only found on devices,
+// with the number of devices carrying each.
+func (s *ScannerService) Tags() ([]model.Tag, error) {
+	registered, err := s.tags.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.repo.TagCounts()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.Tag, 0, len(registered))
+	for _, t := range registered {
+		t.DeviceCount = counts[t.Name]
+		delete(counts, t.Name)
+		out = append(out, t)
+	}
+	for name, n := range counts {
+		out = append(out, model.Tag{Name: name, DeviceCount: n})
+	}
+	slices.SortFunc(out, func(a, b model.Tag) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+func (s *ScannerService) Tag(name string) (*model.Tag, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	t, err := s.tags.FindByName(name)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.repo.TagCounts()
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		if counts[name] == 0 {
+			return nil, ErrTagNotFound
+		}
+		t = &model.Tag{Name: name}
+	}
+	t.DeviceCount = counts[name]
+	return t, nil
+}
+
+// SaveTag registers a tag or, with update set, changes the color and
+// description of an existing one.
+func (s *ScannerService) SaveTag(t model.Tag, update bool) (model.Tag, error) {
+	name, err := tagName(t.Name)
+	if err != nil {
+		return t, err
+	}
+	t.Name = name
+	if t.Color != "" && !tagColorPattern.MatchString(t.Color) {
+		return t, fmt.Errorf("%w: color must look like #rrggbb", ErrInvalidTag)
+	}
+	t.Color = strings.ToLower(t.Color)
+
+	old, err := s.tags.FindByName(name)
+	if err != nil {
+		return t, err
+	}
+	switch {
+	case old != nil && !update:
+		return t, fmt.Errorf("%w: %s", ErrTagExists, name)
+	case old != nil:
+		t.CreatedAt = old.CreatedAt
+	case update:
+		// Tags found only on devices are registered on first update.
+		if _, err := s.Tag(name); err != nil {
+			return t, err
+		}
+		t.CreatedAt = time.Now()
+	default:
+		t.CreatedAt = time.Now()
+	}
+	if err := s.tags.Save(t); err != nil {
+		return t, err
+	}
+	counts, err := s.repo.TagCounts()
+	t.DeviceCount = counts[name]
+	return t, err
+}
+
+// RenameTag renames a tag on every device carrying it. With merge set the
+// target may already exist and the two tags are combined; its color and
+// description win. Tags added by a tag rule cannot be renamed.
+func (s *ScannerService) RenameTag(from, to string, merge bool) (model.Tag, error) {
+	src, err := s.Tag(from)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	if err := s.checkNoRuleAdds(src.Name); err != nil {
+		return model.Tag{}, err
+	}
+	to, err = tagName(to)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	if to == src.Name {
+		return model.Tag{}, fmt.Errorf("%w: cannot rename %s to itself", ErrInvalidTag, to)
+	}
+	dst, err := s.Tag(to)
+	switch {
+	case errors.Is(err, ErrTagNotFound):
+		dst = &model.Tag{Name: to, Color: src.Color, Description: src.Description, CreatedAt: src.CreatedAt}
+	case err != nil:
+		return model.Tag{}, err
+	case !merge:
+		return model.Tag{}, fmt.Errorf("%w: %s", ErrTagExists, to)
+	}
+	if dst.CreatedAt.IsZero() {
+		dst.CreatedAt = time.Now()
+	}
+
+	if err := s.cascadeTag(src.Name, dst, func(tags []string) []string {
+		out := make([]string, 0, len(tags))
+		for _, t := range tags {
+			if t == src.Name {
+				t = to
+			}
+			if !slices.Contains(out, t) {
+				out = append(out, t)
+			}
+		}
+		return out
+	}); err != nil {
+		return model.Tag{}, err
+	}
+	t, err := s.Tag(to)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	return *t, nil
+}
+
+// DeleteTag removes a tag from every device and from the registry. Tags
+// added by a tag rule cannot be deleted.
+func (s *ScannerService) DeleteTag(name string) error {
+	t, err := s.Tag(name)
+	if err != nil {
+		return err
+	}
+	if err := s.checkNoRuleAdds(t.Name); err != nil {
+		return err
+	}
+	return s.cascadeTag(t.Name, nil, func(tags []string) []string {
+		return slices.DeleteFunc(slices.Clone(tags), func(tag string) bool { return tag == t.Name })
+	})
+}
+
+// BulkTags adds and removes tags on the devices listed in ids, or on those
+// matching a search query, in one transaction. Removals are applied first.
+func (s *ScannerService) BulkTags(ids []string, query string, add, remove []string) (model.BulkTagResult, error) {
+	var res model.BulkTagResult
+	add, err := tagNames(add)
+	if err != nil {
+		return res, err
+	}
+	remove, err = tagNames(remove)
+	if err != nil {
+		return res, err
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		return res, fmt.Errorf("%w: nothing to add or remove", ErrInvalidTag)
+	}
+
+	var devices []model.Device
+	switch {
+	case len(ids) > 0 && query != "":
+		return res, fmt.Errorf("%w: give either ids or a query, not both", ErrInvalidTag)
+	case len(ids) > 0:
+		for _, id := range ids {
+			d, err := s.repo.FindByID(id)
+			if err != nil {
+				return res, err
+			}
+			if d == nil {
+				return res, fmt.Errorf("%w: unknown device %q", ErrInvalidTag, id)
+			}
+			if !slices.ContainsFunc(devices, func(o model.Device) bool { return o.ID == d.ID }) {
+				devices = append(devices, *d)
+			}
+		}
+	case query != "":
+		expr, err := ParseQuery(query, time.Now())
+		if err != nil {
+			return res, err
+		}
+		page, err := s.repo.List(repository.DeviceQuery{Match: expr})
+		if err != nil {
+			return res, err
+		}
+		devices = page.Devices
+	default:
+		return res, fmt.Errorf("%w: ids or a query is required", ErrInvalidTag)
+	}
+
+	next := make(map[string][]string)
+	for _, d := range devices {
+		tags := slices.DeleteFunc(slices.Clone(d.Tags), func(t string) bool { return slices.Contains(remove, t) })
+		for _, t := range add {
+			if !slices.Contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+		if len(tags) > s.cfg.MaxTagsPerDevice {
+			return res, fmt.Errorf("%w: device %s would have more than %d tags", ErrInvalidTag, d.ID, s.cfg.MaxTagsPerDevice)
+		}
+		next[d.ID] = tags
+	}
+	if err := s.tags.Ensure(add); err != nil {
+		return res, err
+	}
+	updated, err := s.applyTags(devices, next)
+	if err != nil {
+		return res, err
+	}
+	return model.BulkTagResult{Matched: len(devices), Updated: updated}, nil
+}
+
+// checkNoRuleAdds fails with ErrTagInUse while a tag rule, enabled or not,
+// adds name: the rule would bring the tag back on its next run.
+func (s *ScannerService) checkNoRuleAdds(name string) error {
+	rules, err := s.rules.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, r := range rules {
+		if slices.Contains(r.Tags, name) {
+			return fmt.Errorf("%w: rule %q adds %s, change the rule first", ErrTagInUse, r.Name, name)
+		}
+	}
+	return nil
+}
+
+// cascadeTag rewrites the tags of every device carrying name and replaces
+// name in the registry with to, or drops it when to is nil, in one
+// transaction.
+func (s *ScannerService) cascadeTag(name string, to *model.Tag, rewrite func([]string) []string) error {
+	page, err := s.repo.List(repository.DeviceQuery{Tag: name})
+	if err != nil {
+		return err
+	}
+	next := make(map[string][]string, len(page.Devices))
+	for _, d := range page.Devices {
+		next[d.ID] = rewrite(d.Tags)
+	}
+	changed, events := tagChanges(page.Devices, next)
+	if err := s.tags.Cascade(name, to, changed); err != nil {
+		return err
+	}
+	s.appendEvents(events)
+	return nil
+}
+
+// applyTags writes the changed tag lists in one batch and records a
+// tags_changed event per device that changed.
+func (s *ScannerService) applyTags(devices []model.Device, next map[string][]string) (int, error) {
+	changed, events := tagChanges(devices, next)
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	if err := s.repo.SetTags(changed); err != nil {
+		return 0, err
+	}
+	s.appendEvents(events)
+	return len(changed), nil
+}
+
+// tagChanges returns the tag lists in next that differ from the devices'
+// current ones, keyed by device ID, with a tags_changed event for each.
+func tagChanges(devices []model.Device, next map[string][]string) (map[string][]string, []model.DeviceEvent) {
+	changed := make(map[string][]string)
+	var events []model.DeviceEvent
+	now := time.Now()
+	for _, d := range devices {
+		tags, ok := next[d.ID]
+		if !ok || slices.Equal(d.Tags, tags) {
+			continue
+		}
+		changed[d.ID] = tags
+		events = append(events, model.DeviceEvent{
+			DeviceID:  d.ID,
+			Type:      model.EventTagsChanged,
+			OldValue:  strings.Join(d.Tags, ","),
+			NewValue:  strings.Join(tags, ","),
+			Timestamp: now,
+		})
+	}
+	return changed, events
+}
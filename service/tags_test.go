@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"slices"
+	"testing"
+)
+
+func tagsOf(t *testing.T, svc *ScannerService, id string) []string {
+	t.Helper()
+	d, err := svc.FindByID(id)
+	if err != nil || d == nil {
+		t.Fatalf("device %s not found: %v", id, err)
+	}
+	return d.Tags
+}
+
+func TestTags_ListWithCounts(t *testing.T) {
+	svc := groupFixture(t)
+	if _, err := svc.SaveTag(model.Tag{Name: " Printer ", Color: "#FF0000"}, false); err != nil {
+		t.Fatalf("SaveTag error: %v", err)
+	}
+	if _, err := svc.SaveTag(model.Tag{Name: "unused"}, false); err != nil {
+		t.Fatalf("SaveTag error: %v", err)
+	}
+	if _, err := svc.SaveTag(model.Tag{Name: "printer"}, false); !errors.Is(err, ErrTagExists) {
+		t.Errorf("expected ErrTagExists, got %v", err)
+	}
+	if _, err := svc.SaveTag(model.Tag{Name: "x", Color: "red"}, false); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected ErrInvalidTag for a bad color, got %v", err)
+	}
+
+	tags, err := svc.Tags()
+	if err != nil {
+		t.Fatalf("Tags error: %v", err)
+	}
+	got := map[string]int{}
+	for _, tag := range tags {
+		got[tag.Name] = tag.DeviceCount
+	}
+	want := map[string]int{"printer": 2, "server": 1, "unused": 0}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for name, n := range want {
+		if got[name] != n {
+			t.Errorf("expected %s on %d devices, got %d", name, n, got[name])
+		}
+	}
+	if tags[0].Name != "printer" || tags[0].Color != "#ff0000" {
+		t.Errorf("expected printer first with its color, got %+v", tags[0])
+	}
+
+	// A tag only found on devices can be described without registering it first.
+	if _, err := svc.SaveTag(model.Tag{Name: "server", Description: "racks"}, true); err != nil {
+		t.Errorf("SaveTag update error: %v", err)
+	}
+	if _, err := svc.SaveTag(model.Tag{Name: "nope"}, true); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("expected ErrTagNotFound, got %v", err)
+	}
+}
+
+func TestTags_RenameMergeDelete(t *testing.T) {
+	svc := groupFixture(t)
+	svc.UpdateTags("s1", []string{"server", "printer"})
+	svc.SaveTag(model.Tag{Name: "printer", Description: "paper"}, true)
+
+	if _, err := svc.RenameTag("printer", "server", false); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("expected ErrTagExists without merge, got %v", err)
+	}
+	renamed, err := svc.RenameTag("printer", "printers", false)
+	if err != nil {
+		t.Fatalf("RenameTag error: %v", err)
+	}
+	if renamed.Description != "paper" || renamed.DeviceCount != 3 {
+		t.Errorf("expected metadata and devices to move, got %+v", renamed)
+	}
+	if _, err := svc.Tag("printer"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("expected old name to be gone, got %v", err)
+	}
+
+	merged, err := svc.RenameTag("printers", "server", true)
+	if err != nil {
+		t.Fatalf("merge error: %v", err)
+	}
+	if merged.DeviceCount != 3 {
+		t.Errorf("expected 3 devices after merge, got %d", merged.DeviceCount)
+	}
+	if got := tagsOf(t, svc, "s1"); !slices.Equal(got, []string{"server"}) {
+		t.Errorf("expected merged tags to be deduplicated, got %v", got)
+	}
+	events, _ := svc.DeviceHistory("p1", repository.EventFilter{Types: []string{model.EventTagsChanged}})
+	if len(events) != 2 || events[0].NewValue != "server" {
+		t.Errorf("expected tags_changed events for the rename and merge, got %+v", events)
+	}
+
+	if err := svc.DeleteTag("server"); err != nil {
+		t.Fatalf("DeleteTag error: %v", err)
+	}
+	for _, id := range []string{"p1", "p2", "s1"} {
+		if got := tagsOf(t, svc, id); len(got) != 0 {
+			t.Errorf("expected %s to lose the tag, got %v", id, got)
+		}
+	}
+	if err := svc.DeleteTag("server"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("expected ErrTagNotFound, got %v", err)
+	}
+}
+
+func TestBulkTags(t *testing.T) {
+	svc := groupFixture(t)
+
+	res, err := svc.BulkTags([]string{"p1", "s1"}, "", []string{"Floor-2"}, []string{"printer"})
+	if err != nil {
+		t.Fatalf("BulkTags error: %v", err)
+	}
+	if res != (model.BulkTagResult{Matched: 2, Updated: 2}) {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if got := tagsOf(t, svc, "p1"); !slices.Equal(got, []string{"floor-2"}) {
+		t.Errorf("expected p1 tags [floor-2], got %v", got)
+	}
+	if got := tagsOf(t, svc, "s1"); !slices.Equal(got, []string{"server", "floor-2"}) {
+		t.Errorf("expected s1 tags [server floor-2], got %v", got)
+	}
+	if _, err := svc.Tag("floor-2"); err != nil {
+		t.Errorf("expected added tag to be registered, got %v", err)
+	}
+
+	res, err = svc.BulkTags(nil, "status:online", []string{"floor-2"}, nil)
+	if err != nil {
+		t.Fatalf("BulkTags by query error: %v", err)
+	}
+	if res != (model.BulkTagResult{Matched: 2, Updated: 1}) {
+		t.Errorf("expected only p2 to change, got %+v", res)
+	}
+
+	cases := []struct {
+		name  string
+		ids   []string
+		query string
+		add   []string
+	}{
+		{"no selection", nil, "", []string{"a"}},
+		{"both selections", []string{"p1"}, "status:online", []string{"a"}},
+		{"unknown device", []string{"nope"}, "", []string{"a"}},
+		{"nothing to do", []string{"p1"}, "", nil},
+		{"comma", []string{"p1"}, "", []string{"a,b"}},
+	}
+	for _, c := range cases {
+		if _, err := svc.BulkTags(c.ids, c.query, c.add, nil); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("%s: expected ErrInvalidTag, got %v", c.name, err)
+		}
+	}
+	var syntaxErr *QuerySyntaxError
+	if _, err := svc.BulkTags(nil, "status:", []string{"a"}, nil); !errors.As(err, &syntaxErr) {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+
+	cfg := fastPolling()
+	cfg.MaxTagsPerDevice = 2
+	svc.WithConfig(cfg)
+	if _, err := svc.BulkTags([]string{"s1"}, "", []string{"extra"}, nil); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected the tag cap to be enforced, got %v", err)
+	}
+	if got := tagsOf(t, svc, "p1"); !slices.Equal(got, []string{"floor-2"}) {
+		t.Errorf("expected failed bulk update to leave devices alone, got %v", got)
+	}
+}
+
+// failingTagsRepo fails every device tag write.
+type failingTagsRepo struct{ *fakeDeviceRepo }
+
+func (r failingTagsRepo) SetTags(map[string][]string) error { return errors.New("disk full") }
+
+func TestTags_RenameIsAtomic(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	repo.Save(model.Device{ID: "p1", IPAddress: "10.0.0.1", Tags: []string{"printer"}})
+	svc := NewScannerService(failingTagsRepo{repo}, &dummyLogger{})
+	svc.SaveTag(model.Tag{Name: "printer"}, false)
+
+	if _, err := svc.RenameTag("printer", "printers", false); err == nil {
+		t.Fatal("expected the rename to fail")
+	}
+	if _, err := svc.Tag("printer"); err != nil {
+		t.Errorf("expected the source tag to stay registered, got %v", err)
+	}
+	if _, err := svc.Tag("printers"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("expected the target tag not to be registered, got %v", err)
+	}
+	if got := tagsOf(t, svc, "p1"); !slices.Equal(got, []string{"printer"}) {
+		t.Errorf("expected the device tags to be unchanged, got %v", got)
+	}
+}
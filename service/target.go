@@ -0,0 +1,212 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TargetError describes a single malformed token of a target spec. It
+// unwraps to ErrInvalidRange so callers can map it to a 400.
+type TargetError struct {
+	Field  string
+	Index  int
+	Token  string
+	Reason string
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("invalid %s token %d %q: %s", e.Field, e.Index+1, e.Token, e.Reason)
+}
+
+func (e *TargetError) Unwrap() error { return ErrInvalidRange }
+
+type addrRange struct {
+	first netip.Addr
+	last  netip.Addr
+}
+
+// TargetSpec is a parsed scan target: the union of every CIDR, dash range and
+// single host in the spec, minus the exclusions, kept as sorted disjoint
+// ranges.
+type TargetSpec struct {
+	ranges []addrRange
+}
+
+// ParseTargets parses a comma separated list of CIDRs ("10.0.1.0/24"), dash
+// ranges ("10.0.0.10-10.0.0.50" or "10.0.0.10-50") and single addresses,
+// and removes any address matched by an entry of exclude.
+func ParseTargets(spec string, exclude []string) (*TargetSpec, error) {
+	var include []addrRange
+	for i, tok := range strings.Split(spec, ",") {
+		r, err := parseTargetToken(strings.TrimSpace(tok), true)
+		if err != nil {
+			return nil, &TargetError{Field: "range", Index: i, Token: strings.TrimSpace(tok), Reason: err.Error()}
+		}
+		include = append(include, r)
+	}
+
+	var excluded []addrRange
+	for i, tok := range exclude {
+		r, err := parseTargetToken(strings.TrimSpace(tok), false)
+		if err != nil {
+			return nil, &TargetError{Field: "exclude", Index: i, Token: strings.TrimSpace(tok), Reason: err.Error()}
+		}
+		excluded = append(excluded, r)
+	}
+
+	ranges := mergeRanges(include)
+	for _, ex := range mergeRanges(excluded) {
+		ranges = subtractRange(ranges, ex)
+	}
+	return &TargetSpec{ranges: ranges}, nil
+}
+
+// parseTargetToken parses one token. For included CIDRs the IPv4 network and
+// broadcast addresses are dropped; excluded CIDRs cover the whole prefix.
+func parseTargetToken(tok string, include bool) (addrRange, error) {
+	if tok == "" {
+		return addrRange{}, fmt.Errorf("empty token")
+	}
+
+	if strings.Contains(tok, "/") {
+		if include {
+			first, last, err := cidrBounds(tok)
+			if err != nil {
+				return addrRange{}, fmt.Errorf("not a valid CIDR")
+			}
+			return addrRange{first, last}, nil
+		}
+		p, err := netip.ParsePrefix(tok)
+		if err != nil {
+			return addrRange{}, fmt.Errorf("not a valid CIDR")
+		}
+		p = p.Masked()
+		return addrRange{p.Addr(), lastAddr(p)}, nil
+	}
+
+	if from, to, ok := strings.Cut(tok, "-"); ok {
+		first, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil {
+			return addrRange{}, fmt.Errorf("range start is not an IP address")
+		}
+		last, err := parseRangeEnd(first, strings.TrimSpace(to))
+		if err != nil {
+			return addrRange{}, err
+		}
+		if last.Less(first) {
+			return addrRange{}, fmt.Errorf("range end is before range start")
+		}
+		return addrRange{first, last}, nil
+	}
+
+	addr, err := netip.ParseAddr(tok)
+	if err != nil {
+		return addrRange{}, fmt.Errorf("not an IP address, CIDR or range")
+	}
+	return addrRange{addr, addr}, nil
+}
+
+// parseRangeEnd accepts either a full address or, for IPv4, just the last
+// octet ("10.0.0.10-50").
+func parseRangeEnd(first netip.Addr, s string) (netip.Addr, error) {
+	if last, err := netip.ParseAddr(s); err == nil {
+		if last.Is4() != first.Is4() {
+			return netip.Addr{}, fmt.Errorf("range mixes IPv4 and IPv6")
+		}
+		return last, nil
+	}
+	if first.Is4() {
+		if octet, err := strconv.ParseUint(s, 10, 8); err == nil {
+			b := first.As4()
+			b[3] = byte(octet)
+			return netip.AddrFrom4(b), nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("range end is not an IP address")
+}
+
+func mergeRanges(in []addrRange) []addrRange {
+	if len(in) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(in)
+	slices.SortFunc(sorted, func(a, b addrRange) int { return a.first.Compare(b.first) })
+
+	out := []addrRange{sorted[0]}
+	for _, r := range sorted[1:] {
+		cur := &out[len(out)-1]
+		if r.first.BitLen() == cur.last.BitLen() && (!cur.last.Less(r.first) || cur.last.Next() == r.first) {
+			if cur.last.Less(r.last) {
+				cur.last = r.last
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func subtractRange(in []addrRange, ex addrRange) []addrRange {
+	out := make([]addrRange, 0, len(in))
+	for _, r := range in {
+		if r.first.BitLen() != ex.first.BitLen() || ex.last.Less(r.first) || r.last.Less(ex.first) {
+			out = append(out, r)
+			continue
+		}
+		if r.first.Less(ex.first) {
+			out = append(out, addrRange{r.first, ex.first.Prev()})
+		}
+		if ex.last.Less(r.last) {
+			out = append(out, addrRange{ex.last.Next(), r.last})
+		}
+	}
+	return out
+}
+
+// Size returns the number of addresses the spec expands to, saturating at
+// math.MaxUint64.
+func (t *TargetSpec) Size() uint64 {
+	var total uint64
+	for _, r := range t.ranges {
+		n := rangeSize(r.first, r.last)
+		if total+n < total {
+			return math.MaxUint64
+		}
+		total += n
+	}
+	return total
+}
+
+func (t *TargetSpec) iterator() *targetIterator {
+	return &targetIterator{ranges: t.ranges}
+}
+
+// targetIterator chains one ipIterator per disjoint range of a TargetSpec.
+type targetIterator struct {
+	ranges []addrRange
+	cur    *ipIterator
+}
+
+func (it *targetIterator) NextChunk(n int) []string {
+	out := make([]string, 0, n)
+	for len(out) < n {
+		if it.cur == nil {
+			if len(it.ranges) == 0 {
+				break
+			}
+			it.cur = newIPIterator(it.ranges[0].first, it.ranges[0].last)
+			it.ranges = it.ranges[1:]
+		}
+		addr, ok := it.cur.Next()
+		if !ok {
+			it.cur = nil
+			continue
+		}
+		out = append(out, addr.String())
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func expand(t *testing.T, spec *TargetSpec) []string {
+	t.Helper()
+	var out []string
+	it := spec.iterator()
+	for chunk := it.NextChunk(3); len(chunk) > 0; chunk = it.NextChunk(3) {
+		out = append(out, chunk...)
+	}
+	return out
+}
+
+func TestParseTargets_Forms(t *testing.T) {
+	cases := []struct {
+		spec    string
+		exclude []string
+		want    []string
+	}{
+		{"10.0.0.7", nil, []string{"10.0.0.7"}},
+		{"10.0.0.10-10.0.0.12", nil, []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}},
+		{"10.0.0.10-12", nil, []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}},
+		{"10.0.1.0/30, 10.0.2.7", nil, []string{"10.0.1.1", "10.0.1.2", "10.0.2.7"}},
+		{"10.0.0.1-3,10.0.0.2-5", nil, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}},
+		{"10.0.1.0/29", []string{"10.0.1.1", "10.0.1.4-5"}, []string{"10.0.1.2", "10.0.1.3", "10.0.1.6"}},
+		{"10.0.1.0/24", []string{"10.0.1.0/25", "10.0.1.128/26", "10.0.1.192/27", "10.0.1.224/28", "10.0.1.240/29"}, []string{"10.0.1.248", "10.0.1.249", "10.0.1.250", "10.0.1.251", "10.0.1.252", "10.0.1.253", "10.0.1.254"}},
+	}
+	for _, c := range cases {
+		spec, err := ParseTargets(c.spec, c.exclude)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", c.spec, err)
+		}
+		got := expand(t, spec)
+		if strings.Join(got, " ") != strings.Join(c.want, " ") {
+			t.Errorf("%q excluding %v: expected %v, got %v", c.spec, c.exclude, c.want, got)
+		}
+		if spec.Size() != uint64(len(c.want)) {
+			t.Errorf("%q: expected size %d, got %d", c.spec, len(c.want), spec.Size())
+		}
+	}
+}
+
+func TestParseTargets_Errors(t *testing.T) {
+	cases := []struct {
+		spec    string
+		exclude []string
+		field   string
+		token   string
+	}{
+		{"10.0.0.0/24,10.0.0.300", nil, "range", "10.0.0.300"},
+		{"10.0.0.50-10.0.0.10", nil, "range", "10.0.0.50-10.0.0.10"},
+		{"10.0.0.1-256", nil, "range", "10.0.0.1-256"},
+		{"10.0.0.1-fe80::1", nil, "range", "10.0.0.1-fe80::1"},
+		{"10.0.0.0/24,", nil, "range", ""},
+		{"10.0.0.0/24", []string{"10.0.1.1", "printer"}, "exclude", "printer"},
+	}
+	for _, c := range cases {
+		_, err := ParseTargets(c.spec, c.exclude)
+		var te *TargetError
+		if !errors.As(err, &te) {
+			t.Fatalf("%q: expected TargetError, got %v", c.spec, err)
+		}
+		if te.Field != c.field || te.Token != c.token {
+			t.Errorf("%q: expected error at %s %q, got %s %q", c.spec, c.field, c.token, te.Field, te.Token)
+		}
+		if !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("%q: expected error to wrap ErrInvalidRange", c.spec)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package service
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// Bits of tcpi_options.
+const (
+	tcpiOptTimestamps = 1
+	tcpiOptSACK       = 2
+	tcpiOptWScale     = 4
+)
+
+// tcpTraits reads what the kernel learned about the peer during the handshake
+// of an established connection. Until the peer sends data, tcpi_snd_wnd still
+// holds the unscaled window of its SYN/ACK; older kernels do not report it
+// and leave Window at 0.
+func tcpTraits(conn net.Conn) (TCPTraits, bool) {
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return TCPTraits{}, false
+	}
+	raw, err := tc.SyscallConn()
+	if err != nil {
+		return TCPTraits{}, false
+	}
+	var info *unix.TCPInfo
+	if err := raw.Control(func(fd uintptr) {
+		info, err = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil || info == nil {
+		return TCPTraits{}, false
+	}
+	return TCPTraits{
+		Window:        int(info.Snd_wnd),
+		WindowScaling: info.Options&tcpiOptWScale != 0,
+		SACK:          info.Options&tcpiOptSACK != 0,
+		Timestamps:    info.Options&tcpiOptTimestamps != 0,
+	}, true
+}
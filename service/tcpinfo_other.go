@@ -0,0 +1,9 @@
+//go:build !linux
+
+package service
+
+import "net"
+
+func tcpTraits(conn net.Conn) (TCPTraits, bool) {
+	return TCPTraits{}, false
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	neighbourTablePath = "/proc/net/arp"
+	readNeighbours6    = dumpNeighbours6
+)
+
+// datagramPingRTT sends one echo request per address over unprivileged ICMP
+// datagram sockets and reports the round-trip time and TTL of every address
+// that answered within timeout.
+func datagramPingRTT(ips []string, timeout time.Duration) (map[string]echoReply, error) {
+	return echoAll(ips, timeout, false)
+}
+
+// readNeighbourTable returns the complete entries of the kernel ARP cache
+// keyed by IP address.
+func readNeighbourTable() (map[string]string, error) {
+	f, err := os.Open(neighbourTablePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	out := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	sc.Scan() // header
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		flags, mac := fields[2], fields[3]
+		if flags == "0x0" || mac == "00:00:00:00:00:00" {
+			continue
+		}
+		out[fields[0]] = mac
+	}
+	return out, sc.Err()
+}
+
+func neighbourMAC(ip string) string {
+	table, err := neighbourTableFor(ip)
+	if err != nil {
+		return ""
+	}
+	return table[ip]
+}
+
+// neighbourTableFor returns the ARP cache for IPv4 addresses and the NDP
+// cache for IPv6 ones.
+func neighbourTableFor(ip string) (map[string]string, error) {
+	if isIPv6(ip) {
+		return readNeighbours6()
+	}
+	return readNeighbourTable()
+}
+
+// neighbourAlive nudges the kernel into resolving each address (ARP for
+// IPv4, NDP for IPv6) by sending a single UDP datagram to the discard port,
+// then checks which addresses made it into the neighbour table. Only hosts on
+// attached segments can show up.
+func (s *ScannerService) neighbourAlive(ctx context.Context, ips []string) (map[string]bool, error) {
+	runParallel(ctx, s.cfg.HostParallelism, ips, func(ip string) {
+		if addr := net.ParseIP(strings.Split(ip, "%")[0]); addr == nil || addr.IsLoopback() {
+			return
+		}
+		if err := s.probes.Acquire(ctx, 1); err != nil {
+			return
+		}
+		defer s.probes.Release(1)
+		conn, err := net.Dial("udp", net.JoinHostPort(ip, "9"))
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte{0})
+		conn.Close()
+	})
+
+	select {
+	case <-time.After(s.cfg.Liveness.Timeout):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	v4, err4 := readNeighbourTable()
+	v6, err6 := readNeighbours6()
+	if err4 != nil && err6 != nil {
+		return nil, errors.Join(err4, err6)
+	}
+	alive := make(map[string]bool)
+	for _, ip := range ips {
+		if _, ok := v4[ip]; ok {
+			alive[ip] = true
+		} else if _, ok := v6[ip]; ok {
+			alive[ip] = true
+		}
+	}
+	return alive, nil
+}
+
+func isIPv6(ip string) bool {
+	return strings.Contains(ip, ":")
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadNeighbourTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arp")
+	table := `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.1      0x1         0x2         aa:bb:cc:dd:ee:01     *        eth0
+192.168.1.7      0x1         0x0         00:00:00:00:00:00     *        eth0
+192.168.1.9      0x1         0x6         aa:bb:cc:dd:ee:09     *        eth0
+`
+	if err := os.WriteFile(path, []byte(table), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := neighbourTablePath
+	neighbourTablePath = path
+	defer func() { neighbourTablePath = old }()
+
+	got, err := readNeighbourTable()
+	if err != nil {
+		t.Fatalf("readNeighbourTable error: %v", err)
+	}
+	if len(got) != 2 || got["192.168.1.1"] != "aa:bb:cc:dd:ee:01" || got["192.168.1.9"] != "aa:bb:cc:dd:ee:09" {
+		t.Errorf("unexpected neighbour table %v", got)
+	}
+	if mac := neighbourMAC("192.168.1.7"); mac != "" {
+		t.Errorf("expected incomplete entry to be ignored, got %q", mac)
+	}
+}
+
+func TestDatagramPing_Localhost(t *testing.T) {
+	if !unprivilegedICMPAvailable() {
+		t.Skip("ICMP datagram sockets not permitted by net.ipv4.ping_group_range")
+	}
+	got, err := datagramPingRTT([]string{"127.0.0.1"}, time.Second)
+	if err != nil {
+		t.Fatalf("datagramPingRTT error: %v", err)
+	}
+	if _, ok := got["127.0.0.1"]; !ok {
+		t.Errorf("expected 127.0.0.1 to answer, got %v", got)
+	}
+}
+
+func TestWithConfig_ForcedMode(t *testing.T) {
+	cfg := DefaultScannerConfig()
+	cfg.Mode = ModeUnprivileged
+	svc := NewScannerService(newFakeDeviceRepo(), &dummyLogger{}).WithConfig(cfg)
+	if svc.Mode() != ModeUnprivileged {
+		t.Errorf("expected unprivileged mode, got %q", svc.Mode())
+	}
+}
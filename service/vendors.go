@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"network-scanner/model"
+	"time"
+)
+
+var ErrVendorsReadOnly = errors.New("manufacturer resolver cannot be reloaded")
+
+func (s *ScannerService) vendorDB() (VendorDatabase, error) {
+	db, ok := s.resolver.(VendorDatabase)
+	if !ok {
+		return nil, ErrVendorsReadOnly
+	}
+	return db, nil
+}
+
+// ReloadVendors re-reads the vendor database and updates the manufacturer
+// of existing devices. A malformed file leaves the previous data in use.
+func (s *ScannerService) ReloadVendors() (model.VendorUpdate, error) {
+	db, err := s.vendorDB()
+	if err != nil {
+		return model.VendorUpdate{}, err
+	}
+	n, err := db.Reload()
+	if err != nil {
+		return model.VendorUpdate{}, err
+	}
+	return s.vendorsUpdated(n)
+}
+
+// ImportVendors adds an IEEE registry file (oui.txt, or a registry CSV such
+// as mam.csv or oui36.csv) to the vendor database.
+func (s *ScannerService) ImportVendors(in io.Reader) (model.VendorUpdate, error) {
+	db, err := s.vendorDB()
+	if err != nil {
+		return model.VendorUpdate{}, err
+	}
+	n, err := db.Import(in)
+	if err != nil {
+		return model.VendorUpdate{}, err
+	}
+	return s.vendorsUpdated(n)
+}
+
+// WatchVendors reloads the vendor database whenever its files change,
+// checking every Vendors.WatchInterval until ctx is done.
+func (s *ScannerService) WatchVendors(ctx context.Context) {
+	db, err := s.vendorDB()
+	if err != nil {
+		return
+	}
+	t := time.NewTicker(s.cfg.Vendors.WatchInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if !db.Changed() {
+			continue
+		}
+		if res, err := s.ReloadVendors(); err != nil {
+			s.logger.Error("Failed to reload vendor database: ", err)
+		} else {
+			s.logger.Info("Vendor database reloaded: ", res.Prefixes, " prefixes, ", res.DevicesUpdated, " devices updated")
+		}
+	}
+}
+
+func (s *ScannerService) vendorsUpdated(prefixes int) (model.VendorUpdate, error) {
+	updated, err := s.reresolveManufacturers()
+	if updated > 0 {
+		s.classifyDevices(nil)
+	}
+	return model.VendorUpdate{Prefixes: prefixes, DevicesUpdated: updated}, err
+}
+
+// reresolveManufacturers looks the manufacturer of every device with a MAC
+// up again. As during scans, an empty result keeps the stored value.
+func (s *ScannerService) reresolveManufacturers() (int, error) {
+	return s.updateDevices(nil, func(d *model.Device) bool {
+		if d.MACAddress == "" {
+			return false
+		}
+		resolved := s.resolver.Resolve(d.MACAddress)
+		if resolved == "" || resolved == d.Manufacturer {
+			return false
+		}
+		d.Manufacturer = resolved
+		return true
+	})
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"network-scanner/model"
+	"network-scanner/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadVendors_ReresolvesDevices(t *testing.T) {
+	repo := newFakeDeviceRepo()
+	repo.Save(model.Device{ID: "d1", IPAddress: "10.0.0.1", MACAddress: "00:22:72:00:00:01", FirstSeen: time.Now()})
+	repo.Save(model.Device{ID: "d2", IPAddress: "10.0.0.2", MACAddress: "00:00:0c:00:00:02", Manufacturer: "Cisco Systems, Inc", FirstSeen: time.Now()})
+	repo.Save(model.Device{ID: "d3", IPAddress: "10.0.0.3"})
+	svc := NewScannerServiceWithResolver(repo, &dummyLogger{}, fixtureResolver(t, vendorFixture))
+
+	res, err := svc.ImportVendors(strings.NewReader(ouiText))
+	if err != nil {
+		t.Fatalf("ImportVendors error: %v", err)
+	}
+	if res.DevicesUpdated != 2 {
+		t.Errorf("expected 2 devices updated, got %+v", res)
+	}
+	if d, _ := repo.FindByID("d1"); d.Manufacturer != "American Micro-Fuel Device Corp." {
+		t.Errorf("expected d1 to be resolved, got %q", d.Manufacturer)
+	}
+	events, _ := svc.DeviceHistory("d2", repository.EventFilter{Types: []string{model.EventManufacturerChanged}})
+	if len(events) != 1 || events[0].NewValue != "Cisco Systems Inc (renamed)" {
+		t.Errorf("expected a manufacturer_changed event for d2, got %+v", events)
+	}
+
+	if res, err := svc.ReloadVendors(); err != nil || res.DevicesUpdated != 0 {
+		t.Errorf("expected an unchanged reload to update nothing, got %+v, %v", res, err)
+	}
+
+	plain := NewScannerService(repo, &dummyLogger{})
+	if _, err := plain.ReloadVendors(); !errors.Is(err, ErrVendorsReadOnly) {
+		t.Errorf("expected ErrVendorsReadOnly without a vendor database, got %v", err)
+	}
+}